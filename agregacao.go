@@ -0,0 +1,386 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type valorCampo struct {
+	Texto    string
+	Numero   float64
+	Numerico bool
+	// Nos campos de preço, Preco tem o valor exato e Numero só uma aproximação.
+	Preco   Preco
+	EhPreco bool
+}
+
+func valorNumerico(n float64) valorCampo {
+	return valorCampo{Numero: n, Numerico: true}
+}
+
+func valorPreco(p Preco) valorCampo {
+	return valorCampo{Numero: p.float64(), Numerico: true, Preco: p, EhPreco: true}
+}
+
+func valorTexto(b []byte) valorCampo {
+	return valorCampo{Texto: textoFixo(b)}
+}
+
+func (v valorCampo) String() string {
+	if v.EhPreco {
+		return v.Preco.String()
+	}
+	if v.Numerico {
+		return formatarNumero(v.Numero)
+	}
+	return v.Texto
+}
+
+func formatarNumero(n float64) string {
+	return strconv.FormatFloat(math.Round(n*1e4)/1e4, 'f', -1, 64)
+}
+
+type campoProduto struct {
+	numerico bool
+	extrair  func(produto *Produto) valorCampo
+}
+
+type campoAcesso struct {
+	numerico bool
+	extrair  func(acesso *Acesso) valorCampo
+}
+
+var camposProduto = map[string]campoProduto{
+	"id": {true, func(p *Produto) valorCampo { return valorNumerico(float64(bytesToInt32(p.ID))) }},
+	"product_id": {true, func(p *Produto) valorCampo {
+		return valorNumerico(float64(bytesToInt32(p.ProductID)))
+	}},
+	"price":         {true, func(p *Produto) valorCampo { return valorPreco(bytesToPreco(p.Price)) }},
+	"brand":         {false, func(p *Produto) valorCampo { return valorTexto(p.Brand[:]) }},
+	"category_code": {false, func(p *Produto) valorCampo { return valorTexto(p.CategoryCode[:]) }},
+}
+
+var camposAcesso = map[string]campoAcesso{
+	"id":           {true, func(a *Acesso) valorCampo { return valorNumerico(float64(bytesToInt32(a.ID))) }},
+	"user_session": {false, func(a *Acesso) valorCampo { return valorTexto(a.UserSession[:]) }},
+	"user_id":      {true, func(a *Acesso) valorCampo { return valorNumerico(float64(bytesToInt32(a.UserID))) }},
+	"event_type":   {false, func(a *Acesso) valorCampo { return valorTexto(a.EventType[:]) }},
+}
+
+func nomesCampos(tabela string) []string {
+	var nomes []string
+	switch tabela {
+	case "produtos":
+		for nome := range camposProduto {
+			nomes = append(nomes, nome)
+		}
+	case "acessos":
+		for nome := range camposAcesso {
+			nomes = append(nomes, nome)
+		}
+	}
+	sort.Strings(nomes)
+	return nomes
+}
+
+func campoNumerico(tabela string, campo string) (bool, error) {
+	switch tabela {
+	case "produtos":
+		c, ok := camposProduto[campo]
+		if ok {
+			return c.numerico, nil
+		}
+	case "acessos":
+		c, ok := camposAcesso[campo]
+		if ok {
+			return c.numerico, nil
+		}
+	default:
+		return false, fmt.Errorf("tabela desconhecida: %s", tabela)
+	}
+	return false, fmt.Errorf("campo %q não existe na tabela %s", campo, tabela)
+}
+
+// percorrerCampos lê todos os registros da tabela chamando fn com os valores dos campos
+// pedidos, na mesma ordem de campos. O slice de valores é reutilizado entre chamadas.
+func percorrerCampos(armazenamento Armazenamento, tabela string, filename string, campos []string, fn func(valores []valorCampo) error) error {
+	for _, campo := range campos {
+		if _, err := campoNumerico(tabela, campo); err != nil {
+			return err
+		}
+	}
+
+	valores := make([]valorCampo, len(campos))
+	switch tabela {
+	case "produtos":
+		extratores := make([]func(*Produto) valorCampo, len(campos))
+		for i, campo := range campos {
+			extratores[i] = camposProduto[campo].extrair
+		}
+		return percorrerProdutos(armazenamento, filename, func(produto *Produto) error {
+			for i, extrair := range extratores {
+				valores[i] = extrair(produto)
+			}
+			return fn(valores)
+		})
+	case "acessos":
+		extratores := make([]func(*Acesso) valorCampo, len(campos))
+		for i, campo := range campos {
+			extratores[i] = camposAcesso[campo].extrair
+		}
+		return percorrerAcessos(armazenamento, filename, func(acesso *Acesso) error {
+			for i, extrair := range extratores {
+				valores[i] = extrair(acesso)
+			}
+			return fn(valores)
+		})
+	}
+	return fmt.Errorf("tabela desconhecida: %s", tabela)
+}
+
+type Agregacao struct {
+	Funcao string // count, sum, avg, min, max ou count_distinct
+	Campo  string
+}
+
+func (a Agregacao) Nome() string {
+	if a.Campo == "" {
+		return a.Funcao
+	}
+	return a.Funcao + "(" + a.Campo + ")"
+}
+
+// parseAgregacoes interpreta listas como "count,avg:price,count_distinct:brand".
+func parseAgregacoes(s string) ([]Agregacao, error) {
+	var agregacoes []Agregacao
+	for _, parte := range strings.Split(s, ",") {
+		parte = strings.TrimSpace(parte)
+		if parte == "" {
+			continue
+		}
+		funcao, campo, _ := strings.Cut(parte, ":")
+		agregacoes = append(agregacoes, Agregacao{Funcao: funcao, Campo: campo})
+	}
+
+	if len(agregacoes) == 0 {
+		return nil, fmt.Errorf("nenhuma agregação informada")
+	}
+	return agregacoes, nil
+}
+
+func validarAgregacao(tabela string, a Agregacao) error {
+	switch a.Funcao {
+	case "count":
+		if a.Campo != "" {
+			return fmt.Errorf("count não recebe campo")
+		}
+		return nil
+	case "count_distinct":
+		_, err := campoNumerico(tabela, a.Campo)
+		return err
+	case "sum", "avg", "min", "max":
+		numerico, err := campoNumerico(tabela, a.Campo)
+		if err != nil {
+			return err
+		}
+		if !numerico {
+			return fmt.Errorf("%s exige um campo numérico, %q é texto", a.Funcao, a.Campo)
+		}
+		return nil
+	}
+	return fmt.Errorf("agregação desconhecida: %s", a.Funcao)
+}
+
+// acumulador guarda soma, mínimo e máximo de preços como Preco, em centavos, para que
+// sejam exatos mesmo sobre milhões de registros e saiam com as duas casas do preço.
+type acumulador struct {
+	count     int64
+	soma      float64
+	centavos  Preco
+	ehPreco   bool
+	min       valorCampo
+	max       valorCampo
+	distintos map[string]struct{}
+}
+
+func (ac *acumulador) adicionar(v valorCampo) {
+	if ac.count == 0 || menorValor(v, ac.min) {
+		ac.min = v
+	}
+	if ac.count == 0 || menorValor(ac.max, v) {
+		ac.max = v
+	}
+	ac.count++
+	if v.EhPreco {
+		ac.ehPreco = true
+		ac.centavos += v.Preco
+	} else {
+		ac.soma += v.Numero
+	}
+	if ac.distintos != nil {
+		ac.distintos[v.String()] = struct{}{}
+	}
+}
+
+// menorValor compara preços pelos centavos e os demais números pelo valor.
+func menorValor(a valorCampo, b valorCampo) bool {
+	if a.EhPreco && b.EhPreco {
+		return a.Preco < b.Preco
+	}
+	return a.Numero < b.Numero
+}
+
+func (ac *acumulador) resultado(funcao string) valorCampo {
+	switch funcao {
+	case "sum":
+		if ac.ehPreco {
+			return valorPreco(ac.centavos)
+		}
+		return valorNumerico(ac.soma)
+	case "avg":
+		// A média de preços em geral não é um número inteiro de centavos.
+		if ac.ehPreco {
+			return valorNumerico(float64(ac.centavos) / escalaPreco / float64(ac.count))
+		}
+		return valorNumerico(ac.soma / float64(ac.count))
+	case "min":
+		return ac.min
+	case "max":
+		return ac.max
+	case "count_distinct":
+		return valorNumerico(float64(len(ac.distintos)))
+	}
+	return valorNumerico(float64(ac.count))
+}
+
+type GrupoAgregado struct {
+	Chave   []string
+	Valores []valorCampo
+}
+
+// agrupar calcula as agregações para cada combinação distinta dos campos de grupo.
+// Sem campos de grupo, o resultado é um único grupo com a tabela inteira.
+func agrupar(armazenamento Armazenamento, tabela string, filename string, camposGrupo []string, agregacoes []Agregacao) ([]GrupoAgregado, error) {
+	for _, a := range agregacoes {
+		if err := validarAgregacao(tabela, a); err != nil {
+			return nil, err
+		}
+	}
+
+	campos := append([]string{}, camposGrupo...)
+	posicaoCampo := make([]int, len(agregacoes))
+	for i, a := range agregacoes {
+		posicaoCampo[i] = -1
+		if a.Campo != "" {
+			posicaoCampo[i] = len(campos)
+			campos = append(campos, a.Campo)
+		}
+	}
+
+	type estadoGrupo struct {
+		chave []string
+		// valoresChave guarda a chave com os valores originais, para ordenar os campos
+		// numéricos como números.
+		valoresChave []valorCampo
+		acumuladores []acumulador
+	}
+	grupos := make(map[string]*estadoGrupo)
+	partesChave := make([]string, len(camposGrupo))
+
+	err := percorrerCampos(armazenamento, tabela, filename, campos, func(valores []valorCampo) error {
+		for i := range camposGrupo {
+			partesChave[i] = valores[i].String()
+		}
+		chave := strings.Join(partesChave, "\x00")
+
+		grupo, ok := grupos[chave]
+		if !ok {
+			grupo = &estadoGrupo{
+				chave:        append([]string{}, partesChave...),
+				valoresChave: append([]valorCampo{}, valores[:len(camposGrupo)]...),
+				acumuladores: make([]acumulador, len(agregacoes)),
+			}
+			for i, a := range agregacoes {
+				if a.Funcao == "count_distinct" {
+					grupo.acumuladores[i].distintos = make(map[string]struct{})
+				}
+			}
+			grupos[chave] = grupo
+		}
+
+		for i := range agregacoes {
+			var v valorCampo
+			if posicaoCampo[i] >= 0 {
+				v = valores[posicaoCampo[i]]
+			}
+			grupo.acumuladores[i].adicionar(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ordenados := make([]*estadoGrupo, 0, len(grupos))
+	for _, grupo := range grupos {
+		ordenados = append(ordenados, grupo)
+	}
+	sort.Slice(ordenados, func(i, j int) bool {
+		a, b := ordenados[i].valoresChave, ordenados[j].valoresChave
+		for k := range a {
+			if a[k].Numerico {
+				if menorValor(a[k], b[k]) || menorValor(b[k], a[k]) {
+					return menorValor(a[k], b[k])
+				}
+			} else if a[k].Texto != b[k].Texto {
+				return a[k].Texto < b[k].Texto
+			}
+		}
+		return false
+	})
+
+	resultado := make([]GrupoAgregado, len(ordenados))
+	for i, grupo := range ordenados {
+		valores := make([]valorCampo, len(agregacoes))
+		for j, a := range agregacoes {
+			valores[j] = grupo.acumuladores[j].resultado(a.Funcao)
+		}
+		resultado[i] = GrupoAgregado{Chave: grupo.chave, Valores: valores}
+	}
+
+	return resultado, nil
+}
+
+func escreverGrupos(w io.Writer, formato string, camposGrupo []string, agregacoes []Agregacao, grupos []GrupoAgregado) error {
+	cabecalho := append([]string{}, camposGrupo...)
+	for _, a := range agregacoes {
+		cabecalho = append(cabecalho, a.Nome())
+	}
+
+	linhas := make([][]string, len(grupos))
+	objetos := make([]map[string]any, len(grupos))
+	for i, grupo := range grupos {
+		linha := append([]string{}, grupo.Chave...)
+		objeto := make(map[string]any, len(cabecalho))
+		for j, campo := range camposGrupo {
+			objeto[campo] = grupo.Chave[j]
+		}
+		for j, a := range agregacoes {
+			v := grupo.Valores[j]
+			linha = append(linha, v.String())
+			if v.EhPreco {
+				objeto[a.Nome()] = v.Preco
+			} else {
+				objeto[a.Nome()] = math.Round(v.Numero*1e4) / 1e4
+			}
+		}
+		linhas[i] = linha
+		objetos[i] = objeto
+	}
+
+	return escreverSaida(w, formato, cabecalho, linhas, objetos)
+}
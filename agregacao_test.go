@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestAgruparPrecos(t *testing.T) {
+	b := abrirBancoTeste(t)
+	produtos := []Produto{
+		produtoTeste(t, 1, 100025, "marca"),
+		produtoTeste(t, 2, 102225, "marca"),
+		produtoTeste(t, 3, 10, "outra"),
+	}
+	if _, err := b.inserirProdutos(produtos); err != nil {
+		t.Fatal(err)
+	}
+
+	agregacoes, err := parseAgregacoes("sum:price,min:price,max:price,avg:price,count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grupos, err := agrupar(b.arquivos.Armazenamento, "produtos", b.arquivos.Produtos, []string{"brand"}, agregacoes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tabela, json bytes.Buffer
+	if err := escreverGrupos(&tabela, "tabela", []string{"brand"}, agregacoes, grupos); err != nil {
+		t.Fatal(err)
+	}
+	if err := escreverGrupos(&json, "json", []string{"brand"}, agregacoes, grupos); err != nil {
+		t.Fatal(err)
+	}
+
+	// Preços saem sempre com duas casas; a média não é um preço e sai como número.
+	for _, quero := range []string{"2022.50", "1000.25", "1022.25", "1011.25", "0.10"} {
+		if !strings.Contains(tabela.String(), quero) {
+			t.Errorf("tabela sem %s:\n%s", quero, tabela.String())
+		}
+	}
+	for _, quero := range []string{`"sum(price)":2022.50`, `"min(price)":1000.25`, `"max(price)":0.10`} {
+		if !strings.Contains(strings.Join(strings.Fields(json.String()), ""), quero) {
+			t.Errorf("JSON sem %s:\n%s", quero, json.String())
+		}
+	}
+}
+
+func TestAgruparOrdenaChavesNumericas(t *testing.T) {
+	b := abrirBancoTeste(t)
+	var produtos []Produto
+	for _, productID := range []int32{100, 9, 20, -3, 9} {
+		produtos = append(produtos, produtoTeste(t, productID, Preco(productID)*10, "m"))
+	}
+	if _, err := b.inserirProdutos(produtos); err != nil {
+		t.Fatal(err)
+	}
+	agregacoes, err := parseAgregacoes("count")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Como texto, 100 viria antes de 20 e de 9, e 10.00 antes de 2.00.
+	for _, campos := range [][]string{{"product_id"}, {"brand", "price"}} {
+		grupos, err := agrupar(b.arquivos.Armazenamento, "produtos", b.arquivos.Produtos, campos, agregacoes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var chaves []string
+		for _, grupo := range grupos {
+			chaves = append(chaves, grupo.Chave[len(campos)-1])
+		}
+		quero := "-3 9 20 100"
+		if campos[0] == "brand" {
+			quero = "-0.30 0.90 2.00 10.00"
+		}
+		if strings.Join(chaves, " ") != quero {
+			t.Errorf("grupos por %v = %v, quero %s", campos, chaves, quero)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// Arquivo é o que o banco usa de um arquivo aberto. *os.File já satisfaz a interface.
+type Arquivo interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+	io.Writer
+	io.WriterAt
+	io.Closer
+	Name() string
+	Stat() (fs.FileInfo, error)
+	Sync() error
+	Truncate(tamanho int64) error
+}
+
+// Armazenamento abre e manipula os arquivos de dados e de índice pelo nome. As flags
+// e permissões de Abrir são as de os.OpenFile, e os erros de arquivo inexistente
+// satisfazem errors.Is(err, os.ErrNotExist), como os do pacote os.
+type Armazenamento interface {
+	Abrir(nome string, flag int, perm fs.FileMode) (Arquivo, error)
+	Info(nome string) (fs.FileInfo, error)
+	Renomear(antigo string, novo string) error
+	Remover(nome string) error
+	Truncar(nome string, tamanho int64) error
+	AlterarData(nome string, data time.Time) error
+}
+
+// armazenamentoOS usa o sistema de arquivos do sistema operacional.
+type armazenamentoOS struct{}
+
+func (armazenamentoOS) Abrir(nome string, flag int, perm fs.FileMode) (Arquivo, error) {
+	file, err := os.OpenFile(nome, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (armazenamentoOS) Info(nome string) (fs.FileInfo, error) {
+	return os.Stat(nome)
+}
+
+func (armazenamentoOS) Renomear(antigo string, novo string) error {
+	return os.Rename(antigo, novo)
+}
+
+func (armazenamentoOS) Remover(nome string) error {
+	return os.Remove(nome)
+}
+
+func (armazenamentoOS) Truncar(nome string, tamanho int64) error {
+	return os.Truncate(nome, tamanho)
+}
+
+func (armazenamentoOS) AlterarData(nome string, data time.Time) error {
+	return os.Chtimes(nome, data, data)
+}
+
+// abrirArquivo abre nome só para leitura, como os.Open.
+func abrirArquivo(armazenamento Armazenamento, nome string) (Arquivo, error) {
+	return armazenamento.Abrir(nome, os.O_RDONLY, 0)
+}
+
+// criarArquivo cria nome, ou o esvazia se já existir, como os.Create.
+func criarArquivo(armazenamento Armazenamento, nome string) (Arquivo, error) {
+	return armazenamento.Abrir(nome, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+}
+
+// lerArquivoInteiro devolve o conteúdo de nome, como os.ReadFile.
+func lerArquivoInteiro(armazenamento Armazenamento, nome string) ([]byte, error) {
+	file, err := abrirArquivo(armazenamento, nome)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	dados, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler o arquivo %s: %w", nome, err)
+	}
+	return dados, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io/fs"
+	"sync/atomic"
+)
+
+// armazenamentoContador repassa tudo a outro armazenamento e conta quantas leituras
+// foram feitas nos arquivos abertos e quantos bytes elas trouxeram.
+type armazenamentoContador struct {
+	Armazenamento
+	leituras atomic.Int64
+	bytes    atomic.Int64
+}
+
+func novoArmazenamentoContador(base Armazenamento) *armazenamentoContador {
+	return &armazenamentoContador{Armazenamento: base}
+}
+
+func (c *armazenamentoContador) Abrir(nome string, flag int, perm fs.FileMode) (Arquivo, error) {
+	file, err := c.Armazenamento.Abrir(nome, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &arquivoContador{Arquivo: file, contador: c}, nil
+}
+
+// zerar recomeça a contagem e devolve as leituras e os bytes contados até aqui.
+func (c *armazenamentoContador) zerar() (int64, int64) {
+	return c.leituras.Swap(0), c.bytes.Swap(0)
+}
+
+type arquivoContador struct {
+	Arquivo
+	contador *armazenamentoContador
+}
+
+func (a *arquivoContador) Read(b []byte) (int, error) {
+	n, err := a.Arquivo.Read(b)
+	a.contador.leituras.Add(1)
+	a.contador.bytes.Add(int64(n))
+	return n, err
+}
+
+func (a *arquivoContador) ReadAt(b []byte, offset int64) (int, error) {
+	n, err := a.Arquivo.ReadAt(b, offset)
+	a.contador.leituras.Add(1)
+	a.contador.bytes.Add(int64(n))
+	return n, err
+}
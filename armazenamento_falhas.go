@@ -0,0 +1,254 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// errFalhaInjetada é o erro das operações que armazenamentoFalhas faz falhar.
+var errFalhaInjetada = errors.New("falha injetada")
+
+// armazenamentoFalhas envolve um armazenamentoMemoria para simular disco cheio, escritas
+// interrompidas no meio, renomeações que falham e quedas do sistema. Para a queda, ele
+// separa o que o processo enxerga do que já chegou ao disco: o conteúdo de um arquivo só
+// fica durável depois de Sync, enquanto criar, renomear, remover e truncar valem assim
+// que são feitos.
+type armazenamentoFalhas struct {
+	base *armazenamentoMemoria
+
+	mu sync.Mutex
+	// limite é quantos bytes ainda podem ser escritos antes de as escritas falharem;
+	// -1 desliga a falha.
+	limite int64
+	// escritaParcial faz a escrita que passa do limite gravar o que cabe antes de
+	// falhar, em vez de não gravar nada.
+	escritaParcial bool
+	// renomeacoesAteFalha é quantas renomeações ainda dão certo; -1 desliga a falha.
+	renomeacoesAteFalha int
+	escritos            int64
+	renomeacoes         int
+	// duravel guarda, por arquivo, o conteúdo do último Sync.
+	duravel map[*conteudoMemoria][]byte
+}
+
+// novoArmazenamentoFalhas começa sem falhas armadas e considera durável tudo o que já
+// está em base.
+func novoArmazenamentoFalhas(base *armazenamentoMemoria) *armazenamentoFalhas {
+	f := &armazenamentoFalhas{
+		base:                base,
+		limite:              -1,
+		renomeacoesAteFalha: -1,
+		duravel:             make(map[*conteudoMemoria][]byte),
+	}
+
+	base.mu.Lock()
+	defer base.mu.Unlock()
+	for _, conteudo := range base.arquivos {
+		f.duravel[conteudo] = slices.Clone(conteudo.dados)
+	}
+	return f
+}
+
+// armar faz as escritas falharem depois de limite bytes e a renomeação de número
+// renomeacoes (contando de zero) falhar. Use -1 para desligar cada falha. Os contadores
+// de bytes escritos e de renomeações recomeçam do zero.
+func (f *armazenamentoFalhas) armar(limite int64, escritaParcial bool, renomeacoes int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.limite = limite
+	f.escritaParcial = escritaParcial
+	f.renomeacoesAteFalha = renomeacoes
+	f.escritos = 0
+	f.renomeacoes = 0
+}
+
+// desarmar desliga as falhas, mantendo os contadores.
+func (f *armazenamentoFalhas) desarmar() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.limite = -1
+	f.renomeacoesAteFalha = -1
+}
+
+// contadores devolve quantos bytes foram escritos e quantas renomeações foram feitas
+// desde o último armar.
+func (f *armazenamentoFalhas) contadores() (int64, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.escritos, f.renomeacoes
+}
+
+// queda devolve o que sobraria no disco se o sistema caísse agora: os mesmos arquivos,
+// com o conteúdo do último Sync de cada um.
+func (f *armazenamentoFalhas) queda() *armazenamentoMemoria {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.base.mu.Lock()
+	defer f.base.mu.Unlock()
+
+	m := novoArmazenamentoMemoria()
+	for nome, conteudo := range f.base.arquivos {
+		m.arquivos[nome] = &conteudoMemoria{
+			dados:      slices.Clone(f.duravel[conteudo]),
+			modificado: conteudo.modificado,
+		}
+	}
+	return m
+}
+
+// reservarEscrita desconta n bytes do limite e devolve quantos deles podem ser gravados.
+func (f *armazenamentoFalhas) reservarEscrita(n int) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.limite < 0 || int64(n) <= f.limite {
+		if f.limite >= 0 {
+			f.limite -= int64(n)
+		}
+		f.escritos += int64(n)
+		return n, nil
+	}
+
+	parcial := 0
+	if f.escritaParcial {
+		parcial = int(f.limite)
+	}
+	f.limite = 0
+	f.escritos += int64(parcial)
+	return parcial, errFalhaInjetada
+}
+
+func (f *armazenamentoFalhas) sincronizar(conteudo *conteudoMemoria) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.base.mu.Lock()
+	defer f.base.mu.Unlock()
+
+	f.duravel[conteudo] = slices.Clone(conteudo.dados)
+}
+
+// truncarDuravel aplica ao conteúdo durável um truncamento já feito no arquivo.
+func (f *armazenamentoFalhas) truncarDuravel(conteudo *conteudoMemoria, tamanho int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dados := f.duravel[conteudo]
+	if tamanho < int64(len(dados)) {
+		f.duravel[conteudo] = dados[:tamanho]
+	} else {
+		f.duravel[conteudo] = append(dados, make([]byte, tamanho-int64(len(dados)))...)
+	}
+}
+
+func (f *armazenamentoFalhas) conteudo(nome string) *conteudoMemoria {
+	f.base.mu.Lock()
+	defer f.base.mu.Unlock()
+	return f.base.arquivos[filepath.Clean(nome)]
+}
+
+func (f *armazenamentoFalhas) Abrir(nome string, flag int, perm fs.FileMode) (Arquivo, error) {
+	file, err := f.base.Abrir(nome, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	arquivo := &arquivoFalhas{Arquivo: file, armazenamento: f, conteudo: file.(*arquivoMemoria).conteudo}
+	if flag&os.O_TRUNC != 0 && flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		f.truncarDuravel(arquivo.conteudo, 0)
+	}
+	return arquivo, nil
+}
+
+func (f *armazenamentoFalhas) Info(nome string) (fs.FileInfo, error) {
+	return f.base.Info(nome)
+}
+
+func (f *armazenamentoFalhas) Renomear(antigo string, novo string) error {
+	f.mu.Lock()
+	falhar := f.renomeacoesAteFalha == 0
+	if f.renomeacoesAteFalha > 0 {
+		f.renomeacoesAteFalha--
+	}
+	f.renomeacoes++
+	f.mu.Unlock()
+
+	if falhar {
+		return &os.LinkError{Op: "rename", Old: antigo, New: novo, Err: errFalhaInjetada}
+	}
+	return f.base.Renomear(antigo, novo)
+}
+
+func (f *armazenamentoFalhas) Remover(nome string) error {
+	return f.base.Remover(nome)
+}
+
+func (f *armazenamentoFalhas) Truncar(nome string, tamanho int64) error {
+	conteudo := f.conteudo(nome)
+	err := f.base.Truncar(nome, tamanho)
+	if err != nil {
+		return err
+	}
+	f.truncarDuravel(conteudo, tamanho)
+	return nil
+}
+
+func (f *armazenamentoFalhas) AlterarData(nome string, data time.Time) error {
+	return f.base.AlterarData(nome, data)
+}
+
+// arquivoFalhas repassa as operações ao arquivo em memória, aplicando as falhas armadas
+// nas escritas e registrando o conteúdo durável a cada Sync.
+type arquivoFalhas struct {
+	Arquivo
+	armazenamento *armazenamentoFalhas
+	conteudo      *conteudoMemoria
+}
+
+func (a *arquivoFalhas) Write(b []byte) (int, error) {
+	n, errFalha := a.armazenamento.reservarEscrita(len(b))
+	escritos, err := a.Arquivo.Write(b[:n])
+	if err != nil {
+		return escritos, err
+	}
+	if errFalha != nil {
+		return escritos, &fs.PathError{Op: "write", Path: a.Name(), Err: errFalha}
+	}
+	return escritos, nil
+}
+
+func (a *arquivoFalhas) WriteAt(b []byte, offset int64) (int, error) {
+	n, errFalha := a.armazenamento.reservarEscrita(len(b))
+	escritos, err := a.Arquivo.WriteAt(b[:n], offset)
+	if err != nil {
+		return escritos, err
+	}
+	if errFalha != nil {
+		return escritos, &fs.PathError{Op: "write", Path: a.Name(), Err: errFalha}
+	}
+	return escritos, nil
+}
+
+func (a *arquivoFalhas) Sync() error {
+	err := a.Arquivo.Sync()
+	if err != nil {
+		return err
+	}
+	a.armazenamento.sincronizar(a.conteudo)
+	return nil
+}
+
+func (a *arquivoFalhas) Truncate(tamanho int64) error {
+	err := a.Arquivo.Truncate(tamanho)
+	if err != nil {
+		return err
+	}
+	a.armazenamento.truncarDuravel(a.conteudo, tamanho)
+	return nil
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// armazenamentoMemoria guarda os arquivos num mapa, sem tocar no disco. Como no
+// sistema operacional, um arquivo aberto continua válido depois de renomeado ou
+// removido, e quem o abrir pelo nome de novo vê o conteúdo atual.
+type armazenamentoMemoria struct {
+	mu       sync.Mutex
+	arquivos map[string]*conteudoMemoria
+}
+
+type conteudoMemoria struct {
+	dados      []byte
+	modificado time.Time
+}
+
+func novoArmazenamentoMemoria() *armazenamentoMemoria {
+	return &armazenamentoMemoria{arquivos: make(map[string]*conteudoMemoria)}
+}
+
+// copiar devolve um armazenamento independente com o mesmo conteúdo de m.
+func (m *armazenamentoMemoria) copiar() *armazenamentoMemoria {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	copia := novoArmazenamentoMemoria()
+	for nome, conteudo := range m.arquivos {
+		copia.arquivos[nome] = &conteudoMemoria{dados: slices.Clone(conteudo.dados), modificado: conteudo.modificado}
+	}
+	return copia
+}
+
+func (m *armazenamentoMemoria) Abrir(nome string, flag int, perm fs.FileMode) (Arquivo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	nome = filepath.Clean(nome)
+	conteudo, existe := m.arquivos[nome]
+	switch {
+	case existe && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &fs.PathError{Op: "open", Path: nome, Err: fs.ErrExist}
+	case !existe && flag&os.O_CREATE == 0:
+		return nil, &fs.PathError{Op: "open", Path: nome, Err: fs.ErrNotExist}
+	case !existe:
+		conteudo = &conteudoMemoria{modificado: time.Now()}
+		m.arquivos[nome] = conteudo
+	}
+
+	f := &arquivoMemoria{armazenamento: m, nome: nome, conteudo: conteudo, flag: flag}
+	if flag&os.O_TRUNC != 0 && f.gravavel() {
+		conteudo.dados = nil
+		conteudo.modificado = time.Now()
+	}
+	return f, nil
+}
+
+func (m *armazenamentoMemoria) Info(nome string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	nome = filepath.Clean(nome)
+	conteudo, existe := m.arquivos[nome]
+	if !existe {
+		return nil, &fs.PathError{Op: "stat", Path: nome, Err: fs.ErrNotExist}
+	}
+	return conteudo.info(nome), nil
+}
+
+func (m *armazenamentoMemoria) Renomear(antigo string, novo string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	antigo, novo = filepath.Clean(antigo), filepath.Clean(novo)
+	conteudo, existe := m.arquivos[antigo]
+	if !existe {
+		return &os.LinkError{Op: "rename", Old: antigo, New: novo, Err: fs.ErrNotExist}
+	}
+	delete(m.arquivos, antigo)
+	m.arquivos[novo] = conteudo
+	return nil
+}
+
+func (m *armazenamentoMemoria) Remover(nome string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	nome = filepath.Clean(nome)
+	if _, existe := m.arquivos[nome]; !existe {
+		return &fs.PathError{Op: "remove", Path: nome, Err: fs.ErrNotExist}
+	}
+	delete(m.arquivos, nome)
+	return nil
+}
+
+func (m *armazenamentoMemoria) Truncar(nome string, tamanho int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	nome = filepath.Clean(nome)
+	conteudo, existe := m.arquivos[nome]
+	if !existe {
+		return &fs.PathError{Op: "truncate", Path: nome, Err: fs.ErrNotExist}
+	}
+	return conteudo.truncar(nome, tamanho)
+}
+
+func (m *armazenamentoMemoria) AlterarData(nome string, data time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	nome = filepath.Clean(nome)
+	conteudo, existe := m.arquivos[nome]
+	if !existe {
+		return &fs.PathError{Op: "chtimes", Path: nome, Err: fs.ErrNotExist}
+	}
+	conteudo.modificado = data
+	return nil
+}
+
+func (c *conteudoMemoria) info(nome string) fs.FileInfo {
+	return infoMemoria{nome: filepath.Base(nome), tamanho: int64(len(c.dados)), modificado: c.modificado}
+}
+
+func (c *conteudoMemoria) truncar(nome string, tamanho int64) error {
+	if tamanho < 0 {
+		return &fs.PathError{Op: "truncate", Path: nome, Err: fs.ErrInvalid}
+	}
+	if tamanho <= int64(len(c.dados)) {
+		c.dados = c.dados[:tamanho]
+	} else {
+		c.dados = append(c.dados, make([]byte, tamanho-int64(len(c.dados)))...)
+	}
+	c.modificado = time.Now()
+	return nil
+}
+
+// escrever grava b em offset, completando com zeros se offset passar do fim.
+func (c *conteudoMemoria) escrever(b []byte, offset int64) {
+	if fim := offset + int64(len(b)); fim > int64(len(c.dados)) {
+		c.dados = append(c.dados, make([]byte, fim-int64(len(c.dados)))...)
+	}
+	copy(c.dados[offset:], b)
+	c.modificado = time.Now()
+}
+
+// arquivoMemoria é um arquivo aberto de armazenamentoMemoria, com sua própria posição
+// de leitura e escrita.
+type arquivoMemoria struct {
+	armazenamento *armazenamentoMemoria
+	nome          string
+	conteudo      *conteudoMemoria
+	flag          int
+	posicao       int64
+	fechado       bool
+}
+
+var errEscritaEmAppend = errors.New("WriteAt em arquivo aberto com O_APPEND")
+
+func (f *arquivoMemoria) legivel() bool {
+	return f.flag&os.O_WRONLY == 0
+}
+
+func (f *arquivoMemoria) gravavel() bool {
+	return f.flag&(os.O_WRONLY|os.O_RDWR) != 0
+}
+
+// conferir devolve o erro de uma operação op que não pode ser feita no arquivo.
+func (f *arquivoMemoria) conferir(op string, escrita bool) error {
+	switch {
+	case f.fechado:
+		return &fs.PathError{Op: op, Path: f.nome, Err: fs.ErrClosed}
+	case escrita && !f.gravavel(), !escrita && !f.legivel():
+		return &fs.PathError{Op: op, Path: f.nome, Err: fs.ErrPermission}
+	}
+	return nil
+}
+
+func (f *arquivoMemoria) Read(b []byte) (int, error) {
+	f.armazenamento.mu.Lock()
+	defer f.armazenamento.mu.Unlock()
+
+	if err := f.conferir("read", false); err != nil {
+		return 0, err
+	}
+	if f.posicao >= int64(len(f.conteudo.dados)) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.conteudo.dados[f.posicao:])
+	f.posicao += int64(n)
+	return n, nil
+}
+
+func (f *arquivoMemoria) ReadAt(b []byte, offset int64) (int, error) {
+	f.armazenamento.mu.Lock()
+	defer f.armazenamento.mu.Unlock()
+
+	if err := f.conferir("read", false); err != nil {
+		return 0, err
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "readat", Path: f.nome, Err: fs.ErrInvalid}
+	}
+	if offset >= int64(len(f.conteudo.dados)) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.conteudo.dados[offset:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *arquivoMemoria) Write(b []byte) (int, error) {
+	f.armazenamento.mu.Lock()
+	defer f.armazenamento.mu.Unlock()
+
+	if err := f.conferir("write", true); err != nil {
+		return 0, err
+	}
+	if f.flag&os.O_APPEND != 0 {
+		f.posicao = int64(len(f.conteudo.dados))
+	}
+	f.conteudo.escrever(b, f.posicao)
+	f.posicao += int64(len(b))
+	return len(b), nil
+}
+
+func (f *arquivoMemoria) WriteAt(b []byte, offset int64) (int, error) {
+	f.armazenamento.mu.Lock()
+	defer f.armazenamento.mu.Unlock()
+
+	if err := f.conferir("write", true); err != nil {
+		return 0, err
+	}
+	if f.flag&os.O_APPEND != 0 {
+		return 0, errEscritaEmAppend
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "writeat", Path: f.nome, Err: fs.ErrInvalid}
+	}
+	f.conteudo.escrever(b, offset)
+	return len(b), nil
+}
+
+func (f *arquivoMemoria) Seek(offset int64, whence int) (int64, error) {
+	f.armazenamento.mu.Lock()
+	defer f.armazenamento.mu.Unlock()
+
+	if f.fechado {
+		return 0, &fs.PathError{Op: "seek", Path: f.nome, Err: fs.ErrClosed}
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.posicao
+	case io.SeekEnd:
+		offset += int64(len(f.conteudo.dados))
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.nome, Err: fs.ErrInvalid}
+	}
+	f.posicao = offset
+	return offset, nil
+}
+
+func (f *arquivoMemoria) Close() error {
+	f.armazenamento.mu.Lock()
+	defer f.armazenamento.mu.Unlock()
+
+	if f.fechado {
+		return &fs.PathError{Op: "close", Path: f.nome, Err: fs.ErrClosed}
+	}
+	f.fechado = true
+	return nil
+}
+
+func (f *arquivoMemoria) Name() string {
+	return f.nome
+}
+
+func (f *arquivoMemoria) Stat() (fs.FileInfo, error) {
+	f.armazenamento.mu.Lock()
+	defer f.armazenamento.mu.Unlock()
+
+	if f.fechado {
+		return nil, &fs.PathError{Op: "stat", Path: f.nome, Err: fs.ErrClosed}
+	}
+	return f.conteudo.info(f.nome), nil
+}
+
+// Sync não tem o que fazer: em memória, toda escrita já é definitiva.
+func (f *arquivoMemoria) Sync() error {
+	f.armazenamento.mu.Lock()
+	defer f.armazenamento.mu.Unlock()
+
+	if f.fechado {
+		return &fs.PathError{Op: "sync", Path: f.nome, Err: fs.ErrClosed}
+	}
+	return nil
+}
+
+func (f *arquivoMemoria) Truncate(tamanho int64) error {
+	f.armazenamento.mu.Lock()
+	defer f.armazenamento.mu.Unlock()
+
+	if err := f.conferir("truncate", true); err != nil {
+		return err
+	}
+	return f.conteudo.truncar(f.nome, tamanho)
+}
+
+type infoMemoria struct {
+	nome       string
+	tamanho    int64
+	modificado time.Time
+}
+
+func (i infoMemoria) Name() string       { return i.nome }
+func (i infoMemoria) Size() int64        { return i.tamanho }
+func (i infoMemoria) Mode() fs.FileMode  { return 0644 }
+func (i infoMemoria) ModTime() time.Time { return i.modificado }
+func (i infoMemoria) IsDir() bool        { return false }
+func (i infoMemoria) Sys() any           { return nil }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"testing"
+)
+
+// Cada banco usa o armazenamento dos seus Arquivos: um banco em memória e outro em disco
+// podem ser usados ao mesmo tempo sem que um veja os arquivos do outro.
+func TestArmazenamentoPorBanco(t *testing.T) {
+	dir := t.TempDir()
+	emDisco := abrirBancoTeste(t)
+	arquivos := arquivosNoDiretorio(dir)
+	arquivos.Armazenamento = novoArmazenamentoMemoria()
+	emMemoria, err := abrirBanco(arquivos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer emMemoria.fechar()
+
+	var wg sync.WaitGroup
+	for _, b := range []*Banco{emDisco, emMemoria} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 20 {
+				if _, err := b.inserirProduto(produtoTeste(t, int32(i), Preco(i), "m")); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, b := range []*Banco{emDisco, emMemoria} {
+		produtos, err := b.listarProdutos(1, 100, 100, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(produtos) != 20 {
+			t.Errorf("%d produtos em %s, quero 20", len(produtos), b.arquivos.Produtos)
+		}
+	}
+
+	entradas, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entradas) > 0 {
+		t.Errorf("o banco em memória criou %d arquivo(s) em disco", len(entradas))
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// gravarAtomicamente escreve nome por meio de um arquivo temporário ao lado dele, que
+// só substitui o original depois de completo e sincronizado com o disco. Quem lê nome
+// vê o conteúdo antigo ou o novo, nunca um arquivo pela metade.
+func gravarAtomicamente(armazenamento Armazenamento, nome string, escrever func(w io.Writer) error) error {
+	temp := nome + ".tmp"
+	file, err := criarArquivo(armazenamento, temp)
+	if err != nil {
+		return fmt.Errorf("erro ao criar o arquivo temporário de %s: %w", nome, err)
+	}
+
+	writer := bufio.NewWriter(file)
+	err = escrever(writer)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if errClose := file.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		armazenamento.Remover(temp)
+		return fmt.Errorf("erro ao gravar %s: %w", nome, err)
+	}
+
+	err = armazenamento.Renomear(temp, nome)
+	if err != nil {
+		armazenamento.Remover(temp)
+		return fmt.Errorf("erro ao renomear o arquivo temporário de %s: %w", nome, err)
+	}
+	return nil
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+type Arquivos struct {
+	Produtos       string
+	Acessos        string
+	IndiceProdutos string
+	IndiceAcessos  string
+	IndicePrecos   string
+	// Armazenamento é por onde passa toda a E/S dos arquivos acima. Com
+	// novoArmazenamentoMemoria(), o banco inteiro funciona sem tocar no disco.
+	Armazenamento Armazenamento
+}
+
+func arquivosPadrao() Arquivos {
+	return Arquivos{
+		Produtos:       "produtos.bin",
+		Acessos:        "acessos.bin",
+		IndiceProdutos: "indice_produtos.dat",
+		IndiceAcessos:  "indice_acessos.dat",
+		IndicePrecos:   "indice_precos.dat",
+		Armazenamento:  armazenamentoOS{},
+	}
+}
+
+// arquivosNoDiretorio devolve os arquivos com os nomes padrão dentro de dir.
+func arquivosNoDiretorio(dir string) Arquivos {
+	arquivos := arquivosPadrao()
+	for _, nome := range []*string{&arquivos.Produtos, &arquivos.Acessos, &arquivos.IndiceProdutos, &arquivos.IndiceAcessos, &arquivos.IndicePrecos} {
+		*nome = filepath.Join(dir, *nome)
+	}
+	return arquivos
+}
+
+// Banco reúne os arquivos de dados e índices e serializa as escritas, para que
+// vários leitores (por exemplo, requisições HTTP) possam usá-los ao mesmo tempo.
+type Banco struct {
+	arquivos Arquivos
+	mu       sync.RWMutex
+	// mapas é nil quando o banco lê os arquivos com chamadas de sistema comuns.
+	mapas *mapasBanco
+}
+
+func abrirBanco(arquivos Arquivos) (*Banco, error) {
+	for _, nome := range []string{arquivos.Produtos, arquivos.Acessos} {
+		file, err := arquivos.Armazenamento.Abrir(nome, os.O_RDONLY|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao abrir o arquivo %s: %w", nome, err)
+		}
+		file.Close()
+	}
+
+	if err := conferirFormatoPrecos(arquivos.Armazenamento, arquivos.Produtos); err != nil {
+		return nil, err
+	}
+
+	if err := aplicarDiario(arquivos.Armazenamento, arquivos.Produtos, tamanhoProduto); err != nil {
+		return nil, err
+	}
+	if err := aplicarDiario(arquivos.Armazenamento, arquivos.Acessos, tamanhoAcesso); err != nil {
+		return nil, err
+	}
+
+	// Índices ausentes, mais antigos que os dados ou inconsistentes são recriados aqui.
+	if err := repararIndices(arquivos); err != nil {
+		return nil, err
+	}
+	return &Banco{arquivos: arquivos}, nil
+}
+
+// abrirBancoMapeado abre o banco mantendo os arquivos de dados e os índices de ID
+// mapeados em memória, para que as consultas por ID não façam chamadas de sistema.
+func abrirBancoMapeado(arquivos Arquivos) (*Banco, error) {
+	b, err := abrirBanco(arquivos)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mapas, err = mapearBanco(arquivos)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *Banco) fechar() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.mapas == nil {
+		return nil
+	}
+	err := b.mapas.fechar()
+	b.mapas = nil
+	return err
+}
+
+func (b *Banco) travarEscrita() {
+	b.mu.Lock()
+}
+
+// liberarEscrita refaz os mapeamentos antes de soltar a trava, já que a escrita pode
+// ter aumentado os arquivos ou trocado os índices. Se o novo mapeamento falhar, o banco
+// volta a ler pelos arquivos.
+func (b *Banco) liberarEscrita() {
+	defer b.mu.Unlock()
+
+	if b.mapas == nil {
+		return
+	}
+	b.mapas.fechar()
+
+	var err error
+	b.mapas, err = mapearBanco(b.arquivos)
+	if err != nil {
+		log.Printf("erro ao remapear o banco, lendo pelos arquivos: %v", err)
+		b.mapas = nil
+	}
+}
+
+// reindexar recria todos os índices do banco.
+func (b *Banco) reindexar() error {
+	b.travarEscrita()
+	defer b.liberarEscrita()
+	return reindexar(b.arquivos)
+}
+
+func (b *Banco) consultarProduto(id int32) (Produto, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.mapas != nil {
+		return b.mapas.consultarProduto(id)
+	}
+	return consultarProdutoComIndice(b.arquivos.Armazenamento, b.arquivos.IndiceProdutos, b.arquivos.Produtos, id)
+}
+
+func (b *Banco) consultarAcesso(id int32) (Acesso, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.mapas != nil {
+		return b.mapas.consultarAcesso(id)
+	}
+	return consultarAcessoComIndice(b.arquivos.Armazenamento, b.arquivos.IndiceAcessos, b.arquivos.Acessos, id)
+}
+
+// consultarProdutos resolve vários IDs de uma vez: o resultado segue a ordem de ids,
+// com nil para os IDs ausentes, que também são devolvidos à parte.
+func (b *Banco) consultarProdutos(ids []int32) ([]*Produto, []int32, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.mapas != nil {
+		return b.mapas.consultarProdutos(ids)
+	}
+	return consultarVariosProdutos(b.arquivos.Armazenamento, b.arquivos.IndiceProdutos, b.arquivos.Produtos, ids)
+}
+
+func (b *Banco) consultarAcessos(ids []int32) ([]*Acesso, []int32, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.mapas != nil {
+		return b.mapas.consultarAcessos(ids)
+	}
+	return consultarVariosAcessos(b.arquivos.Armazenamento, b.arquivos.IndiceAcessos, b.arquivos.Acessos, ids)
+}
+
+// listarProdutos percorre os produtos a partir de idInicial, em ordem de ID, e devolve
+// até limite produtos com ID <= idFinal aceitos pelo filtro.
+func (b *Banco) listarProdutos(idInicial int32, idFinal int32, limite int, filtro func(*Produto) bool) ([]Produto, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	produtos := []Produto{}
+	if limite <= 0 {
+		return produtos, nil
+	}
+	err := percorrerProdutosComIndice(b.arquivos.Armazenamento, b.arquivos.IndiceProdutos, b.arquivos.Produtos, idInicial, func(produto Produto) bool {
+		if bytesToInt32(produto.ID) > idFinal {
+			return false
+		}
+		if filtro == nil || filtro(&produto) {
+			produtos = append(produtos, produto)
+		}
+		return len(produtos) < limite
+	})
+	return produtos, err
+}
+
+func (b *Banco) listarAcessos(idInicial int32, idFinal int32, limite int, filtro func(*Acesso) bool) ([]Acesso, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	acessos := []Acesso{}
+	if limite <= 0 {
+		return acessos, nil
+	}
+	err := percorrerAcessosComIndice(b.arquivos.Armazenamento, b.arquivos.IndiceAcessos, b.arquivos.Acessos, idInicial, func(acesso Acesso) bool {
+		if bytesToInt32(acesso.ID) > idFinal {
+			return false
+		}
+		if filtro == nil || filtro(&acesso) {
+			acessos = append(acessos, acesso)
+		}
+		return len(acessos) < limite
+	})
+	return acessos, err
+}
+
+// inserirProduto grava o produto com o próximo ID da sequência e devolve o registro gravado.
+func (b *Banco) inserirProduto(produto Produto) (Produto, error) {
+	b.travarEscrita()
+	defer b.liberarEscrita()
+
+	return inserirProdutoECriarIndice(b.arquivos.Armazenamento, b.arquivos.Produtos, b.arquivos.IndiceProdutos, b.arquivos.IndicePrecos, produto)
+}
+
+func (b *Banco) inserirAcesso(acesso Acesso) (Acesso, error) {
+	b.travarEscrita()
+	defer b.liberarEscrita()
+
+	acesso, err := inserirAcessoECriarIndice(b.arquivos.Armazenamento, b.arquivos.Acessos, b.arquivos.IndiceAcessos, acesso)
+	if err != nil {
+		return Acesso{}, err
+	}
+
+	return acesso, nil
+}
+
+func (b *Banco) atualizarProduto(produto Produto) error {
+	b.travarEscrita()
+	defer b.liberarEscrita()
+
+	return atualizarProduto(b.arquivos.Armazenamento, b.arquivos.IndiceProdutos, b.arquivos.IndicePrecos, b.arquivos.Produtos, produto)
+}
+
+func (b *Banco) atualizarAcesso(acesso Acesso) error {
+	b.travarEscrita()
+	defer b.liberarEscrita()
+	return atualizarAcesso(b.arquivos.Armazenamento, b.arquivos.IndiceAcessos, b.arquivos.Acessos, acesso)
+}
+
+// salvarProduto atualiza o produto se o ID já existir; senão, insere o produto com esse
+// ID, desde que ele seja maior que todos os IDs já entregues pela sequência.
+func (b *Banco) salvarProduto(produto Produto) error {
+	b.travarEscrita()
+	defer b.liberarEscrita()
+
+	id := bytesToInt32(produto.ID)
+	_, err := offsetNoIndice(b.arquivos.Armazenamento, b.arquivos.IndiceProdutos, id)
+	if err == nil {
+		return atualizarProduto(b.arquivos.Armazenamento, b.arquivos.IndiceProdutos, b.arquivos.IndicePrecos, b.arquivos.Produtos, produto)
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return inserirProdutoComIDEIndices(b.arquivos, produto)
+}
+
+// inserirProdutoComID insere o produto com o ID que ele já traz. Um ID já entregue pela
+// sequência, exista ou não o produto, é recusado com ErrDuplicateID.
+func (b *Banco) inserirProdutoComID(produto Produto) error {
+	b.travarEscrita()
+	defer b.liberarEscrita()
+	return inserirProdutoComIDEIndices(b.arquivos, produto)
+}
+
+func inserirProdutoComIDEIndices(arquivos Arquivos, produto Produto) error {
+	offset, err := tamanhoArquivo(arquivos.Armazenamento, arquivos.Produtos)
+	if err != nil {
+		return err
+	}
+
+	err = inserirProdutoComID(arquivos.Armazenamento, arquivos.Produtos, produto)
+	if err != nil {
+		return err
+	}
+
+	err = adicionarAoIndice(arquivos.Armazenamento, arquivos.IndiceProdutos, produto.ID, offset)
+	if err != nil {
+		return err
+	}
+
+	return adicionarAoIndicePrecos(arquivos.Armazenamento, arquivos.IndicePrecos, produto.Price, offset)
+}
+
+func (b *Banco) salvarAcesso(acesso Acesso) error {
+	b.travarEscrita()
+	defer b.liberarEscrita()
+
+	id := bytesToInt32(acesso.ID)
+	_, err := offsetNoIndice(b.arquivos.Armazenamento, b.arquivos.IndiceAcessos, id)
+	if err == nil {
+		return atualizarAcesso(b.arquivos.Armazenamento, b.arquivos.IndiceAcessos, b.arquivos.Acessos, acesso)
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return inserirAcessoComIDEIndice(b.arquivos, acesso)
+}
+
+func (b *Banco) inserirAcessoComID(acesso Acesso) error {
+	b.travarEscrita()
+	defer b.liberarEscrita()
+	return inserirAcessoComIDEIndice(b.arquivos, acesso)
+}
+
+func inserirAcessoComIDEIndice(arquivos Arquivos, acesso Acesso) error {
+	offset, err := tamanhoArquivo(arquivos.Armazenamento, arquivos.Acessos)
+	if err != nil {
+		return err
+	}
+
+	err = inserirAcessoComID(arquivos.Armazenamento, arquivos.Acessos, acesso)
+	if err != nil {
+		return err
+	}
+	return adicionarAoIndice(arquivos.Armazenamento, arquivos.IndiceAcessos, acesso.ID, offset)
+}
+
+func (b *Banco) removerProduto(id int32) error {
+	return b.removerProdutos([]int32{id})
+}
+
+func (b *Banco) removerAcesso(id int32) error {
+	return b.removerAcessos([]int32{id})
+}
+
+// inserirProdutos grava todos os produtos ou nenhum, com IDs consecutivos da sequência.
+func (b *Banco) inserirProdutos(produtos []Produto) ([]Produto, error) {
+	b.travarEscrita()
+	defer b.liberarEscrita()
+
+	return inserirProdutosEmLote(b.arquivos.Armazenamento, b.arquivos.Produtos, b.arquivos.IndiceProdutos, b.arquivos.IndicePrecos, produtos)
+}
+
+func (b *Banco) inserirAcessos(acessos []Acesso) ([]Acesso, error) {
+	b.travarEscrita()
+	defer b.liberarEscrita()
+	return inserirAcessosEmLote(b.arquivos.Armazenamento, b.arquivos.Acessos, b.arquivos.IndiceAcessos, acessos)
+}
+
+// removerProdutos remove todos os IDs ou, se algum não existir, nenhum.
+func (b *Banco) removerProdutos(ids []int32) error {
+	b.travarEscrita()
+	defer b.liberarEscrita()
+
+	return removerProdutosEmLote(b.arquivos.Armazenamento, b.arquivos.Produtos, b.arquivos.IndiceProdutos, b.arquivos.IndicePrecos, ids)
+}
+
+func (b *Banco) removerAcessos(ids []int32) error {
+	b.travarEscrita()
+	defer b.liberarEscrita()
+	return removerAcessosEmLote(b.arquivos.Armazenamento, b.arquivos.Acessos, b.arquivos.IndiceAcessos, ids)
+}
+
+// removerProdutosExistentes remove, numa única reescrita do arquivo, os IDs que existirem
+// e ignora os demais. Devolve quantos IDs distintos foram removidos.
+func (b *Banco) removerProdutosExistentes(ids []int32) (int, error) {
+	b.travarEscrita()
+	defer b.liberarEscrita()
+
+	existentes, err := idsNoIndice(b.arquivos.Armazenamento, b.arquivos.IndiceProdutos, ids)
+	if err != nil {
+		return 0, err
+	}
+	if len(existentes) == 0 {
+		return 0, nil
+	}
+
+	return len(existentes), removerProdutosEmLote(b.arquivos.Armazenamento, b.arquivos.Produtos, b.arquivos.IndiceProdutos, b.arquivos.IndicePrecos, existentes)
+}
+
+func (b *Banco) removerAcessosExistentes(ids []int32) (int, error) {
+	b.travarEscrita()
+	defer b.liberarEscrita()
+
+	existentes, err := idsNoIndice(b.arquivos.Armazenamento, b.arquivos.IndiceAcessos, ids)
+	if err != nil {
+		return 0, err
+	}
+	if len(existentes) == 0 {
+		return 0, nil
+	}
+
+	return len(existentes), removerAcessosEmLote(b.arquivos.Armazenamento, b.arquivos.Acessos, b.arquivos.IndiceAcessos, existentes)
+}
+
+// idsNoIndice devolve, ordenados e sem repetições, os IDs de ids presentes no índice.
+func idsNoIndice(armazenamento Armazenamento, indexName string, ids []int32) ([]int32, error) {
+	offsets, _, err := offsetsNoIndice(armazenamento, indexName, ids)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Sorted(maps.Keys(offsets)), nil
+}
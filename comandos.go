@@ -0,0 +1,446 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func executarComando(nome string, args []string) error {
+	switch nome {
+	case "agrupar":
+		return comandoAgrupar(args)
+	case "top":
+		return comandoTop(args)
+	case "funil":
+		return comandoFunil(args)
+	case "servir":
+		return comandoServir(args)
+	case "resp":
+		return comandoRESP(args)
+	case "check":
+		return comandoCheck(args)
+	case "reindex":
+		return comandoReindex(args)
+	case "crashtest":
+		return comandoCrashtest(args)
+	case "gerar":
+		return comandoGerar(args)
+	case "bench":
+		return comandoBench(args)
+	case "shell":
+		return comandoShell(args)
+	case "migrar-precos":
+		return comandoMigrarPrecos(args)
+	}
+	return fmt.Errorf("comando desconhecido: %s", nome)
+}
+
+func arquivoDaTabela(tabela string, filenameProd string, filenameAccess string) (string, error) {
+	switch tabela {
+	case "produtos":
+		return filenameProd, nil
+	case "acessos":
+		return filenameAccess, nil
+	}
+	return "", fmt.Errorf("tabela desconhecida: %s", tabela)
+}
+
+func flagsArquivos(flags *flag.FlagSet) *Arquivos {
+	arquivos := arquivosPadrao()
+	flags.StringVar(&arquivos.Produtos, "produtos", arquivos.Produtos, "arquivo de produtos")
+	flags.StringVar(&arquivos.Acessos, "acessos", arquivos.Acessos, "arquivo de acessos")
+	flags.StringVar(&arquivos.IndiceProdutos, "indice-produtos", arquivos.IndiceProdutos, "índice primário de produtos")
+	flags.StringVar(&arquivos.IndiceAcessos, "indice-acessos", arquivos.IndiceAcessos, "índice primário de acessos")
+	flags.StringVar(&arquivos.IndicePrecos, "indice-precos", arquivos.IndicePrecos, "índice de preços de produtos")
+	return &arquivos
+}
+
+func abrirBancoComando(arquivos Arquivos, mmap bool) (*Banco, error) {
+	if mmap {
+		return abrirBancoMapeado(arquivos)
+	}
+	return abrirBanco(arquivos)
+}
+
+func dividirLista(s string) []string {
+	var itens []string
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			itens = append(itens, item)
+		}
+	}
+	return itens
+}
+
+func comandoAgrupar(args []string) error {
+	flags := flag.NewFlagSet("agrupar", flag.ContinueOnError)
+	tabela := flags.String("tabela", "produtos", "tabela a agrupar (produtos ou acessos)")
+	por := flags.String("por", "", "campos de agrupamento separados por vírgula")
+	agg := flags.String("agg", "count", "agregações, ex.: count,avg:price,count_distinct:brand")
+	formato := flags.String("formato", "tabela", "formato de saída (tabela ou json)")
+	filenameProd := flags.String("produtos", "produtos.bin", "arquivo de produtos")
+	filenameAccess := flags.String("acessos", "acessos.bin", "arquivo de acessos")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	filename, err := arquivoDaTabela(*tabela, *filenameProd, *filenameAccess)
+	if err != nil {
+		return err
+	}
+	if *tabela == "produtos" {
+		if err := conferirFormatoPrecos(armazenamentoOS{}, filename); err != nil {
+			return err
+		}
+	}
+
+	agregacoes, err := parseAgregacoes(*agg)
+	if err != nil {
+		return err
+	}
+
+	camposGrupo := dividirLista(*por)
+	grupos, err := agrupar(armazenamentoOS{}, *tabela, filename, camposGrupo, agregacoes)
+	if err != nil {
+		return err
+	}
+
+	return escreverGrupos(os.Stdout, *formato, camposGrupo, agregacoes, grupos)
+}
+
+func comandoTop(args []string) error {
+	flags := flag.NewFlagSet("top", flag.ContinueOnError)
+	tabela := flags.String("tabela", "acessos", "tabela consultada (produtos ou acessos)")
+	campo := flags.String("campo", "user_session", "campo cujos valores são contados")
+	k := flags.Int("k", 10, "quantidade de valores mais frequentes")
+	formato := flags.String("formato", "tabela", "formato de saída (tabela ou json)")
+	filenameProd := flags.String("produtos", "produtos.bin", "arquivo de produtos")
+	filenameAccess := flags.String("acessos", "acessos.bin", "arquivo de acessos")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	filename, err := arquivoDaTabela(*tabela, *filenameProd, *filenameAccess)
+	if err != nil {
+		return err
+	}
+	if *tabela == "produtos" {
+		if err := conferirFormatoPrecos(armazenamentoOS{}, filename); err != nil {
+			return err
+		}
+	}
+
+	top, err := topKValores(armazenamentoOS{}, *tabela, filename, *campo, *k)
+	if err != nil {
+		return err
+	}
+
+	return escreverTopK(os.Stdout, *formato, *campo, top)
+}
+
+func comandoFunil(args []string) error {
+	flags := flag.NewFlagSet("funil", flag.ContinueOnError)
+	por := flags.String("por", "", "campo de produtos para abrir o funil (ex.: brand, product_id)")
+	limite := flags.Int("limite", 0, "quantidade máxima de grupos exibidos (0 para todos)")
+	formato := flags.String("formato", "tabela", "formato de saída (tabela ou json)")
+	filenameProd := flags.String("produtos", "produtos.bin", "arquivo de produtos")
+	filenameAccess := flags.String("acessos", "acessos.bin", "arquivo de acessos")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if err := conferirFormatoPrecos(armazenamentoOS{}, *filenameProd); err != nil {
+		return err
+	}
+
+	funil, err := analisarFunil(armazenamentoOS{}, *filenameProd, *filenameAccess, *por)
+	if err != nil {
+		return err
+	}
+
+	if *limite > 0 && len(funil) > *limite+1 {
+		funil = funil[:*limite+1]
+	}
+
+	return escreverFunil(os.Stdout, *formato, *por, funil)
+}
+
+func comandoServir(args []string) error {
+	flags := flag.NewFlagSet("servir", flag.ContinueOnError)
+	endereco := flags.String("addr", ":8080", "endereço HTTP")
+	arquivos := flagsArquivos(flags)
+	mmap := flags.Bool("mmap", false, "mantém dados e índices mapeados em memória para as consultas por ID")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	banco, err := abrirBancoComando(*arquivos, *mmap)
+	if err != nil {
+		return err
+	}
+	defer banco.fechar()
+
+	return servirHTTP(*endereco, banco)
+}
+
+func comandoRESP(args []string) error {
+	flags := flag.NewFlagSet("resp", flag.ContinueOnError)
+	endereco := flags.String("addr", ":6380", "endereço TCP do servidor RESP")
+	arquivos := flagsArquivos(flags)
+	mmap := flags.Bool("mmap", false, "mantém dados e índices mapeados em memória para as consultas por ID")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	banco, err := abrirBancoComando(*arquivos, *mmap)
+	if err != nil {
+		return err
+	}
+	defer banco.fechar()
+
+	return servirRESP(*endereco, banco)
+}
+
+func comandoCheck(args []string) error {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	formato := flags.String("formato", "tabela", "formato de saída (tabela ou json)")
+	arquivos := flagsArquivos(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	problemas, err := verificarArquivos(*arquivos)
+	if err != nil {
+		return err
+	}
+
+	if len(problemas) == 0 && *formato == "tabela" {
+		fmt.Println("nenhum problema encontrado")
+		return nil
+	}
+	if err := escreverProblemas(os.Stdout, *formato, problemas); err != nil {
+		return err
+	}
+	if len(problemas) > 0 {
+		return fmt.Errorf("%d problema(s) encontrado(s)", len(problemas))
+	}
+	return nil
+}
+
+func comandoReindex(args []string) error {
+	flags := flag.NewFlagSet("reindex", flag.ContinueOnError)
+	arquivos := flagsArquivos(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if err := reindexar(*arquivos); err != nil {
+		return err
+	}
+
+	fmt.Printf("índices recriados: %s, %s, %s\n", arquivos.IndiceProdutos, arquivos.IndiceAcessos, arquivos.IndicePrecos)
+	return nil
+}
+
+func comandoMigrarPrecos(args []string) error {
+	flags := flag.NewFlagSet("migrar-precos", flag.ContinueOnError)
+	arquivos := flagsArquivos(flags)
+	backup := flags.Bool("backup", true, "guarda o arquivo original em <produtos>.float32")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	n, err := migrarPrecos(*arquivos, *backup)
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		fmt.Printf("%s já está com preços em centavos; nada a converter\n", arquivos.Produtos)
+		return nil
+	}
+	fmt.Printf("%d produtos convertidos para preços em centavos\n", n)
+	if *backup {
+		fmt.Printf("original guardado em %s\n", arquivoBackupFloat32(arquivos.Produtos))
+	}
+	return nil
+}
+
+func comandoCrashtest(args []string) error {
+	flags := flag.NewFlagSet("crashtest", flag.ContinueOnError)
+	cenarios := flags.String("cenarios", "", "cenários a simular, separados por vírgula (padrão: todos)")
+	registros := flags.Int("registros", 20, "produtos e acessos no banco inicial")
+	passo := flags.Int64("passo", 1, "intervalo, em bytes, entre os pontos de falha das escritas")
+	verboso := flags.Bool("v", false, "mostra os logs de reparo dos índices")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *registros < 10 || *passo < 1 {
+		return fmt.Errorf("-registros deve ser pelo menos 10 e -passo pelo menos 1")
+	}
+
+	escolhidos, err := cenariosPorNome(dividirLista(*cenarios))
+	if err != nil {
+		return err
+	}
+
+	if !*verboso {
+		saidaLog := log.Writer()
+		log.SetOutput(io.Discard)
+		defer log.SetOutput(saidaLog)
+	}
+
+	arquivos := arquivosPadrao()
+	inicial, err := bancoInicialQueda(arquivos, *registros)
+	if err != nil {
+		return err
+	}
+
+	var falhas int
+	for _, cenario := range escolhidos {
+		r, err := simularQuedas(cenario, arquivos, inicial, *passo)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%-18s %6d bytes %6d execuções %4d falha(s)\n", r.cenario, r.bytes, r.execucoes, len(r.falhas))
+		for _, f := range r.falhas {
+			fmt.Printf("    %s\n", f)
+		}
+		falhas += len(r.falhas)
+	}
+
+	if falhas > 0 {
+		return fmt.Errorf("%d falha(s) de consistência", falhas)
+	}
+	return nil
+}
+
+func comandoGerar(args []string) error {
+	cfg := configGeradorPadrao()
+	flags := flag.NewFlagSet("gerar", flag.ContinueOnError)
+	flags.IntVar(&cfg.linhas, "linhas", cfg.linhas, "número de eventos")
+	flags.Uint64Var(&cfg.semente, "semente", cfg.semente, "semente do gerador; a mesma semente gera os mesmos dados")
+	flags.IntVar(&cfg.produtos, "n-produtos", cfg.produtos, "produtos distintos")
+	flags.IntVar(&cfg.marcas, "n-marcas", cfg.marcas, "marcas distintas")
+	flags.IntVar(&cfg.categorias, "n-categorias", cfg.categorias, "categorias distintas")
+	flags.IntVar(&cfg.usuarios, "n-usuarios", cfg.usuarios, "usuários distintos")
+	flags.IntVar(&cfg.sessoes, "n-sessoes", cfg.sessoes, "sessões distintas")
+	flags.Float64Var(&cfg.zipfProdutos, "zipf-produtos", cfg.zipfProdutos, "expoente Zipf da popularidade dos produtos (> 1)")
+	flags.Float64Var(&cfg.zipfUsuarios, "zipf-usuarios", cfg.zipfUsuarios, "expoente Zipf das sessões por usuário (> 1)")
+	flags.Float64Var(&cfg.zipfSessoes, "zipf-sessoes", cfg.zipfSessoes, "expoente Zipf dos eventos por sessão (> 1)")
+	flags.Float64Var(&cfg.zipfMarcas, "zipf-marcas", cfg.zipfMarcas, "expoente Zipf dos produtos por marca (> 1, ou 0 para uniforme)")
+	flags.Float64Var(&cfg.zipfCategorias, "zipf-categorias", cfg.zipfCategorias, "expoente Zipf dos produtos por categoria (> 1, ou 0 para uniforme)")
+	flags.StringVar(&cfg.eventos, "eventos", cfg.eventos, "mistura de tipos de evento, como tipo=peso separados por vírgula")
+	saida := flags.String("saida", "t.csv", "arquivo CSV de saída (- para a saída padrão)")
+	binario := flags.Bool("bin", false, "grava os arquivos binários, os índices e as sequências em vez do CSV")
+	arquivos := flagsArquivos(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *binario {
+		return gerarBinarios(*arquivos, cfg)
+	}
+	if *saida == "-" {
+		return gerarCSV(os.Stdout, cfg)
+	}
+
+	file, err := os.Create(*saida)
+	if err != nil {
+		return fmt.Errorf("erro ao criar o arquivo CSV: %w", err)
+	}
+	err = gerarCSV(file, cfg)
+	if errClose := file.Close(); err == nil {
+		err = errClose
+	}
+	return err
+}
+
+func comandoBench(args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	tamanhos := flags.String("tamanhos", "1e4,1e5,1e6", "números de registros dos bancos gerados (até 1e8 ou mais, se couber no disco)")
+	consultas := flags.Int("consultas", 1000, "consultas por estratégia e tamanho")
+	tempo := flags.Duration("tempo", 5*time.Second, "tempo máximo de cada estratégia em cada tamanho")
+	semente := flags.Uint64("semente", 1, "semente dos dados gerados e dos IDs consultados")
+	estrategias := flags.String("estrategias", "", "estratégias medidas: varredura, busca-binaria, indice, mmap (padrão: todas)")
+	dir := flags.String("dir", "", "diretório dos bancos gerados (padrão: um temporário, apagado no fim)")
+	formato := flags.String("formato", "tabela", "formato de saída (tabela ou json)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	ns, err := lerTamanhos(*tamanhos)
+	if err != nil {
+		return err
+	}
+	if *consultas < 1 {
+		return fmt.Errorf("-consultas deve ser pelo menos 1")
+	}
+	cfg := configBench{consultas: *consultas, tempo: *tempo, semente: *semente, estrategias: dividirLista(*estrategias)}
+	for _, nome := range cfg.estrategias {
+		if !slices.ContainsFunc(estrategiasBusca(Arquivos{}, nil), func(e estrategiaBusca) bool { return e.nome == nome }) {
+			return fmt.Errorf("estratégia desconhecida: %s", nome)
+		}
+	}
+
+	base := *dir
+	if base == "" {
+		base, err = os.MkdirTemp("", "bench-")
+		if err != nil {
+			return fmt.Errorf("erro ao criar diretório temporário: %w", err)
+		}
+		defer os.RemoveAll(base)
+	}
+
+	var medicoes []MedicaoBusca
+	for _, n := range ns {
+		dirBanco := filepath.Join(base, strconv.Itoa(n))
+		if err := os.MkdirAll(dirBanco, 0755); err != nil {
+			return fmt.Errorf("erro ao criar diretório %s: %w", dirBanco, err)
+		}
+
+		fmt.Fprintf(os.Stderr, "medindo %d registros em %s\n", n, dirBanco)
+		m, err := medirBuscas(dirBanco, n, cfg)
+		if err != nil {
+			return err
+		}
+		medicoes = append(medicoes, m...)
+	}
+
+	return escreverMedicoes(os.Stdout, *formato, medicoes)
+}
+
+func comandoShell(args []string) error {
+	flags := flag.NewFlagSet("shell", flag.ContinueOnError)
+	mmap := flags.Bool("mmap", false, "mantém os arquivos mapeados em memória")
+	historico := flags.String("historico", arquivoHistoricoPadrao(), "arquivo do histórico de comandos (vazio para não gravar)")
+	arquivos := flagsArquivos(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	s := &shell{mmap: *mmap, saida: os.Stdout}
+	if err := s.abrir(*arquivos); err != nil {
+		return err
+	}
+	editor := novoEditorLinha(*historico, s.completar)
+	return executarShell(s, editor)
+}
+
+func arquivoHistoricoPadrao() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".banco_historico")
+}
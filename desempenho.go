@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// estrategiaBusca é uma das maneiras de achar um produto pelo ID comparadas por bench.
+type estrategiaBusca struct {
+	nome   string
+	buscar func(id int32) error
+}
+
+func estrategiasBusca(arquivos Arquivos, mapas *mapasBanco) []estrategiaBusca {
+	return []estrategiaBusca{
+		{"varredura", func(id int32) error {
+			_, err := buscarPorVarredura(arquivos.Armazenamento, arquivos.Produtos, id)
+			return err
+		}},
+		{"busca-binaria", func(id int32) error {
+			_, err := pesquisarProduto(arquivos.Armazenamento, arquivos.Produtos, id)
+			return err
+		}},
+		{"indice", func(id int32) error {
+			_, err := consultarProdutoComIndice(arquivos.Armazenamento, arquivos.IndiceProdutos, arquivos.Produtos, id)
+			return err
+		}},
+		{"mmap", func(id int32) error {
+			_, err := mapas.consultarProduto(id)
+			return err
+		}},
+	}
+}
+
+// buscarPorVarredura lê o arquivo desde o início, como lerArquivoProdutos, até achar id.
+func buscarPorVarredura(armazenamento Armazenamento, filename string, id int32) (Produto, error) {
+	for produto, err := range produtosDoArquivo(armazenamento, filename) {
+		if err != nil {
+			return Produto{}, err
+		}
+		if bytesToInt32(produto.ID) == id {
+			return *produto, nil
+		}
+	}
+	return Produto{}, fmt.Errorf("produto com ID %d %w", id, ErrNotFound)
+}
+
+// MedicaoBusca resume as consultas de uma estratégia num arquivo de registros produtos.
+// Leituras e bytes contam só as chamadas de leitura; o que mmap lê por falta de página
+// não aparece neles.
+type MedicaoBusca struct {
+	Registros           int     `json:"registros"`
+	Estrategia          string  `json:"estrategia"`
+	Consultas           int     `json:"consultas"`
+	P50                 float64 `json:"p50_us"`
+	P90                 float64 `json:"p90_us"`
+	P99                 float64 `json:"p99_us"`
+	Maximo              float64 `json:"max_us"`
+	ConsultasPorSegundo float64 `json:"consultas_por_segundo"`
+	LeiturasPorConsulta float64 `json:"leituras_por_consulta"`
+	BytesPorConsulta    float64 `json:"bytes_por_consulta"`
+}
+
+// configBench controla quantas consultas cada estratégia faz: até consultas, parando
+// antes se passar de tempo (mas sempre pelo menos uma).
+type configBench struct {
+	consultas   int
+	tempo       time.Duration
+	semente     uint64
+	estrategias []string
+}
+
+// medirBuscas gera um banco com n registros em dir e mede cada estratégia sobre ele,
+// com os mesmos IDs sorteados para todas.
+func medirBuscas(dir string, n int, cfg configBench) ([]MedicaoBusca, error) {
+	arquivos := arquivosNoDiretorio(dir)
+
+	cfgGerador := configGeradorPadrao()
+	cfgGerador.linhas = n
+	cfgGerador.semente = cfg.semente
+	if err := gerarBinarios(arquivos, cfgGerador); err != nil {
+		return nil, err
+	}
+
+	mapas, err := mapearBanco(arquivos)
+	if err != nil {
+		return nil, err
+	}
+	defer mapas.fechar()
+
+	rng := rand.New(rand.NewPCG(cfg.semente, uint64(n)))
+	ids := make([]int32, cfg.consultas)
+	for i := range ids {
+		ids[i] = int32(1 + rng.IntN(n))
+	}
+
+	// As estratégias leem pelo contador, que só existe nesta cópia de arquivos: nada
+	// mais do processo passa por ele.
+	contador := novoArmazenamentoContador(arquivos.Armazenamento)
+	arquivos.Armazenamento = contador
+
+	var medicoes []MedicaoBusca
+	for _, estrategia := range estrategiasBusca(arquivos, mapas) {
+		if len(cfg.estrategias) > 0 && !slices.Contains(cfg.estrategias, estrategia.nome) {
+			continue
+		}
+
+		m, err := medirEstrategia(estrategia, ids, cfg.tempo, contador)
+		if err != nil {
+			return nil, err
+		}
+		m.Registros = n
+		medicoes = append(medicoes, m)
+	}
+	return medicoes, nil
+}
+
+func medirEstrategia(estrategia estrategiaBusca, ids []int32, limite time.Duration, contador *armazenamentoContador) (MedicaoBusca, error) {
+	duracoes := make([]time.Duration, 0, len(ids))
+	contador.zerar()
+
+	inicio := time.Now()
+	for _, id := range ids {
+		t := time.Now()
+		if err := estrategia.buscar(id); err != nil {
+			return MedicaoBusca{}, fmt.Errorf("%s: erro ao buscar o ID %d: %w", estrategia.nome, id, err)
+		}
+		duracoes = append(duracoes, time.Since(t))
+
+		if time.Since(inicio) > limite {
+			break
+		}
+	}
+	total := time.Since(inicio)
+	leituras, bytes := contador.zerar()
+
+	slices.Sort(duracoes)
+	n := float64(len(duracoes))
+	return MedicaoBusca{
+		Estrategia:          estrategia.nome,
+		Consultas:           len(duracoes),
+		P50:                 microssegundos(percentil(duracoes, 0.50)),
+		P90:                 microssegundos(percentil(duracoes, 0.90)),
+		P99:                 microssegundos(percentil(duracoes, 0.99)),
+		Maximo:              microssegundos(duracoes[len(duracoes)-1]),
+		ConsultasPorSegundo: n / total.Seconds(),
+		LeiturasPorConsulta: float64(leituras) / n,
+		BytesPorConsulta:    float64(bytes) / n,
+	}, nil
+}
+
+// percentil devolve o valor de ordem p (entre 0 e 1) de duracoes, já ordenadas.
+func percentil(duracoes []time.Duration, p float64) time.Duration {
+	i := int(math.Ceil(p*float64(len(duracoes)))) - 1
+	return duracoes[max(i, 0)]
+}
+
+func microssegundos(d time.Duration) float64 {
+	return float64(d) / float64(time.Microsecond)
+}
+
+// lerTamanhos interpreta uma lista como "1e4,1e5,1e6".
+func lerTamanhos(s string) ([]int, error) {
+	var tamanhos []int
+	for _, parte := range dividirLista(s) {
+		f, err := strconv.ParseFloat(parte, 64)
+		if err != nil || f < 1 || f >= math.MaxInt32 || f != math.Trunc(f) {
+			return nil, fmt.Errorf("tamanho inválido: %q", parte)
+		}
+		tamanhos = append(tamanhos, int(f))
+	}
+	if len(tamanhos) == 0 {
+		return nil, fmt.Errorf("nenhum tamanho informado")
+	}
+	return tamanhos, nil
+}
+
+func escreverMedicoes(w io.Writer, formato string, medicoes []MedicaoBusca) error {
+	if medicoes == nil {
+		medicoes = []MedicaoBusca{}
+	}
+
+	linhas := make([][]string, len(medicoes))
+	for i, m := range medicoes {
+		linhas[i] = []string{
+			strconv.Itoa(m.Registros),
+			m.Estrategia,
+			strconv.Itoa(m.Consultas),
+			strconv.FormatFloat(m.P50, 'f', 1, 64),
+			strconv.FormatFloat(m.P90, 'f', 1, 64),
+			strconv.FormatFloat(m.P99, 'f', 1, 64),
+			strconv.FormatFloat(m.Maximo, 'f', 1, 64),
+			strconv.FormatFloat(m.ConsultasPorSegundo, 'f', 0, 64),
+			strconv.FormatFloat(m.LeiturasPorConsulta, 'f', 1, 64),
+			strconv.FormatFloat(m.BytesPorConsulta, 'f', 0, 64),
+		}
+	}
+	cabecalho := []string{"registros", "estrategia", "consultas", "p50 (µs)", "p90 (µs)", "p99 (µs)", "max (µs)", "consultas/s", "leituras/consulta", "bytes/consulta"}
+	return escreverSaida(w, formato, cabecalho, linhas, medicoes)
+}
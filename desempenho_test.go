@@ -0,0 +1,42 @@
+package main
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+const registrosBenchBusca = 100000
+
+// BenchmarkBusca mede cada estratégia de estrategiasBusca, a mesma lista do comando
+// bench, num banco gerado com registrosBenchBusca registros, consultando IDs sorteados.
+func BenchmarkBusca(b *testing.B) {
+	arquivos := arquivosNoDiretorio(b.TempDir())
+	cfg := configGeradorPadrao()
+	cfg.linhas = registrosBenchBusca
+	if err := gerarBinarios(arquivos, cfg); err != nil {
+		b.Fatal(err)
+	}
+
+	mapas, err := mapearBanco(arquivos)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer mapas.fechar()
+
+	rng := rand.New(rand.NewPCG(1, registrosBenchBusca))
+	ids := make([]int32, 1024)
+	for i := range ids {
+		ids[i] = int32(1 + rng.IntN(registrosBenchBusca))
+	}
+
+	for _, estrategia := range estrategiasBusca(arquivos, mapas) {
+		b.Run(estrategia.nome, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := range b.N {
+				if err := estrategia.buscar(ids[i%len(ids)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// Atualizações no lugar passam por <arquivo>.diario, com o offset (int64 little-endian)
+// e o conteúdo novo do registro. O diário é gravado e sincronizado antes do registro e
+// só é apagado depois dele; se sobrar um diário, a atualização foi interrompida e
+// aplicarDiario a termina.
+func arquivoDiario(filename string) string {
+	return filename + ".diario"
+}
+
+// sobrescreverRegistro grava registro no offset de filename sem que uma falha no meio
+// deixe um registro com parte do conteúdo antigo e parte do novo.
+func sobrescreverRegistro(armazenamento Armazenamento, filename string, offset int64, registro []byte) error {
+	err := gravarAtomicamente(armazenamento, arquivoDiario(filename), func(w io.Writer) error {
+		var cabecalho [8]byte
+		binary.LittleEndian.PutUint64(cabecalho[:], uint64(offset))
+		_, err := w.Write(cabecalho[:])
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(registro)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Se a escrita falhar, o diário fica para a próxima abertura do banco.
+	err = escreverNoLugar(armazenamento, filename, offset, registro)
+	if err != nil {
+		return err
+	}
+	return removerDiario(armazenamento, filename)
+}
+
+// aplicarDiario termina a atualização interrompida de filename, se houver uma. O diário
+// só é aplicado se o registro no offset ainda tiver o mesmo ID: depois da falha, uma
+// remoção pode ter reescrito o arquivo e movido os registros.
+func aplicarDiario(armazenamento Armazenamento, filename string, tamanho int) error {
+	nome := arquivoDiario(filename)
+	dados, err := lerArquivoInteiro(armazenamento, nome)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao ler o diário %s: %w", nome, err)
+	}
+	if len(dados) != 8+tamanho {
+		return fmt.Errorf("%w: diário %s com %d bytes", ErrCorrupt, nome, len(dados))
+	}
+
+	offset := int64(binary.LittleEndian.Uint64(dados[0:8]))
+	registro := dados[8:]
+
+	atual, err := lerTrecho(armazenamento, filename, offset, 4)
+	if errors.Is(err, ErrCorrupt) || (err == nil && [4]byte(atual) != [4]byte(registro[0:4])) {
+		log.Printf("diário %s não corresponde mais a %s; descartando", nome, filename)
+		return removerDiario(armazenamento, filename)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("concluindo a atualização interrompida do offset %d de %s", offset, filename)
+	err = escreverNoLugar(armazenamento, filename, offset, registro)
+	if err != nil {
+		return err
+	}
+	return removerDiario(armazenamento, filename)
+}
+
+func escreverNoLugar(armazenamento Armazenamento, filename string, offset int64, registro []byte) error {
+	file, err := armazenamento.Abrir(filename, os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("erro ao abrir o arquivo %s para atualização: %w", filename, err)
+	}
+
+	_, err = file.WriteAt(registro, offset)
+	if err == nil {
+		err = file.Sync()
+	}
+	if errClose := file.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao escrever no offset %d de %s: %w", offset, filename, err)
+	}
+	return nil
+}
+
+func removerDiario(armazenamento Armazenamento, filename string) error {
+	err := armazenamento.Remover(arquivoDiario(filename))
+	if err != nil {
+		return fmt.Errorf("erro ao remover o diário de %s: %w", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// errLinhaCancelada é devolvido por lerLinha quando o usuário aperta Ctrl-C.
+var errLinhaCancelada = errors.New("linha cancelada")
+
+const limiteHistorico = 1000
+
+// editorLinha lê comandos do terminal com edição no estilo readline: setas, Home/End,
+// Ctrl-A/E/K/U/W, histórico com as setas para cima e para baixo e Tab para completar.
+// Se a entrada não for um terminal, ele apenas lê uma linha por vez.
+type editorLinha struct {
+	entrada  *bufio.Reader
+	saida    io.Writer
+	fd       int
+	terminal bool
+
+	historico        []string
+	arquivoHistorico string
+
+	// completar recebe o texto antes do cursor e devolve as opções para a última
+	// palavra dele.
+	completar func(antes string) []string
+}
+
+func novoEditorLinha(arquivoHistorico string, completar func(string) []string) *editorLinha {
+	e := &editorLinha{
+		entrada:          bufio.NewReader(os.Stdin),
+		saida:            os.Stdout,
+		fd:               int(os.Stdin.Fd()),
+		terminal:         ehTerminal(int(os.Stdin.Fd())),
+		arquivoHistorico: arquivoHistorico,
+		completar:        completar,
+	}
+	e.carregarHistorico()
+	return e
+}
+
+// carregarHistorico lê o histórico de sessões anteriores; sem arquivo, o histórico
+// começa vazio.
+func (e *editorLinha) carregarHistorico() {
+	if e.arquivoHistorico == "" {
+		return
+	}
+	dados, err := os.ReadFile(e.arquivoHistorico)
+	if err != nil {
+		return
+	}
+	for _, linha := range strings.Split(string(dados), "\n") {
+		if strings.TrimSpace(linha) != "" {
+			e.historico = append(e.historico, linha)
+		}
+	}
+	e.historico = e.historico[max(0, len(e.historico)-limiteHistorico):]
+}
+
+func (e *editorLinha) salvarHistorico() error {
+	if e.arquivoHistorico == "" {
+		return nil
+	}
+	var b strings.Builder
+	for _, linha := range e.historico {
+		b.WriteString(linha)
+		b.WriteByte('\n')
+	}
+	err := os.WriteFile(e.arquivoHistorico, []byte(b.String()), 0600)
+	if err != nil {
+		return fmt.Errorf("erro ao gravar o histórico: %w", err)
+	}
+	return nil
+}
+
+func (e *editorLinha) adicionarAoHistorico(linha string) {
+	if strings.TrimSpace(linha) == "" {
+		return
+	}
+	if n := len(e.historico); n > 0 && e.historico[n-1] == linha {
+		return
+	}
+	e.historico = append(e.historico, linha)
+	if len(e.historico) > limiteHistorico {
+		e.historico = e.historico[1:]
+	}
+}
+
+// lerLinha mostra o prompt e devolve a linha digitada, sem o "\n". Ctrl-D numa linha
+// vazia devolve io.EOF.
+func (e *editorLinha) lerLinha(prompt string) (string, error) {
+	if !e.terminal {
+		return e.lerLinhaSimples()
+	}
+
+	restaurar, err := modoBruto(e.fd)
+	if err != nil {
+		return e.lerLinhaSimples()
+	}
+	defer restaurar()
+
+	linha, err := e.editar(prompt)
+	if err == nil {
+		e.adicionarAoHistorico(linha)
+	}
+	return linha, err
+}
+
+func (e *editorLinha) lerLinhaSimples() (string, error) {
+	linha, err := e.entrada.ReadString('\n')
+	if err == io.EOF && linha != "" {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(linha, "\r\n"), nil
+}
+
+// estadoLinha é a linha em edição: o texto e a posição do cursor, em runas.
+type estadoLinha struct {
+	prompt string
+	texto  []rune
+	cursor int
+}
+
+func (e *editorLinha) redesenhar(l *estadoLinha) {
+	fmt.Fprintf(e.saida, "\r%s%s\x1b[K", l.prompt, string(l.texto))
+	if volta := len(l.texto) - l.cursor; volta > 0 {
+		fmt.Fprintf(e.saida, "\x1b[%dD", volta)
+	}
+}
+
+func (l *estadoLinha) trocar(texto string) {
+	l.texto = []rune(texto)
+	l.cursor = len(l.texto)
+}
+
+func (l *estadoLinha) inserir(r ...rune) {
+	l.texto = slices.Insert(l.texto, l.cursor, r...)
+	l.cursor += len(r)
+}
+
+// apagarPalavra remove a palavra antes do cursor, como Ctrl-W no bash.
+func (l *estadoLinha) apagarPalavra() {
+	inicio := l.cursor
+	for inicio > 0 && l.texto[inicio-1] == ' ' {
+		inicio--
+	}
+	for inicio > 0 && l.texto[inicio-1] != ' ' {
+		inicio--
+	}
+	l.texto = slices.Delete(l.texto, inicio, l.cursor)
+	l.cursor = inicio
+}
+
+func (e *editorLinha) editar(prompt string) (string, error) {
+	l := &estadoLinha{prompt: prompt}
+	// posHistorico vai até len(historico), que é a linha nova; rascunho guarda o que
+	// foi digitado nela antes de navegar pelo histórico.
+	posHistorico := len(e.historico)
+	rascunho := ""
+	navegar := func(pos int) {
+		if pos < 0 || pos > len(e.historico) {
+			return
+		}
+		if posHistorico == len(e.historico) {
+			rascunho = string(l.texto)
+		}
+		posHistorico = pos
+		if pos == len(e.historico) {
+			l.trocar(rascunho)
+		} else {
+			l.trocar(e.historico[pos])
+		}
+	}
+
+	e.redesenhar(l)
+	for {
+		r, _, err := e.entrada.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.saida, "\n")
+			return string(l.texto), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(e.saida, "^C\n")
+			return "", errLinhaCancelada
+		case 4: // Ctrl-D
+			if len(l.texto) == 0 {
+				fmt.Fprint(e.saida, "\n")
+				return "", io.EOF
+			}
+			if l.cursor < len(l.texto) {
+				l.texto = slices.Delete(l.texto, l.cursor, l.cursor+1)
+			}
+		case 127, 8: // Backspace
+			if l.cursor > 0 {
+				l.texto = slices.Delete(l.texto, l.cursor-1, l.cursor)
+				l.cursor--
+			}
+		case 1: // Ctrl-A
+			l.cursor = 0
+		case 5: // Ctrl-E
+			l.cursor = len(l.texto)
+		case 2: // Ctrl-B
+			l.cursor = max(0, l.cursor-1)
+		case 6: // Ctrl-F
+			l.cursor = min(len(l.texto), l.cursor+1)
+		case 11: // Ctrl-K
+			l.texto = l.texto[:l.cursor]
+		case 21: // Ctrl-U
+			l.texto = slices.Delete(l.texto, 0, l.cursor)
+			l.cursor = 0
+		case 23: // Ctrl-W
+			l.apagarPalavra()
+		case 12: // Ctrl-L
+			fmt.Fprint(e.saida, "\x1b[H\x1b[2J")
+		case 16: // Ctrl-P
+			navegar(posHistorico - 1)
+		case 14: // Ctrl-N
+			navegar(posHistorico + 1)
+		case '\t':
+			e.completarPalavra(l)
+		case 27: // ESC: setas, Home, End e Delete
+			switch e.lerEscape() {
+			case "[A", "OA":
+				navegar(posHistorico - 1)
+			case "[B", "OB":
+				navegar(posHistorico + 1)
+			case "[C", "OC":
+				l.cursor = min(len(l.texto), l.cursor+1)
+			case "[D", "OD":
+				l.cursor = max(0, l.cursor-1)
+			case "[H", "OH", "[1~", "[7~":
+				l.cursor = 0
+			case "[F", "OF", "[4~", "[8~":
+				l.cursor = len(l.texto)
+			case "[3~":
+				if l.cursor < len(l.texto) {
+					l.texto = slices.Delete(l.texto, l.cursor, l.cursor+1)
+				}
+			}
+		default:
+			if r >= ' ' {
+				l.inserir(r)
+			}
+		}
+		e.redesenhar(l)
+	}
+}
+
+// lerEscape lê o resto de uma sequência de escape, como "[A" ou "[3~", depois do ESC.
+func (e *editorLinha) lerEscape() string {
+	var seq []byte
+	for len(seq) < 8 {
+		c, err := e.entrada.ReadByte()
+		if err != nil {
+			break
+		}
+		seq = append(seq, c)
+		if len(seq) == 1 && c != '[' && c != 'O' {
+			break
+		}
+		if len(seq) > 1 && (c >= 'A' && c <= 'Z' || c == '~') {
+			break
+		}
+	}
+	return string(seq)
+}
+
+// completarPalavra completa a palavra antes do cursor: com uma opção só, ela é escrita
+// por inteiro; com várias, escreve o prefixo comum ou, se não houver o que acrescentar,
+// lista as opções.
+func (e *editorLinha) completarPalavra(l *estadoLinha) {
+	if e.completar == nil {
+		return
+	}
+	antes := string(l.texto[:l.cursor])
+	opcoes := e.completar(antes)
+	if len(opcoes) == 0 {
+		return
+	}
+
+	palavra := antes[strings.LastIndexByte(antes, ' ')+1:]
+	if len(opcoes) == 1 {
+		// Depois de "campo=" vem o valor, não outra palavra.
+		resto := strings.TrimPrefix(opcoes[0], palavra)
+		if !strings.HasSuffix(opcoes[0], "=") {
+			resto += " "
+		}
+		l.inserir([]rune(resto)...)
+		return
+	}
+
+	comum := prefixoComum(opcoes)
+	if len(comum) > len(palavra) {
+		l.inserir([]rune(strings.TrimPrefix(comum, palavra))...)
+		return
+	}
+	fmt.Fprintf(e.saida, "\n%s\n", strings.Join(opcoes, "  "))
+}
+
+// prefixoComum compara as palavras por runas, para não cortar um caractere acentuado
+// no meio.
+func prefixoComum(palavras []string) string {
+	comum := []rune(palavras[0])
+	for _, p := range palavras[1:] {
+		n := 0
+		for _, r := range p {
+			if n == len(comum) || comum[n] != r {
+				break
+			}
+			n++
+		}
+		comum = comum[:n]
+	}
+	return string(comum)
+}
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestPrefixoComum(t *testing.T) {
+	casos := []struct {
+		palavras []string
+		quero    string
+	}{
+		{[]string{"produto"}, "produto"},
+		{[]string{"produto", "produtos"}, "produto"},
+		{[]string{"scan", "stats"}, "s"},
+		{[]string{"get", "top"}, ""},
+		// "ç" e "ú" começam com o mesmo byte 0xc3; o prefixo não pode parar no meio deles.
+		{[]string{"ação", "açúcar"}, "aç"},
+		{[]string{"açúcar", "aço"}, "aç"},
+		{[]string{"ção", "çúcar"}, "ç"},
+	}
+	for _, caso := range casos {
+		if comum := prefixoComum(caso.palavras); comum != caso.quero {
+			t.Errorf("prefixoComum(%q) = %q, quero %q", caso.palavras, comum, caso.quero)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	// ErrNotFound indica que não existe registro com a chave pedida.
+	ErrNotFound = errors.New("não encontrado")
+	// ErrCorrupt indica um arquivo de dados ou de índice inconsistente, como um
+	// registro truncado ou uma entrada de índice apontando para fora do arquivo.
+	ErrCorrupt = errors.New("arquivo corrompido")
+	// ErrDuplicateID indica uma tentativa de gravar um ID que já existe.
+	ErrDuplicateID = errors.New("ID duplicado")
+	// ErrInvalidField indica um valor que não pode ser gravado no campo, como um texto
+	// maior que o espaço reservado para ele no registro.
+	ErrInvalidField = errors.New("campo inválido")
+)
+
+// RecordError identifica o registro em que uma leitura ou escrita falhou.
+type RecordError struct {
+	Arquivo string
+	Offset  int64
+	Err     error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("registro no offset %d de %s: %v", e.Offset, e.Arquivo, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// erroRegistro envolve o erro de leitura do registro em offset num *RecordError. Quem
+// chama já tratou io.EOF no início de um registro como fim normal do arquivo, então
+// qualquer EOF aqui significa um arquivo truncado e é reportado como ErrCorrupt.
+func erroRegistro(arquivo string, offset int64, err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = fmt.Errorf("%w: %w", ErrCorrupt, io.ErrUnexpectedEOF)
+	}
+	return &RecordError{Arquivo: arquivo, Offset: offset, Err: err}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Etapas do funil de conversão, na ordem em que uma sessão deve percorrê-las.
+const (
+	etapaNenhuma = iota
+	etapaView
+	etapaCart
+	etapaPurchase
+)
+
+var eventosFunil = map[string]int{
+	"view":     etapaView,
+	"cart":     etapaCart,
+	"purchase": etapaPurchase,
+}
+
+type EtapasFunil struct {
+	Grupo         string  `json:"grupo"`
+	Sessoes       int     `json:"view"`
+	Carrinho      int     `json:"cart"`
+	Compra        int     `json:"purchase"`
+	TaxaCarrinho  float64 `json:"view_to_cart"`
+	TaxaCompra    float64 `json:"cart_to_purchase"`
+	TaxaConversao float64 `json:"view_to_purchase"`
+}
+
+func (e *EtapasFunil) contar(etapa int) {
+	if etapa >= etapaView {
+		e.Sessoes++
+	}
+	if etapa >= etapaCart {
+		e.Carrinho++
+	}
+	if etapa >= etapaPurchase {
+		e.Compra++
+	}
+}
+
+func taxa(parte, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(parte) / float64(total)
+}
+
+// leitorProdutosOrdenado avança pelo arquivo de produtos acompanhando um fluxo de IDs
+// crescentes, para juntar acessos e produtos sem buscas no índice.
+type leitorProdutosOrdenado struct {
+	scanner *scannerProdutos
+	produto *Produto
+	fim     bool
+}
+
+func (l *leitorProdutosOrdenado) buscar(id int32) (*Produto, error) {
+	for !l.fim && (l.produto == nil || bytesToInt32(l.produto.ID) < id) {
+		if !l.scanner.ler() {
+			if l.scanner.erro() != nil {
+				return nil, fmt.Errorf("erro ao ler registro de produto: %w", l.scanner.erro())
+			}
+			l.fim = true
+			l.produto = nil
+			break
+		}
+		l.produto = l.scanner.produto()
+	}
+
+	if l.produto != nil && bytesToInt32(l.produto.ID) == id {
+		return l.produto, nil
+	}
+	return nil, nil
+}
+
+// analisarFunil conta, para cada valor de campoGrupo (um campo de produtos) e no total,
+// quantas sessões chegaram a view, view → cart e view → cart → purchase, seguindo a
+// ordem dos acessos. Cada acesso é associado ao produto de mesmo ID.
+func analisarFunil(armazenamento Armazenamento, filenameProd string, filenameAccess string, campoGrupo string) ([]EtapasFunil, error) {
+	var leitor *leitorProdutosOrdenado
+	var extrair func(*Produto) valorCampo
+	if campoGrupo != "" {
+		campo, ok := camposProduto[campoGrupo]
+		if !ok {
+			return nil, fmt.Errorf("campo %q não existe na tabela produtos", campoGrupo)
+		}
+		extrair = campo.extrair
+
+		scanner, err := abrirScannerProdutos(armazenamento, filenameProd)
+		if err != nil {
+			return nil, err
+		}
+		defer scanner.fechar()
+		leitor = &leitorProdutosOrdenado{scanner: scanner}
+	}
+
+	type chaveSessao struct {
+		grupo  string
+		sessao string
+	}
+	etapas := make(map[chaveSessao]int)
+	total := make(map[string]int)
+
+	err := percorrerAcessos(armazenamento, filenameAccess, func(acesso *Acesso) error {
+		etapa, ok := eventosFunil[textoFixo(acesso.EventType[:])]
+		if !ok {
+			return nil
+		}
+		sessao := textoFixo(acesso.UserSession[:])
+		if etapa == total[sessao]+1 {
+			total[sessao] = etapa
+		}
+
+		if leitor == nil {
+			return nil
+		}
+
+		produto, err := leitor.buscar(bytesToInt32(acesso.ID))
+		if err != nil {
+			return err
+		}
+		if produto == nil {
+			return nil
+		}
+
+		chave := chaveSessao{grupo: extrair(produto).String(), sessao: sessao}
+		if etapa == etapas[chave]+1 {
+			etapas[chave] = etapa
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	geral := EtapasFunil{Grupo: "total"}
+	for _, etapa := range total {
+		geral.contar(etapa)
+	}
+
+	grupos := make(map[string]*EtapasFunil)
+	for chave, etapa := range etapas {
+		e, ok := grupos[chave.grupo]
+		if !ok {
+			e = &EtapasFunil{Grupo: chave.grupo}
+			grupos[chave.grupo] = e
+		}
+		e.contar(etapa)
+	}
+
+	resultado := []EtapasFunil{geral}
+	for _, e := range grupos {
+		resultado = append(resultado, *e)
+	}
+	porGrupo := resultado[1:]
+	sort.Slice(porGrupo, func(i, j int) bool {
+		if porGrupo[i].Sessoes != porGrupo[j].Sessoes {
+			return porGrupo[i].Sessoes > porGrupo[j].Sessoes
+		}
+		return porGrupo[i].Grupo < porGrupo[j].Grupo
+	})
+
+	for i := range resultado {
+		e := &resultado[i]
+		e.TaxaCarrinho = taxa(e.Carrinho, e.Sessoes)
+		e.TaxaCompra = taxa(e.Compra, e.Carrinho)
+		e.TaxaConversao = taxa(e.Compra, e.Sessoes)
+	}
+
+	return resultado, nil
+}
+
+func escreverFunil(w io.Writer, formato string, campoGrupo string, funil []EtapasFunil) error {
+	if campoGrupo == "" {
+		campoGrupo = "grupo"
+	}
+
+	formatarTaxa := func(t float64) string {
+		return strconv.FormatFloat(t*100, 'f', 2, 64) + "%"
+	}
+
+	linhas := make([][]string, len(funil))
+	for i, e := range funil {
+		linhas[i] = []string{
+			e.Grupo,
+			strconv.Itoa(e.Sessoes),
+			strconv.Itoa(e.Carrinho),
+			strconv.Itoa(e.Compra),
+			formatarTaxa(e.TaxaCarrinho),
+			formatarTaxa(e.TaxaCompra),
+			formatarTaxa(e.TaxaConversao),
+		}
+	}
+
+	cabecalho := []string{campoGrupo, "view", "cart", "purchase", "view→cart", "cart→purchase", "view→purchase"}
+	return escreverSaida(w, formato, cabecalho, linhas, funil)
+}
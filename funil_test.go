@@ -0,0 +1,58 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAnalisarFunil(t *testing.T) {
+	b := abrirBancoTeste(t)
+
+	// O acesso de ID n é ligado ao produto de ID n.
+	var produtos []Produto
+	for _, brand := range []string{"A", "A", "B", "B", "A", "B", "A", "A"} {
+		produtos = append(produtos, produtoTeste(t, 1, 100, brand))
+	}
+	acessos := []Acesso{
+		acessoTeste(t, "s1", 1, "view"),
+		acessoTeste(t, "s1", 1, "cart"),
+		acessoTeste(t, "s2", 2, "view"),
+		acessoTeste(t, "s1", 1, "purchase"), // compra em B sem view em B
+		acessoTeste(t, "s2", 2, "purchase"), // pula o carrinho
+		acessoTeste(t, "s3", 3, "cart"),     // carrinho antes do view não conta
+		acessoTeste(t, "s3", 3, "view"),
+		acessoTeste(t, "s4", 4, "click"), // fora do funil
+	}
+	if _, err := b.inserirProdutos(produtos); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.inserirAcessos(acessos); err != nil {
+		t.Fatal(err)
+	}
+
+	funil, err := analisarFunil(b.arquivos.Armazenamento, b.arquivos.Produtos, b.arquivos.Acessos, "brand")
+	if err != nil {
+		t.Fatal(err)
+	}
+	quero := []EtapasFunil{
+		{Grupo: "total", Sessoes: 3, Carrinho: 1, Compra: 1, TaxaCarrinho: 1.0 / 3, TaxaCompra: 1, TaxaConversao: 1.0 / 3},
+		{Grupo: "A", Sessoes: 2, Carrinho: 1, TaxaCarrinho: 0.5},
+		{Grupo: "B", Sessoes: 1},
+	}
+	if !slices.Equal(funil, quero) {
+		t.Errorf("funil por brand =\n%+v\nquero\n%+v", funil, quero)
+	}
+
+	// Sem campo de grupo, só o total.
+	funil, err = analisarFunil(b.arquivos.Armazenamento, b.arquivos.Produtos, b.arquivos.Acessos, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(funil, quero[:1]) {
+		t.Errorf("funil sem grupo = %+v, quero %+v", funil, quero[:1])
+	}
+
+	if _, err := analisarFunil(b.arquivos.Armazenamento, b.arquivos.Produtos, b.arquivos.Acessos, "user_id"); err == nil {
+		t.Error("campo de acessos aceito como grupo do funil")
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cabecalhoCSV é o cabeçalho dos CSVs de eventos de eCommerce lidos por processCSV.
+var cabecalhoCSV = []string{"event_time", "event_type", "product_id", "category_id", "category_code", "brand", "price", "user_id", "user_session"}
+
+// configGerador descreve o conjunto de dados sintético. Expoentes Zipf (s) precisam ser
+// maiores que 1; para marcas e categorias, 0 sorteia de maneira uniforme.
+type configGerador struct {
+	linhas         int
+	semente        uint64
+	produtos       int
+	marcas         int
+	categorias     int
+	usuarios       int
+	sessoes        int
+	zipfProdutos   float64
+	zipfUsuarios   float64
+	zipfSessoes    float64
+	zipfMarcas     float64
+	zipfCategorias float64
+	eventos        string
+	inicio         time.Time
+	intervaloMedio time.Duration
+}
+
+func configGeradorPadrao() configGerador {
+	return configGerador{
+		linhas:         100000,
+		semente:        1,
+		produtos:       5000,
+		marcas:         200,
+		categorias:     60,
+		usuarios:       20000,
+		sessoes:        50000,
+		zipfProdutos:   1.1,
+		zipfUsuarios:   1.2,
+		zipfSessoes:    1.1,
+		zipfMarcas:     1.3,
+		zipfCategorias: 1.2,
+		eventos:        "view=92,cart=5,purchase=3",
+		inicio:         time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC),
+		intervaloMedio: 250 * time.Millisecond,
+	}
+}
+
+// Marcas e categorias mais frequentes ganham nomes reais; as demais são numeradas.
+var (
+	nomesMarcas     = []string{"samsung", "apple", "xiaomi", "huawei", "lucente", "sony", "lg", "lenovo", "acer", "bosch", "oppo", "respect", "cordiant", "artel", "elenberg", "indesit", "midea", "philips", "hp", "asus"}
+	nomesCategorias = []string{"electronics.smartphone", "electronics.audio.headphone", "electronics.video.tv", "appliances.kitchen.washer", "computers.notebook", "appliances.environment.vacuum", "apparel.shoes", "appliances.kitchen.refrigerators", "electronics.clocks", "furniture.living_room.sofa", "auto.accessories.player", "computers.desktop", "electronics.tablet", "kids.toys", "sport.bicycle"}
+)
+
+// deslocamentoZipf suaviza o topo da distribuição de sessões e usuários: com o v padrão
+// de 1, a sessão mais ativa ficaria com mais de 10% de todos os eventos.
+const deslocamentoZipf = 10
+
+type produtoGerado struct {
+	id          int32
+	categoriaID int32
+	categoria   string
+	marca       string
+	preco       float64
+}
+
+type pesoEvento struct {
+	tipo      string
+	acumulado float64
+}
+
+// gerador produz as linhas do CSV uma a uma. Tudo vem de um único gerador pseudoaleatório
+// com a semente da configuração, então a mesma configuração gera sempre o mesmo arquivo.
+type gerador struct {
+	cfg             configGerador
+	rng             *rand.Rand
+	zipfProdutos    *rand.Zipf
+	zipfSessoes     *rand.Zipf
+	produtos        []produtoGerado
+	sessoes         []string
+	usuarioDaSessao []int32
+	eventos         []pesoEvento
+	tempo           time.Time
+}
+
+func novoGerador(cfg configGerador) (*gerador, error) {
+	switch {
+	case cfg.linhas < 0 || cfg.linhas >= math.MaxInt32:
+		return nil, fmt.Errorf("número de linhas inválido: %d", cfg.linhas)
+	case cfg.produtos < 1 || cfg.marcas < 1 || cfg.categorias < 1 || cfg.usuarios < 1 || cfg.sessoes < 1:
+		return nil, fmt.Errorf("produtos, marcas, categorias, usuários e sessões precisam ser pelo menos 1")
+	}
+	for _, s := range []float64{cfg.zipfProdutos, cfg.zipfUsuarios, cfg.zipfSessoes} {
+		if s <= 1 {
+			return nil, fmt.Errorf("expoente Zipf %v inválido: precisa ser maior que 1", s)
+		}
+	}
+	for _, s := range []float64{cfg.zipfMarcas, cfg.zipfCategorias} {
+		if s != 0 && s <= 1 {
+			return nil, fmt.Errorf("expoente Zipf %v inválido: use 0 (uniforme) ou um valor maior que 1", s)
+		}
+	}
+
+	eventos, err := lerPesosEventos(cfg.eventos)
+	if err != nil {
+		return nil, err
+	}
+
+	rng := rand.New(rand.NewPCG(cfg.semente, 0x9e3779b97f4a7c15))
+	g := &gerador{
+		cfg:          cfg,
+		rng:          rng,
+		zipfProdutos: rand.NewZipf(rng, cfg.zipfProdutos, 1, uint64(cfg.produtos-1)),
+		zipfSessoes:  rand.NewZipf(rng, cfg.zipfSessoes, deslocamentoZipf, uint64(cfg.sessoes-1)),
+		eventos:      eventos,
+		tempo:        cfg.inicio,
+	}
+
+	sortearMarca := sorteio(rng, cfg.zipfMarcas, cfg.marcas)
+	sortearCategoria := sorteio(rng, cfg.zipfCategorias, cfg.categorias)
+	g.produtos = make([]produtoGerado, cfg.produtos)
+	for i := range g.produtos {
+		categoria := sortearCategoria()
+		g.produtos[i] = produtoGerado{
+			id:          int32(1000000 + i*7 + rng.IntN(7)),
+			categoriaID: int32(1000 + categoria),
+			categoria:   nomeNumerado(nomesCategorias, "category", categoria),
+			marca:       nomeNumerado(nomesMarcas, "brand", sortearMarca()),
+			// Preços com distribuição log-normal, em torno de US$ 150.
+			preco: math.Round(math.Exp(5+rng.NormFloat64())*100) / 100,
+		}
+	}
+
+	// Cada sessão pertence a um único usuário; usuários populares têm mais sessões.
+	zipfUsuarios := rand.NewZipf(rng, cfg.zipfUsuarios, deslocamentoZipf, uint64(cfg.usuarios-1))
+	g.sessoes = make([]string, cfg.sessoes)
+	g.usuarioDaSessao = make([]int32, cfg.sessoes)
+	for i := range g.sessoes {
+		g.sessoes[i] = fmt.Sprintf("%08x-%04x-%04x-%04x-%012x", rng.Uint32(), rng.Uint32()&0xffff, rng.Uint32()&0xffff, rng.Uint32()&0xffff, rng.Uint64()&0xffffffffffff)
+		g.usuarioDaSessao[i] = int32(500000000 + zipfUsuarios.Uint64())
+	}
+	return g, nil
+}
+
+// sorteio devolve uma função que sorteia um índice em [0, n), com Zipf de expoente s
+// ou uniforme quando s é 0.
+func sorteio(rng *rand.Rand, s float64, n int) func() int {
+	if s == 0 {
+		return func() int { return rng.IntN(n) }
+	}
+	z := rand.NewZipf(rng, s, 1, uint64(n-1))
+	return func() int { return int(z.Uint64()) }
+}
+
+func nomeNumerado(nomes []string, prefixo string, i int) string {
+	if i < len(nomes) {
+		return nomes[i]
+	}
+	return fmt.Sprintf("%s%d", prefixo, i)
+}
+
+// lerPesosEventos interpreta a mistura de eventos no formato "view=92,cart=5,purchase=3".
+func lerPesosEventos(s string) ([]pesoEvento, error) {
+	var eventos []pesoEvento
+	var total float64
+	for _, parte := range dividirLista(s) {
+		tipo, peso, ok := strings.Cut(parte, "=")
+		if !ok {
+			return nil, fmt.Errorf("evento %q inválido: use tipo=peso", parte)
+		}
+		p, err := strconv.ParseFloat(peso, 64)
+		if err != nil || p < 0 {
+			return nil, fmt.Errorf("peso inválido para o evento %s: %q", tipo, peso)
+		}
+		total += p
+		eventos = append(eventos, pesoEvento{strings.TrimSpace(tipo), total})
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("nenhum evento com peso positivo em %q", s)
+	}
+	for i := range eventos {
+		eventos[i].acumulado /= total
+	}
+	return eventos, nil
+}
+
+func (g *gerador) evento() string {
+	x := g.rng.Float64()
+	for _, e := range g.eventos {
+		if x < e.acumulado {
+			return e.tipo
+		}
+	}
+	return g.eventos[len(g.eventos)-1].tipo
+}
+
+// linha devolve a próxima linha do CSV, com event_time sempre crescente.
+func (g *gerador) linha() []string {
+	g.tempo = g.tempo.Add(time.Duration(g.rng.ExpFloat64() * float64(g.cfg.intervaloMedio)))
+	produto := g.produtos[g.zipfProdutos.Uint64()]
+	sessao := g.zipfSessoes.Uint64()
+
+	return []string{
+		g.tempo.Format("2006-01-02 15:04:05 UTC"),
+		g.evento(),
+		strconv.Itoa(int(produto.id)),
+		strconv.Itoa(int(produto.categoriaID)),
+		produto.categoria,
+		produto.marca,
+		strconv.FormatFloat(produto.preco, 'f', 2, 64),
+		strconv.Itoa(int(g.usuarioDaSessao[sessao])),
+		g.sessoes[sessao],
+	}
+}
+
+// gerarCSV escreve cfg.linhas eventos sintéticos, com o mesmo cabeçalho e as mesmas
+// colunas dos CSVs importados por processCSV.
+func gerarCSV(w io.Writer, cfg configGerador) error {
+	g, err := novoGerador(cfg)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	err = writer.Write(cabecalhoCSV)
+	for i := 0; i < cfg.linhas && err == nil; i++ {
+		err = writer.Write(g.linha())
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao escrever o CSV: %w", err)
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("erro ao escrever o CSV: %w", err)
+	}
+	return nil
+}
+
+// gerarBinarios grava os arquivos de dados como processCSV gravaria a partir do CSV de
+// gerarCSV, sem passar pelo texto, e cria os índices e as sequências.
+func gerarBinarios(arquivos Arquivos, cfg configGerador) error {
+	g, err := novoGerador(cfg)
+	if err != nil {
+		return err
+	}
+
+	fileProd, err := criarArquivo(arquivos.Armazenamento, arquivos.Produtos)
+	if err != nil {
+		return fmt.Errorf("erro ao criar arquivo de produtos: %w", err)
+	}
+	defer fileProd.Close()
+
+	fileAcess, err := criarArquivo(arquivos.Armazenamento, arquivos.Acessos)
+	if err != nil {
+		return fmt.Errorf("erro ao criar arquivo de acessos: %w", err)
+	}
+	defer fileAcess.Close()
+
+	writerProd := bufio.NewWriterSize(fileProd, tamanhoBufferLeitura)
+	writerAcess := bufio.NewWriterSize(fileAcess, tamanhoBufferLeitura)
+	var registroProd [tamanhoProduto]byte
+	var registroAcess [tamanhoAcesso]byte
+
+	for i := range cfg.linhas {
+		produto, acesso, err := registrosDaLinha(g.linha())
+		if err != nil {
+			return err
+		}
+		produto.ID = int32ToBytes(int32(i + 1))
+		acesso.ID = int32ToBytes(int32(i + 1))
+
+		codificarProduto(registroProd[:], &produto)
+		codificarAcesso(registroAcess[:], &acesso)
+		if _, err := writerProd.Write(registroProd[:]); err != nil {
+			return fmt.Errorf("erro ao escrever produto no arquivo binário: %w", err)
+		}
+		if _, err := writerAcess.Write(registroAcess[:]); err != nil {
+			return fmt.Errorf("erro ao escrever acesso no arquivo: %w", err)
+		}
+	}
+
+	for _, w := range []*bufio.Writer{writerProd, writerAcess} {
+		if err := w.Flush(); err != nil {
+			return fmt.Errorf("erro ao escrever os arquivos binários: %w", err)
+		}
+	}
+	for _, f := range []Arquivo{fileProd, fileAcess} {
+		if err := f.Sync(); err != nil {
+			return fmt.Errorf("erro ao escrever os arquivos binários: %w", err)
+		}
+	}
+
+	if err := gravarSequencia(arquivos.Armazenamento, arquivos.Produtos, int32(cfg.linhas+1)); err != nil {
+		return err
+	}
+	if err := gravarSequencia(arquivos.Armazenamento, arquivos.Acessos, int32(cfg.linhas+1)); err != nil {
+		return err
+	}
+	return reindexar(arquivos)
+}
@@ -0,0 +1,3 @@
+module banco
+
+go 1.23
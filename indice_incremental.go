@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+)
+
+// adicionarAoIndice registra id -> offset num índice de ID (de produtos ou de acessos).
+// IDs novos vêm da sequência e são sempre maiores que os existentes, então no caso
+// comum a entrada só é anexada. Um ID fora de ordem é inserido na posição achada por
+// busca binária, deslocando as entradas seguintes. Se o índice ainda não existir, quem
+// chama deve criá-lo por completo.
+func adicionarAoIndice(armazenamento Armazenamento, indexName string, id [4]byte, offset int64) error {
+	indexFile, err := armazenamento.Abrir(indexName, os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("erro ao abrir o arquivo de índice: %w", err)
+	}
+	defer indexFile.Close()
+
+	pos, total, err := posicaoInicialIndice(indexFile, tamanhoIndiceID, bytesToInt32(id))
+	if err != nil {
+		return err
+	}
+
+	if pos < total {
+		var chave [4]byte
+		_, err = indexFile.ReadAt(chave[:], pos*tamanhoIndiceID)
+		if err != nil {
+			return fmt.Errorf("erro ao ler registro de índice: %w", erroRegistro(indexName, pos*tamanhoIndiceID, err))
+		}
+		if chave == id {
+			return fmt.Errorf("%w: o ID %d já está no índice %s", ErrDuplicateID, bytesToInt32(id), indexName)
+		}
+	}
+
+	var entrada [tamanhoIndiceID]byte
+	codificarEntradaIndice(entrada[:], id, offset)
+	return inserirEntradaIndice(armazenamento, indexFile, pos, total, entrada[:])
+}
+
+// adicionarAoIndicePrecos registra a entrada no delta do índice de preços; as consultas
+// a intercalam com a base onde criarIndicePrecos a deixaria: por preço e, entre preços
+// iguais, pela ordem no arquivo de produtos.
+func adicionarAoIndicePrecos(armazenamento Armazenamento, indexPreco string, preco [8]byte, offset int64) error {
+	err := anexarAoDelta(armazenamento, indexPreco, adicaoDelta, []entradaPreco{{bytesToPreco(preco), offset}})
+	if err != nil {
+		return err
+	}
+	return compactarSeNecessario(armazenamento, indexPreco)
+}
+
+// removerDoIndicePrecos tira a entrada do produto em offset, cujo preço no índice é
+// preco. Se a entrada não estiver lá, o índice está inconsistente e é removido, para
+// que abrirBanco o recrie.
+func removerDoIndicePrecos(armazenamento Armazenamento, indexPreco string, preco [8]byte, offset int64) error {
+	indice, err := abrirIndicePrecos(armazenamento, indexPreco)
+	if err != nil {
+		return err
+	}
+	entrada := entradaPreco{bytesToPreco(preco), offset}
+	existe, err := indice.contem(entrada)
+	indice.fechar()
+	if err != nil {
+		return err
+	}
+	if !existe {
+		return invalidarIndicePrecos(armazenamento, indexPreco, fmt.Errorf("%w: %s não tem a entrada do produto no offset %d", ErrCorrupt, indexPreco, offset))
+	}
+
+	err = anexarAoDelta(armazenamento, indexPreco, remocaoDelta, []entradaPreco{entrada})
+	if err != nil {
+		return err
+	}
+	return compactarSeNecessario(armazenamento, indexPreco)
+}
+
+// inserirEntradaIndice grava entrada na posição pos de um índice com total entradas,
+// movendo as entradas de pos em diante uma posição para a frente. Uma escrita que falha
+// no meio do deslocamento deixa o índice com entradas repetidas ou perdidas; nesse caso
+// ele é removido, e abrirBanco o recria na próxima abertura.
+func inserirEntradaIndice(armazenamento Armazenamento, indexFile Arquivo, pos int64, total int64, entrada []byte) error {
+	tamanho := int64(len(entrada))
+
+	if pos < total {
+		resto := make([]byte, (total-pos)*tamanho)
+		_, err := indexFile.ReadAt(resto, pos*tamanho)
+		if err != nil {
+			return fmt.Errorf("erro ao ler registro de índice: %w", erroRegistro(indexFile.Name(), pos*tamanho, err))
+		}
+
+		_, err = indexFile.WriteAt(resto, (pos+1)*tamanho)
+		if err != nil {
+			return invalidarIndice(armazenamento, indexFile.Name(), fmt.Errorf("erro ao escrever no arquivo de índice: %w", err))
+		}
+	}
+
+	_, err := indexFile.WriteAt(entrada, pos*tamanho)
+	if err != nil {
+		return invalidarIndice(armazenamento, indexFile.Name(), fmt.Errorf("erro ao escrever no arquivo de índice: %w", err))
+	}
+	return nil
+}
+
+func invalidarIndice(armazenamento Armazenamento, indexName string, err error) error {
+	if errRemover := armazenamento.Remover(indexName); errRemover != nil {
+		return fmt.Errorf("%w (e erro ao remover o índice inválido: %v)", err, errRemover)
+	}
+	return err
+}
+
+// abandonarIndice trata a falha ao atualizar um índice depois que os dados já foram
+// gravados. Devolver o erro faria quem chama repetir uma escrita que aconteceu, então o
+// erro só é registrado no log e o índice é removido, para que nenhuma consulta use
+// offsets velhos. A próxima escrita ou abrirBanco recria o índice; se nem a remoção der
+// certo, o índice fica mais antigo que os dados e abrirBanco o recria do mesmo jeito.
+func abandonarIndice(armazenamento Armazenamento, indexName string, err error) {
+	log.Printf("índice %s não foi atualizado (%v); ele será recriado", indexName, err)
+	if errRemover := armazenamento.Remover(indexName); errRemover != nil && !errors.Is(errRemover, os.ErrNotExist) {
+		log.Printf("erro ao remover o índice %s: %v", indexName, errRemover)
+	}
+}
+
+// tamanhoArquivo devolve o tamanho de filename, ou 0 se ele não existir. Antes de um
+// append, é o offset que o novo registro vai ocupar.
+func tamanhoArquivo(armazenamento Armazenamento, filename string) (int64, error) {
+	info, err := armazenamento.Info(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("erro ao obter informações do arquivo %s: %w", filename, err)
+	}
+	return info.Size(), nil
+}
+
+// indiceExiste informa se o índice já foi criado; sem ele, a atualização incremental
+// não tem base e o índice precisa ser criado do zero.
+func indiceExiste(armazenamento Armazenamento, indexName string) (bool, error) {
+	_, err := armazenamento.Info(indexName)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("erro ao verificar o índice %s: %w", indexName, err)
+	}
+	return true, nil
+}
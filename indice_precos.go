@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+type IndexPreco struct {
+	Price  [8]byte // Preço do produto (chave, centavos em int64)
+	Offset [8]byte // Posição do registro no arquivo de produtos
+}
+
+func criarIndicePrecos(armazenamento Armazenamento, filenameProd string, indexPreco string) error {
+	scanner, err := abrirScannerProdutos(armazenamento, filenameProd)
+	if err != nil {
+		return err
+	}
+	defer scanner.fechar()
+
+	var entradas []IndexPreco
+
+	for offset, produto := range scanner.todos() {
+		var index IndexPreco
+		index.Price = produto.Price
+		binary.LittleEndian.PutUint64(index.Offset[:], uint64(offset))
+		entradas = append(entradas, index)
+	}
+	if scanner.erro() != nil {
+		return fmt.Errorf("erro ao ler registro de produto: %w", scanner.erro())
+	}
+
+	// Preços iguais ficam na ordem do arquivo de produtos.
+	sort.SliceStable(entradas, func(i, j int) bool {
+		return bytesToPreco(entradas[i].Price) < bytesToPreco(entradas[j].Price)
+	})
+
+	// O delta é das escritas feitas sobre a base velha; a base nova já tem tudo.
+	if err := removerDeltaPrecos(armazenamento, indexPreco); err != nil {
+		return err
+	}
+
+	return gravarAtomicamente(armazenamento, indexPreco, func(w io.Writer) error {
+		var entrada [tamanhoIndicePreco]byte
+		for _, index := range entradas {
+			copy(entrada[0:8], index.Price[:])
+			copy(entrada[8:16], index.Offset[:])
+			_, err := w.Write(entrada[:])
+			if err != nil {
+				return fmt.Errorf("erro ao escrever índice de preço: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func totalEntradasIndicePreco(indexFile Arquivo) (int64, error) {
+	indexFileInfo, err := indexFile.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("erro ao obter informações do arquivo de índice: %w", err)
+	}
+	tamanhoEntrada := int64(binary.Size(IndexPreco{}))
+	if indexFileInfo.Size()%tamanhoEntrada != 0 {
+		return 0, fmt.Errorf("%w: tamanho de %s (%d bytes) não é múltiplo da entrada de índice", ErrCorrupt, indexFile.Name(), indexFileInfo.Size())
+	}
+	return indexFileInfo.Size() / tamanhoEntrada, nil
+}
+
+// posicaoInicialIndicePreco retorna a posição da primeira entrada com preço >= preco
+// e o total de entradas do índice de preços.
+func posicaoInicialIndicePreco(indexFile Arquivo, preco Preco) (int64, int64, error) {
+	total, err := totalEntradasIndicePreco(indexFile)
+	if err != nil {
+		return 0, 0, err
+	}
+	tamanhoEntrada := int64(binary.Size(IndexPreco{}))
+
+	var chave [8]byte
+	start, end := int64(0), total
+	for start < end {
+		mid := (start + end) / 2
+		_, err := indexFile.ReadAt(chave[:], mid*tamanhoEntrada)
+		if err != nil {
+			return 0, 0, fmt.Errorf("erro ao ler registro de índice de preço: %w", erroRegistro(indexFile.Name(), mid*tamanhoEntrada, err))
+		}
+
+		if bytesToPreco(chave) < preco {
+			start = mid + 1
+		} else {
+			end = mid
+		}
+	}
+
+	return start, total, nil
+}
+
+// posicaoEntradaIndicePreco retorna a posição da primeira entrada com chave (preço,
+// offset) >= (preco, offset), a ordem em que criarIndicePrecos deixa o índice: é onde a
+// entrada do produto em offset está ou deveria estar.
+func posicaoEntradaIndicePreco(indexFile Arquivo, preco Preco, offset int64) (int64, int64, error) {
+	total, err := totalEntradasIndicePreco(indexFile)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var entrada [tamanhoIndicePreco]byte
+	start, end := int64(0), total
+	for start < end {
+		mid := (start + end) / 2
+		_, err := indexFile.ReadAt(entrada[:], mid*tamanhoIndicePreco)
+		if err != nil {
+			return 0, 0, fmt.Errorf("erro ao ler registro de índice de preço: %w", erroRegistro(indexFile.Name(), mid*tamanhoIndicePreco, err))
+		}
+
+		precoEntrada := bytesToPreco([8]byte(entrada[0:8]))
+		offsetEntrada := int64(binary.LittleEndian.Uint64(entrada[8:16]))
+		if precoEntrada < preco || precoEntrada == preco && offsetEntrada < offset {
+			start = mid + 1
+		} else {
+			end = mid
+		}
+	}
+
+	return start, total, nil
+}
+
+// percorrerProdutosPorPreco visita os produtos em ordem de preço, crescente a partir do
+// primeiro preço >= de ou decrescente a partir do último preço <= de, até fn retornar
+// false.
+func percorrerProdutosPorPreco(armazenamento Armazenamento, indexPreco string, filenameProd string, de Preco, crescente bool, fn func(Produto) bool) error {
+	indice, err := abrirIndicePrecos(armazenamento, indexPreco)
+	if err != nil {
+		return err
+	}
+	defer indice.fechar()
+
+	file, err := abrirArquivo(armazenamento, filenameProd)
+	if err != nil {
+		return fmt.Errorf("erro ao abrir o arquivo de produtos: %w", err)
+	}
+	defer file.Close()
+
+	var erroProduto error
+	var registro [tamanhoProduto]byte
+	var produto Produto
+	err = indice.percorrer(de, crescente, func(entrada entradaPreco) bool {
+		_, erroProduto = file.ReadAt(registro[:], entrada.offset)
+		if erroProduto != nil {
+			erroProduto = fmt.Errorf("erro ao ler registro de produto: %w", erroRegistro(filenameProd, entrada.offset, erroProduto))
+			return false
+		}
+		decodificarProduto(registro[:], &produto)
+		return fn(produto)
+	})
+	if err != nil {
+		return err
+	}
+	return erroProduto
+}
+
+func consultarProdutosPorFaixaDePreco(armazenamento Armazenamento, indexPreco string, filenameProd string, precoMinimo Preco, precoMaximo Preco) ([]Produto, error) {
+	var produtos []Produto
+	err := percorrerProdutosPorPreco(armazenamento, indexPreco, filenameProd, precoMinimo, true, func(produto Produto) bool {
+		if bytesToPreco(produto.Price) > precoMaximo {
+			return false
+		}
+		produtos = append(produtos, produto)
+		return true
+	})
+	return produtos, err
+}
+
+func topProdutosPorPreco(armazenamento Armazenamento, indexPreco string, filenameProd string, n int, maisCaros bool) ([]Produto, error) {
+	var produtos []Produto
+	if n <= 0 {
+		return produtos, nil
+	}
+
+	de := Preco(math.MinInt64)
+	if maisCaros {
+		de = math.MaxInt64
+	}
+	err := percorrerProdutosPorPreco(armazenamento, indexPreco, filenameProd, de, !maisCaros, func(produto Produto) bool {
+		produtos = append(produtos, produto)
+		return len(produtos) < n
+	})
+	return produtos, err
+}
+
+// encontrarProdutoMaisCaro devolve o primeiro produto do arquivo com o maior preço: acha
+// o maior preço no fim do índice e volta à primeira entrada com ele. Como as outras
+// consultas, só lê o índice; recriá-lo é com abrirBanco ou reindexar.
+func encontrarProdutoMaisCaro(armazenamento Armazenamento, indexPreco string, filenameProd string) (Produto, error) {
+	indice, err := abrirIndicePrecos(armazenamento, indexPreco)
+	if err != nil {
+		return Produto{}, err
+	}
+	defer indice.fechar()
+
+	var maior, primeira *entradaPreco
+	err = indice.percorrer(math.MaxInt64, false, func(entrada entradaPreco) bool {
+		maior = &entrada
+		return false
+	})
+	if err == nil && maior != nil {
+		err = indice.percorrer(maior.preco, true, func(entrada entradaPreco) bool {
+			primeira = &entrada
+			return false
+		})
+	}
+	if err != nil {
+		return Produto{}, err
+	}
+	if primeira == nil {
+		return Produto{}, fmt.Errorf("produto %w: arquivo vazio", ErrNotFound)
+	}
+
+	return buscarProdutoPorOffset(armazenamento, filenameProd, primeira.offset)
+}
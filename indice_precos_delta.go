@@ -0,0 +1,417 @@
+package main
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"slices"
+)
+
+// O índice de preços é uma base, ordenada por (preço, offset) como criarIndicePrecos a
+// deixa, mais um delta em <índice>.delta onde cada inserção, atualização ou remoção de
+// produto só anexa operações. Assim uma escrita custa um append em vez de deslocar
+// metade do índice. As consultas leem o delta, que é pequeno, e o intercalam com a base;
+// quando ele passa de limiteDeltaPrecos, compactarIndicePrecos o incorpora à base.
+//
+// Cada operação é a entrada de 16 bytes da base seguida de um byte com o tipo. A base
+// vale até a data mais recente entre ela e o delta. Por isso o delta é removido antes de
+// uma base nova ser gravada: se o processo cair entre as duas coisas, sobra uma base
+// mais antiga que os dados, e abrirBanco a recria.
+const (
+	tamanhoOperacaoDelta = tamanhoIndicePreco + 1
+
+	adicaoDelta  byte = 'A'
+	remocaoDelta byte = 'R'
+
+	// minimoDeltaPrecos é o menor limite de operações do delta. Acima dele, o limite
+	// cresce com a raiz quadrada da base: a compactação, que é linear, acontece a cada
+	// √n escritas, e cada consulta lê no máximo √n operações.
+	minimoDeltaPrecos = 1024
+)
+
+func arquivoDelta(indexPreco string) string {
+	return indexPreco + ".delta"
+}
+
+func limiteDeltaPrecos(totalBase int64) int64 {
+	return max(minimoDeltaPrecos, int64(math.Sqrt(float64(totalBase))))
+}
+
+// entradaPreco é uma entrada do índice de preços já decodificada.
+type entradaPreco struct {
+	preco  Preco
+	offset int64
+}
+
+func entradaPrecoDe(b []byte) entradaPreco {
+	return entradaPreco{bytesToPreco([8]byte(b[0:8])), int64(binary.LittleEndian.Uint64(b[8:16]))}
+}
+
+func (e entradaPreco) codificar(b []byte) {
+	codificarEntradaIndicePreco(b, precoToBytes(e.preco), e.offset)
+}
+
+func compararEntradasPreco(a entradaPreco, b entradaPreco) int {
+	if c := cmp.Compare(a.preco, b.preco); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.offset, b.offset)
+}
+
+// deltaPrecos é o saldo das operações do delta: as entradas que ele acrescenta à base e
+// as entradas da base que ele remove.
+type deltaPrecos struct {
+	nome      string
+	operacoes int64
+	adicoes   []entradaPreco // em ordem de (preço, offset)
+	remocoes  map[entradaPreco]bool
+	saldos    map[entradaPreco]int
+	// posicoes guarda o offset, no delta, da última operação de cada entrada.
+	posicoes map[entradaPreco]int64
+}
+
+// lerDeltaPrecos lê o delta do índice indexPreco; sem delta, o saldo é vazio. Um delta
+// com operação incompleta, de tipo desconhecido ou que adiciona ou remove a mesma entrada
+// duas vezes seguidas é devolvido como ErrCorrupt.
+func lerDeltaPrecos(armazenamento Armazenamento, indexPreco string) (*deltaPrecos, error) {
+	d := &deltaPrecos{
+		nome:     arquivoDelta(indexPreco),
+		remocoes: make(map[entradaPreco]bool),
+		saldos:   make(map[entradaPreco]int),
+		posicoes: make(map[entradaPreco]int64),
+	}
+
+	dados, err := lerArquivoInteiro(armazenamento, d.nome)
+	if errors.Is(err, os.ErrNotExist) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler o delta %s: %w", d.nome, err)
+	}
+	if resto := len(dados) % tamanhoOperacaoDelta; resto != 0 {
+		return nil, &RecordError{Arquivo: d.nome, Offset: int64(len(dados) - resto), Err: fmt.Errorf("%w: operação incompleta", ErrCorrupt)}
+	}
+
+	d.operacoes = int64(len(dados) / tamanhoOperacaoDelta)
+	for i := range d.operacoes {
+		offset := i * tamanhoOperacaoDelta
+		operacao := dados[offset : offset+tamanhoOperacaoDelta]
+		e := entradaPrecoDe(operacao)
+
+		switch operacao[tamanhoIndicePreco] {
+		case adicaoDelta:
+			d.saldos[e]++
+		case remocaoDelta:
+			d.saldos[e]--
+		default:
+			return nil, &RecordError{Arquivo: d.nome, Offset: offset, Err: fmt.Errorf("%w: tipo de operação desconhecido %q", ErrCorrupt, operacao[tamanhoIndicePreco])}
+		}
+		if d.saldos[e] < -1 || d.saldos[e] > 1 {
+			return nil, &RecordError{Arquivo: d.nome, Offset: offset, Err: fmt.Errorf("%w: a entrada de preço %s e offset %d aparece duas vezes", ErrCorrupt, e.preco, e.offset)}
+		}
+		d.posicoes[e] = offset
+	}
+
+	for e, saldo := range d.saldos {
+		switch saldo {
+		case 1:
+			d.adicoes = append(d.adicoes, e)
+		case -1:
+			d.remocoes[e] = true
+		}
+	}
+	slices.SortFunc(d.adicoes, compararEntradasPreco)
+	return d, nil
+}
+
+// mesclar chama fn com cada entrada do índice, base e delta intercalados, em ordem. fn
+// pode alterar a entrada que recebe.
+func (d *deltaPrecos) mesclar(base Arquivo, indexPreco string, fn func(entrada []byte) error) error {
+	var entrada [tamanhoIndicePreco]byte
+	adicoes := d.adicoes
+	emitirAdicoesAte := func(limite *entradaPreco) error {
+		for len(adicoes) > 0 && (limite == nil || compararEntradasPreco(adicoes[0], *limite) < 0) {
+			adicoes[0].codificar(entrada[:])
+			if err := fn(entrada[:]); err != nil {
+				return err
+			}
+			adicoes = adicoes[1:]
+		}
+		return nil
+	}
+
+	scanner := novoScannerRegistros(base, indexPreco, tamanhoIndicePreco)
+	for scanner.ler() {
+		e := entradaPrecoDe(scanner.registro)
+		if err := emitirAdicoesAte(&e); err != nil {
+			return err
+		}
+		if d.remocoes[e] {
+			continue
+		}
+		if err := fn(scanner.registro); err != nil {
+			return err
+		}
+	}
+	if scanner.erro() != nil {
+		return fmt.Errorf("erro ao ler registro de índice de preço: %w", scanner.erro())
+	}
+	return emitirAdicoesAte(nil)
+}
+
+// anexarAoDelta registra as operações do tipo pedido no delta do índice, numa única
+// escrita sincronizada com o disco. Se ela falhar, o delta pode ter ficado com uma
+// operação pela metade, e o índice inteiro é invalidado.
+func anexarAoDelta(armazenamento Armazenamento, indexPreco string, tipo byte, entradas []entradaPreco) error {
+	if _, err := armazenamento.Info(indexPreco); err != nil {
+		return fmt.Errorf("erro ao abrir o arquivo de índice de preços: %w", err)
+	}
+
+	operacoes := make([]byte, len(entradas)*tamanhoOperacaoDelta)
+	for i, e := range entradas {
+		operacao := operacoes[i*tamanhoOperacaoDelta : (i+1)*tamanhoOperacaoDelta]
+		e.codificar(operacao)
+		operacao[tamanhoIndicePreco] = tipo
+	}
+
+	nome := arquivoDelta(indexPreco)
+	file, err := armazenamento.Abrir(nome, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("erro ao abrir o delta %s: %w", nome, err)
+	}
+	_, err = file.Write(operacoes)
+	if err == nil {
+		err = file.Sync()
+	}
+	if errClose := file.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return invalidarIndicePrecos(armazenamento, indexPreco, fmt.Errorf("erro ao escrever no delta %s: %w", nome, err))
+	}
+	return nil
+}
+
+// compactarSeNecessario incorpora o delta à base quando ele passa do limite. As operações
+// já estão no delta, então uma compactação que falha não desfaz a escrita de quem chama:
+// o índice é abandonado e recriado depois.
+func compactarSeNecessario(armazenamento Armazenamento, indexPreco string) error {
+	infoBase, err := armazenamento.Info(indexPreco)
+	if err != nil {
+		return fmt.Errorf("erro ao obter informações do arquivo de índice: %w", err)
+	}
+	infoDelta, err := armazenamento.Info(arquivoDelta(indexPreco))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao obter informações do delta: %w", err)
+	}
+
+	if infoDelta.Size()/tamanhoOperacaoDelta <= limiteDeltaPrecos(infoBase.Size()/tamanhoIndicePreco) {
+		return nil
+	}
+	if err := compactarIndicePrecos(armazenamento, indexPreco); err != nil {
+		abandonarIndice(armazenamento, indexPreco, err)
+	}
+	return nil
+}
+
+// compactarIndicePrecos grava a base com as operações do delta já aplicadas e remove o
+// delta.
+func compactarIndicePrecos(armazenamento Armazenamento, indexPreco string) error {
+	return reescreverIndicePrecos(armazenamento, indexPreco, func(w io.Writer, entrada []byte) error {
+		_, err := w.Write(entrada)
+		return err
+	})
+}
+
+// reescreverIndicePrecos passa cada entrada do índice de preços, base e delta já
+// intercalados, por entrada, que escreve o que deve ficar no lugar dela. O resultado
+// vira a base nova, sem delta. O delta é removido antes de a base ser trocada; se a troca
+// falhar, a base velha não vale mais sozinha e o índice é invalidado.
+func reescreverIndicePrecos(armazenamento Armazenamento, indexPreco string, entrada func(w io.Writer, entrada []byte) error) error {
+	delta, err := lerDeltaPrecos(armazenamento, indexPreco)
+	if err != nil {
+		return err
+	}
+
+	indexFile, err := abrirArquivo(armazenamento, indexPreco)
+	if err != nil {
+		return fmt.Errorf("erro ao abrir o arquivo de índice de preços: %w", err)
+	}
+	defer indexFile.Close()
+
+	if err := removerDeltaPrecos(armazenamento, indexPreco); err != nil {
+		return err
+	}
+
+	err = gravarAtomicamente(armazenamento, indexPreco, func(w io.Writer) error {
+		return delta.mesclar(indexFile, indexPreco, func(e []byte) error {
+			if err := entrada(w, e); err != nil {
+				return fmt.Errorf("erro ao escrever índice de preço: %w", err)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return invalidarIndice(armazenamento, indexPreco, err)
+	}
+	return nil
+}
+
+func removerDeltaPrecos(armazenamento Armazenamento, indexPreco string) error {
+	err := armazenamento.Remover(arquivoDelta(indexPreco))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("erro ao remover o delta de %s: %w", indexPreco, err)
+	}
+	return nil
+}
+
+// invalidarIndicePrecos remove o delta e a base, para que abrirBanco recrie o índice.
+func invalidarIndicePrecos(armazenamento Armazenamento, indexPreco string, err error) error {
+	if errRemover := removerDeltaPrecos(armazenamento, indexPreco); errRemover != nil {
+		log.Print(errRemover)
+	}
+	return invalidarIndice(armazenamento, indexPreco, err)
+}
+
+// tocarIndicePrecos marca o índice como em dia depois de uma escrita no arquivo de
+// produtos que não muda preços. A data que vale é a do delta, se ele existir: avançar a
+// da base faria uma base sem o delta parecer válida.
+func tocarIndicePrecos(armazenamento Armazenamento, indexPreco string) error {
+	nome := arquivoDelta(indexPreco)
+	if _, err := armazenamento.Info(nome); err == nil {
+		return tocarIndice(armazenamento, nome)
+	}
+	return tocarIndice(armazenamento, indexPreco)
+}
+
+// indicePrecosAberto é o índice de preços aberto para consultas: a base, lida por
+// posição, e o saldo do delta, em memória.
+type indicePrecosAberto struct {
+	nome  string
+	base  Arquivo
+	total int64
+	delta *deltaPrecos
+}
+
+func abrirIndicePrecos(armazenamento Armazenamento, indexPreco string) (*indicePrecosAberto, error) {
+	delta, err := lerDeltaPrecos(armazenamento, indexPreco)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := abrirArquivo(armazenamento, indexPreco)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir o arquivo de índice de preços: %w", err)
+	}
+	total, err := totalEntradasIndicePreco(base)
+	if err != nil {
+		base.Close()
+		return nil, err
+	}
+	return &indicePrecosAberto{nome: indexPreco, base: base, total: total, delta: delta}, nil
+}
+
+func (ix *indicePrecosAberto) fechar() error {
+	return ix.base.Close()
+}
+
+func (ix *indicePrecosAberto) entradaBase(pos int64) (entradaPreco, error) {
+	var entrada [tamanhoIndicePreco]byte
+	_, err := ix.base.ReadAt(entrada[:], pos*tamanhoIndicePreco)
+	if err != nil {
+		return entradaPreco{}, fmt.Errorf("erro ao ler registro de índice de preço: %w", erroRegistro(ix.nome, pos*tamanhoIndicePreco, err))
+	}
+	return entradaPrecoDe(entrada[:]), nil
+}
+
+// percorrer visita as entradas do índice em ordem crescente a partir do primeiro preço
+// >= de, ou em ordem decrescente a partir do último preço <= de, até fn retornar false.
+// A base é lida a partir da posição achada por busca binária, e o delta, intercalado.
+func (ix *indicePrecosAberto) percorrer(de Preco, crescente bool, fn func(entradaPreco) bool) error {
+	adicoes := ix.delta.adicoes
+	var pos, passo int64
+	var a, passoAdicoes int
+	if crescente {
+		inicio, _, err := posicaoInicialIndicePreco(ix.base, de)
+		if err != nil {
+			return err
+		}
+		pos, passo = inicio, 1
+		a, _ = slices.BinarySearchFunc(adicoes, de, func(e entradaPreco, p Preco) int { return cmp.Compare(e.preco, p) })
+		passoAdicoes = 1
+	} else {
+		pos = ix.total
+		if de < math.MaxInt64 {
+			fim, _, err := posicaoInicialIndicePreco(ix.base, de+1)
+			if err != nil {
+				return err
+			}
+			pos = fim
+		}
+		pos, passo = pos-1, -1
+		a, _ = slices.BinarySearchFunc(adicoes, de, func(e entradaPreco, p Preco) int {
+			if e.preco <= p {
+				return -1
+			}
+			return 1
+		})
+		a, passoAdicoes = a-1, -1
+	}
+
+	var proxima *entradaPreco
+	for {
+		// proxima é a entrada da base ainda não visitada, já sem as removidas pelo delta.
+		for proxima == nil && pos >= 0 && pos < ix.total {
+			e, err := ix.entradaBase(pos)
+			if err != nil {
+				return err
+			}
+			pos += passo
+			if !ix.delta.remocoes[e] {
+				proxima = &e
+			}
+		}
+
+		temAdicao := a >= 0 && a < len(adicoes)
+		if proxima == nil && !temAdicao {
+			return nil
+		}
+
+		var e entradaPreco
+		if temAdicao && (proxima == nil || (compararEntradasPreco(adicoes[a], *proxima) < 0) == crescente) {
+			e = adicoes[a]
+			a += passoAdicoes
+		} else {
+			e = *proxima
+			proxima = nil
+		}
+		if !fn(e) {
+			return nil
+		}
+	}
+}
+
+// contem informa se a entrada está no índice, na base ou no delta.
+func (ix *indicePrecosAberto) contem(e entradaPreco) (bool, error) {
+	switch ix.delta.saldos[e] {
+	case 1:
+		return true, nil
+	case -1:
+		return false, nil
+	}
+
+	pos, total, err := posicaoEntradaIndicePreco(ix.base, e.preco, e.offset)
+	if err != nil || pos == total {
+		return false, err
+	}
+	atual, err := ix.entradaBase(pos)
+	return atual == e, err
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// conferirIndicePrecos compara o índice de preços mantido incrementalmente, base e delta
+// intercalados, com um recriado do zero por criarIndicePrecos, byte a byte: além do
+// preço, a ordem entre preços iguais também tem de ser a mesma.
+func conferirIndicePrecos(t *testing.T, arquivos Arquivos) {
+	t.Helper()
+	recriado := filepath.Join(t.TempDir(), "indice_precos.dat")
+	if err := criarIndicePrecos(arquivos.Armazenamento, arquivos.Produtos, recriado); err != nil {
+		t.Fatal(err)
+	}
+
+	esperado, err := lerArquivoInteiro(arquivos.Armazenamento, recriado)
+	if err != nil {
+		t.Fatal(err)
+	}
+	atual := entradasIndicePrecos(t, arquivos.Armazenamento, arquivos.IndicePrecos)
+	if !bytes.Equal(atual, esperado) {
+		t.Errorf("índice de preços difere do recriado: %d bytes, quero %d", len(atual), len(esperado))
+	}
+}
+
+// entradasIndicePrecos devolve as entradas do índice como as consultas o veem.
+func entradasIndicePrecos(t *testing.T, armazenamento Armazenamento, indexPreco string) []byte {
+	t.Helper()
+	delta, err := lerDeltaPrecos(armazenamento, indexPreco)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := abrirArquivo(armazenamento, indexPreco)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer base.Close()
+
+	var entradas []byte
+	err = delta.mesclar(base, indexPreco, func(entrada []byte) error {
+		entradas = append(entradas, entrada...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entradas
+}
+
+func TestAtualizarProdutoMantemIndicePrecos(t *testing.T) {
+	b := abrirBancoTeste(t)
+	precos := []Preco{500, 100, 300, 100, 500, 300}
+	produtos := make([]Produto, len(precos))
+	for i, preco := range precos {
+		produtos[i] = produtoTeste(t, int32(i), preco, "m")
+	}
+	produtos, err := b.inserirProdutos(produtos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, caso := range []struct {
+		indice int
+		preco  Preco
+	}{
+		{0, 50},  // vai para o início
+		{1, 900}, // vai para o fim
+		{2, 100}, // empata com produtos antes e depois dele no arquivo
+		{5, 300}, // preço igual: nada muda de lugar
+		{4, 100},
+	} {
+		produto := produtos[caso.indice]
+		produto.Price = precoToBytes(caso.preco)
+		if err := b.atualizarProduto(produto); err != nil {
+			t.Fatal(err)
+		}
+		conferirIndicePrecos(t, b.arquivos)
+	}
+
+	produto := produtos[3]
+	produto.Price = precoToBytes(700)
+	if err := b.salvarProduto(produto); err != nil {
+		t.Fatal(err)
+	}
+	conferirIndicePrecos(t, b.arquivos)
+
+	maisCaro, err := encontrarProdutoMaisCaro(b.arquivos.Armazenamento, b.arquivos.IndicePrecos, b.arquivos.Produtos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maisCaro.ID != produtos[1].ID {
+		t.Errorf("mais caro = produto %d, quero %d", bytesToInt32(maisCaro.ID), bytesToInt32(produtos[1].ID))
+	}
+
+	problemas, err := verificarArquivos(b.arquivos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problemas) > 0 {
+		t.Errorf("arquivos inconsistentes depois das atualizações: %v", problemas)
+	}
+}
+
+func TestLotesMantemIndicePrecos(t *testing.T) {
+	b := abrirBancoTeste(t)
+	lote := func(precos ...Preco) []Produto {
+		produtos := make([]Produto, len(precos))
+		for i, preco := range precos {
+			produtos[i] = produtoTeste(t, int32(i), preco, "m")
+		}
+		return produtos
+	}
+
+	if _, err := b.inserirProdutos(lote(300, 100, 300, 200)); err != nil {
+		t.Fatal(err)
+	}
+	conferirIndicePrecos(t, b.arquivos)
+
+	// Preços menores, iguais e maiores que os do índice, fora de ordem dentro do lote.
+	if _, err := b.inserirProdutos(lote(300, 50, 200, 900, 50, 100)); err != nil {
+		t.Fatal(err)
+	}
+	conferirIndicePrecos(t, b.arquivos)
+
+	if err := b.removerProdutos([]int32{1, 5, 10}); err != nil {
+		t.Fatal(err)
+	}
+	conferirIndicePrecos(t, b.arquivos)
+
+	if n, err := b.removerProdutosExistentes([]int32{2, 99, 2, 8}); err != nil || n != 2 {
+		t.Fatalf("removerProdutosExistentes = %d, %v; quero 2", n, err)
+	}
+	conferirIndicePrecos(t, b.arquivos)
+
+	if err := removerProduto(b.arquivos.Armazenamento, b.arquivos.Produtos, b.arquivos.IndiceProdutos, b.arquivos.IndicePrecos, 3); err != nil {
+		t.Fatal(err)
+	}
+	conferirIndicePrecos(t, b.arquivos)
+
+	// Uma remoção que falha não mexe em nada.
+	if err := b.removerProdutos([]int32{4, 1}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("removerProdutos com ID ausente = %v, quero ErrNotFound", err)
+	}
+	conferirIndicePrecos(t, b.arquivos)
+
+	problemas, err := verificarArquivos(b.arquivos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problemas) > 0 {
+		t.Errorf("arquivos inconsistentes depois dos lotes: %v", problemas)
+	}
+}
+
+// armazenamentoRecusando recusa criar os arquivos em recusar, para simular uma falha
+// num índice depois que os dados já foram gravados.
+type armazenamentoRecusando struct {
+	Armazenamento
+	recusar map[string]bool
+}
+
+func (a armazenamentoRecusando) Abrir(nome string, flag int, perm fs.FileMode) (Arquivo, error) {
+	if flag&os.O_CREATE != 0 && a.recusar[nome] {
+		return nil, fmt.Errorf("criar %s: %w", nome, fs.ErrPermission)
+	}
+	return a.Armazenamento.Abrir(nome, flag, perm)
+}
+
+func TestLoteComFalhaNosIndices(t *testing.T) {
+	silenciarLogs(t)
+	m := novoArmazenamentoMemoria()
+	arquivos := arquivosPadrao()
+	arquivos.Armazenamento = m
+	b, err := abrirBanco(arquivos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	produtos := []Produto{produtoTeste(t, 1, 300, "m"), produtoTeste(t, 2, 100, "m"), produtoTeste(t, 3, 200, "m")}
+	if _, err := b.inserirProdutos(produtos); err != nil {
+		t.Fatal(err)
+	}
+
+	// Os produtos chegam ao arquivo de dados, então a inserção não pode dar erro: quem
+	// repetisse a chamada gravaria tudo de novo.
+	b.arquivos.Armazenamento = armazenamentoRecusando{m, map[string]bool{arquivoDelta(arquivos.IndicePrecos): true}}
+	if _, err := b.inserirProdutos(produtos[:2]); err != nil {
+		t.Fatalf("inserirProdutos com falha no índice de preços = %v, quero nil", err)
+	}
+	if _, err := m.Info(arquivos.IndicePrecos); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("índice de preços desatualizado continua no lugar (Info = %v)", err)
+	}
+
+	b.arquivos.Armazenamento = armazenamentoRecusando{m, map[string]bool{
+		arquivos.IndiceProdutos + ".tmp": true,
+		arquivos.IndicePrecos + ".tmp":   true,
+	}}
+	if err := b.removerProdutos([]int32{1, 4}); err != nil {
+		t.Fatalf("removerProdutos com falha nos índices = %v, quero nil", err)
+	}
+	if _, err := m.Info(arquivos.IndiceProdutos); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("índice de IDs desatualizado continua no lugar (Info = %v)", err)
+	}
+
+	b, err = abrirBanco(arquivos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int32
+	for produto, err := range produtosDoArquivo(m, arquivos.Produtos) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, bytesToInt32(produto.ID))
+	}
+	if quero := []int32{2, 3, 5}; !slices.Equal(ids, quero) {
+		t.Errorf("IDs gravados = %v, quero %v", ids, quero)
+	}
+	conferirIndicePrecos(t, b.arquivos)
+	problemas, err := verificarArquivos(b.arquivos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problemas) > 0 {
+		t.Errorf("arquivos inconsistentes depois de reabrir: %v", problemas)
+	}
+}
+
+func TestIndicePrecosComDelta(t *testing.T) {
+	m := novoArmazenamentoMemoria()
+	arquivos := arquivosPadrao()
+	arquivos.Armazenamento = m
+	b, err := abrirBanco(arquivos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Um lote maior que o limite do delta já é incorporado à base.
+	lote := make([]Produto, 2000)
+	for i := range lote {
+		lote[i] = produtoTeste(t, int32(i), Preco(i*37%1000), "m")
+	}
+	produtos, err := b.inserirProdutos(lote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Info(arquivoDelta(arquivos.IndicePrecos)); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("delta continua depois de um lote acima do limite (Info = %v)", err)
+	}
+	conferirIndicePrecos(t, b.arquivos)
+	infoBase, err := m.Info(arquivos.IndicePrecos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Uma inserção só anexa ao delta: o que ela lê não depende do tamanho do índice.
+	contador := novoArmazenamentoContador(m)
+	b.arquivos.Armazenamento = contador
+	if _, err := b.inserirProduto(produtoTeste(t, 9000, 500, "m")); err != nil {
+		t.Fatal(err)
+	}
+	if _, lidos := contador.zerar(); lidos > 1024 {
+		t.Errorf("inserir um produto leu %d bytes", lidos)
+	}
+	b.arquivos.Armazenamento = m
+	if info, err := m.Info(arquivos.IndicePrecos); err != nil || info.Size() != infoBase.Size() {
+		t.Errorf("a inserção mexeu na base do índice (Info = %v, %v)", info, err)
+	}
+
+	produto := produtos[10]
+	produto.Price = precoToBytes(5000)
+	if err := b.atualizarProduto(produto); err != nil {
+		t.Fatal(err)
+	}
+	produto = produtos[11]
+	produto.Price = precoToBytes(500)
+	if err := b.atualizarProduto(produto); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := m.Info(arquivoDelta(arquivos.IndicePrecos)); err != nil || info.Size() != 5*tamanhoOperacaoDelta {
+		t.Fatalf("delta com %v, quero 5 operações (Info = %v)", info, err)
+	}
+	conferirIndicePrecos(t, b.arquivos)
+
+	// As consultas intercalam a base com o delta.
+	var porPreco []Produto
+	for produto, err := range produtosDoArquivo(m, arquivos.Produtos) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		porPreco = append(porPreco, *produto)
+	}
+	slices.SortStableFunc(porPreco, func(a, b Produto) int {
+		return int(bytesToPreco(a.Price) - bytesToPreco(b.Price))
+	})
+	ids := func(produtos []Produto) []int32 {
+		ids := make([]int32, len(produtos))
+		for i, p := range produtos {
+			ids[i] = bytesToInt32(p.ID)
+		}
+		return ids
+	}
+
+	faixa, err := consultarProdutosPorFaixaDePreco(m, arquivos.IndicePrecos, arquivos.Produtos, 490, 510)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var querFaixa []Produto
+	for _, p := range porPreco {
+		if preco := bytesToPreco(p.Price); preco >= 490 && preco <= 510 {
+			querFaixa = append(querFaixa, p)
+		}
+	}
+	if !slices.Equal(ids(faixa), ids(querFaixa)) {
+		t.Errorf("faixa de 4.90 a 5.10 = %v, quero %v", ids(faixa), ids(querFaixa))
+	}
+
+	baratos, err := topProdutosPorPreco(m, arquivos.IndicePrecos, arquivos.Produtos, 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ids(baratos), ids(porPreco[:5])) {
+		t.Errorf("5 mais baratos = %v, quero %v", ids(baratos), ids(porPreco[:5]))
+	}
+	caros, err := topProdutosPorPreco(m, arquivos.IndicePrecos, arquivos.Produtos, 5, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	querCaros := slices.Clone(porPreco)
+	slices.Reverse(querCaros)
+	if !slices.Equal(ids(caros), ids(querCaros[:5])) {
+		t.Errorf("5 mais caros = %v, quero %v", ids(caros), ids(querCaros[:5]))
+	}
+
+	maisCaro, err := encontrarProdutoMaisCaro(m, arquivos.IndicePrecos, arquivos.Produtos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maisCaro.ID != produtos[10].ID {
+		t.Errorf("mais caro = produto %d, quero %d", bytesToInt32(maisCaro.ID), bytesToInt32(produtos[10].ID))
+	}
+
+	motivo, err := problemaIndice(m, indicePrecos(m, arquivos.Produtos, arquivos.IndicePrecos))
+	if err != nil || motivo != "" {
+		t.Errorf("problemaIndice com delta = %q, %v", motivo, err)
+	}
+	problemas, err := verificarArquivos(b.arquivos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problemas) > 0 {
+		t.Errorf("arquivos inconsistentes com delta: %v", problemas)
+	}
+
+	// Passado o limite, o delta é incorporado e a base fica igual à recriada.
+	for i := 5; ; i++ {
+		if _, err := b.inserirProduto(produtoTeste(t, int32(i), Preco(i%50), "m")); err != nil {
+			t.Fatal(err)
+		}
+		_, err := m.Info(arquivoDelta(arquivos.IndicePrecos))
+		if errors.Is(err, fs.ErrNotExist) {
+			if i != minimoDeltaPrecos {
+				t.Errorf("delta incorporado com %d operações, quero %d", i+1, minimoDeltaPrecos+1)
+			}
+			break
+		}
+		if i > minimoDeltaPrecos {
+			t.Fatalf("delta continua com %d operações (Info = %v)", i+1, err)
+		}
+	}
+	conferirIndicePrecos(t, b.arquivos)
+}
+
+func TestMaisCaroSoLeOIndice(t *testing.T) {
+	silenciarLogs(t)
+	m := novoArmazenamentoMemoria()
+	arquivos := arquivosPadrao()
+	arquivos.Armazenamento = m
+	b, err := abrirBanco(arquivos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.inserirProdutos([]Produto{produtoTeste(t, 1, 100, "m"), produtoTeste(t, 2, 300, "m")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sem o índice, a consulta falha em vez de recriá-lo; quem recria é abrirBanco.
+	if err := m.Remover(arquivos.IndicePrecos); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := encontrarProdutoMaisCaro(m, arquivos.IndicePrecos, arquivos.Produtos); err == nil {
+		t.Error("encontrarProdutoMaisCaro sem índice não falhou")
+	}
+	if _, err := m.Info(arquivos.IndicePrecos); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("índice recriado por uma consulta (Info = %v)", err)
+	}
+
+	if _, err := abrirBanco(arquivos); err != nil {
+		t.Fatal(err)
+	}
+	maisCaro, err := encontrarProdutoMaisCaro(m, arquivos.IndicePrecos, arquivos.Produtos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytesToPreco(maisCaro.Price) != 300 {
+		t.Errorf("mais caro com preço %s, quero 3.00", bytesToPreco(maisCaro.Price))
+	}
+}
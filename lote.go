@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+// inserirProdutosEmLote grava os produtos com IDs consecutivos da sequência, numa única
+// escrita no arquivo de dados e outra no índice, e anexa os preços deles ao delta do
+// índice de preços. Ou todos os produtos são gravados ou nenhum: em caso de
+// erro, os arquivos voltam ao tamanho anterior. Uma falha no índice de preços, depois
+// que os produtos já estão gravados, não é um erro da inserção; ver abandonarIndice.
+func inserirProdutosEmLote(armazenamento Armazenamento, filenameProd string, indexProd string, indexPreco string, produtos []Produto) ([]Produto, error) {
+	if len(produtos) == 0 {
+		return nil, nil
+	}
+
+	primeiroID, err := reservarIDs(armazenamento, filenameProd, len(produtos), maiorIDProdutos)
+	if err != nil {
+		return nil, err
+	}
+
+	gravados := make([]Produto, len(produtos))
+	registros := make([]byte, len(produtos)*tamanhoProduto)
+	for i := range produtos {
+		gravados[i] = produtos[i]
+		gravados[i].ID = int32ToBytes(primeiroID + int32(i))
+		codificarProduto(registros[i*tamanhoProduto:], &gravados[i])
+	}
+
+	offsetInicial, err := gravarLote(armazenamento, filenameProd, indexProd, registros, tamanhoProduto, func() error {
+		return criarIndiceProdutos(armazenamento, filenameProd, indexProd)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = adicionarLoteAoIndicePrecos(armazenamento, filenameProd, indexPreco, registros, offsetInicial)
+	if err != nil {
+		abandonarIndice(armazenamento, indexPreco, err)
+	}
+	return gravados, nil
+}
+
+func inserirAcessosEmLote(armazenamento Armazenamento, filenameAccess string, indexAccess string, acessos []Acesso) ([]Acesso, error) {
+	if len(acessos) == 0 {
+		return nil, nil
+	}
+
+	primeiroID, err := reservarIDs(armazenamento, filenameAccess, len(acessos), maiorIDAcessos)
+	if err != nil {
+		return nil, err
+	}
+
+	gravados := make([]Acesso, len(acessos))
+	registros := make([]byte, len(acessos)*tamanhoAcesso)
+	for i := range acessos {
+		gravados[i] = acessos[i]
+		gravados[i].ID = int32ToBytes(primeiroID + int32(i))
+		codificarAcesso(registros[i*tamanhoAcesso:], &gravados[i])
+	}
+
+	_, err = gravarLote(armazenamento, filenameAccess, indexAccess, registros, tamanhoAcesso, func() error {
+		return criarIndiceAcessos(armazenamento, filenameAccess, indexAccess)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return gravados, nil
+}
+
+// removerProdutosEmLote tira todos os IDs do arquivo numa única passada, refaz o índice
+// uma vez e ajusta o índice de preços em outra passada. Se algum ID não existir, nada é
+// removido. Depois que o arquivo de dados é trocado, a remoção já aconteceu: falhas nos
+// índices são tratadas por abandonarIndice.
+func removerProdutosEmLote(armazenamento Armazenamento, filenameProd string, indexProd string, indexPreco string, ids []int32) error {
+	removidos, err := removerRegistrosEmLote(armazenamento, filenameProd, tamanhoProduto, "produto", ids)
+	if err != nil {
+		return err
+	}
+
+	err = criarIndiceProdutos(armazenamento, filenameProd, indexProd)
+	if err != nil {
+		abandonarIndice(armazenamento, indexProd, err)
+	}
+	err = removerDoIndicePrecosEmLote(armazenamento, filenameProd, indexPreco, removidos)
+	if err != nil {
+		abandonarIndice(armazenamento, indexPreco, err)
+	}
+	return nil
+}
+
+func removerAcessosEmLote(armazenamento Armazenamento, filenameAccess string, indexAccess string, ids []int32) error {
+	_, err := removerRegistrosEmLote(armazenamento, filenameAccess, tamanhoAcesso, "acesso", ids)
+	if err != nil {
+		return err
+	}
+
+	err = criarIndiceAcessos(armazenamento, filenameAccess, indexAccess)
+	if err != nil {
+		abandonarIndice(armazenamento, indexAccess, err)
+	}
+	return nil
+}
+
+// gravarLote anexa registros (já codificados, com IDs crescentes e maiores que os do
+// arquivo) e as entradas correspondentes do índice, e devolve o offset do primeiro
+// registro. Sem índice, ou com um índice cuja última chave não seja menor que o primeiro
+// ID novo, o índice é refeito por criarIndice.
+func gravarLote(armazenamento Armazenamento, filename string, indexName string, registros []byte, tamanho int, criarIndice func() error) (int64, error) {
+	offsetInicial, err := anexarRegistros(armazenamento, filename, registros, tamanho)
+	if err != nil {
+		return 0, err
+	}
+
+	err = anexarLoteAoIndice(armazenamento, indexName, registros, tamanho, offsetInicial)
+	if err == errIndiceForaDeOrdem {
+		err = criarIndice()
+	}
+	if err != nil {
+		return 0, desfazerAnexo(armazenamento, filename, offsetInicial, err)
+	}
+	return offsetInicial, nil
+}
+
+// anexarRegistros grava registros no fim de filename numa única escrita, sincronizada
+// com o disco, e devolve o offset do primeiro deles. Se a escrita falhar no meio, o
+// arquivo volta ao tamanho anterior, para não ficar com um registro incompleto.
+func anexarRegistros(armazenamento Armazenamento, filename string, registros []byte, tamanho int) (int64, error) {
+	offsetInicial, err := tamanhoArquivo(armazenamento, filename)
+	if err != nil {
+		return 0, err
+	}
+	if offsetInicial%int64(tamanho) != 0 {
+		return 0, fmt.Errorf("%w: tamanho de %s (%d bytes) não é múltiplo do registro", ErrCorrupt, filename, offsetInicial)
+	}
+
+	file, err := armazenamento.Abrir(filename, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("erro ao abrir o arquivo %s para inserção: %w", filename, err)
+	}
+	_, err = file.Write(registros)
+	if err == nil {
+		err = file.Sync()
+	}
+	if errClose := file.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return 0, desfazerAnexo(armazenamento, filename, offsetInicial, fmt.Errorf("erro ao escrever em %s: %w", filename, err))
+	}
+	return offsetInicial, nil
+}
+
+// desfazerAnexo trunca filename de volta a tamanho depois que err interrompeu um anexo.
+func desfazerAnexo(armazenamento Armazenamento, filename string, tamanho int64, err error) error {
+	if errTrunc := armazenamento.Truncar(filename, tamanho); errTrunc != nil {
+		return fmt.Errorf("%w (e erro ao desfazer a gravação: %v)", err, errTrunc)
+	}
+	return err
+}
+
+// errIndiceForaDeOrdem indica que o lote não pode ser só anexado ao índice.
+var errIndiceForaDeOrdem = errors.New("índice precisa ser refeito")
+
+func anexarLoteAoIndice(armazenamento Armazenamento, indexName string, registros []byte, tamanho int, offsetInicial int64) error {
+	existe, err := indiceExiste(armazenamento, indexName)
+	if err != nil {
+		return err
+	}
+	if !existe {
+		return errIndiceForaDeOrdem
+	}
+
+	indexFile, err := armazenamento.Abrir(indexName, os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("erro ao abrir o arquivo de índice: %w", err)
+	}
+	defer indexFile.Close()
+
+	primeiroID := int32(binary.LittleEndian.Uint32(registros[0:4]))
+	_, total, err := posicaoInicialIndice(indexFile, tamanhoIndiceID, primeiroID)
+	if err != nil {
+		return err
+	}
+	if total > 0 {
+		var ultimo [4]byte
+		_, err = indexFile.ReadAt(ultimo[:], (total-1)*tamanhoIndiceID)
+		if err != nil {
+			return fmt.Errorf("erro ao ler registro de índice: %w", erroRegistro(indexName, (total-1)*tamanhoIndiceID, err))
+		}
+		if bytesToInt32(ultimo) >= primeiroID {
+			return errIndiceForaDeOrdem
+		}
+	}
+
+	n := len(registros) / tamanho
+	entradas := make([]byte, n*tamanhoIndiceID)
+	for i := 0; i < n; i++ {
+		var id [4]byte
+		copy(id[:], registros[i*tamanho:])
+		codificarEntradaIndice(entradas[i*tamanhoIndiceID:], id, offsetInicial+int64(i*tamanho))
+	}
+
+	_, err = indexFile.WriteAt(entradas, total*tamanhoIndiceID)
+	if err != nil {
+		if errTrunc := indexFile.Truncate(total * tamanhoIndiceID); errTrunc != nil {
+			return fmt.Errorf("erro ao escrever no arquivo de índice: %w (e erro ao desfazer: %v)", err, errTrunc)
+		}
+		return fmt.Errorf("erro ao escrever no arquivo de índice: %w", err)
+	}
+	return nil
+}
+
+// removerRegistrosEmLote copia para um arquivo temporário, ao lado de filename, todos os
+// registros cujo ID não está em ids, e só então troca o arquivo original por ele. Devolve,
+// em ordem crescente, os offsets que os registros removidos tinham.
+func removerRegistrosEmLote(armazenamento Armazenamento, filename string, tamanho int, tipo string, ids []int32) ([]int64, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	encontrados := make(map[int32]bool, len(ids))
+	for _, id := range ids {
+		encontrados[id] = false
+	}
+
+	file, err := abrirArquivo(armazenamento, filename)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir o arquivo de %ss: %w", tipo, err)
+	}
+	defer file.Close()
+
+	removidos := make([]int64, 0, len(ids))
+
+	temp := filename + ".tmp"
+	tempFile, err := criarArquivo(armazenamento, temp)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar arquivo temporário para %ss: %w", tipo, err)
+	}
+	defer armazenamento.Remover(temp)
+	defer tempFile.Close()
+
+	scanner := novoScannerRegistros(file, filename, tamanho)
+	writer := bufio.NewWriter(tempFile)
+	for scanner.ler() {
+		id := int32(binary.LittleEndian.Uint32(scanner.registro[0:4]))
+		if _, remover := encontrados[id]; remover {
+			encontrados[id] = true
+			removidos = append(removidos, scanner.offset())
+			continue
+		}
+
+		_, err = writer.Write(scanner.registro)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao escrever %s no arquivo temporário: %w", tipo, err)
+		}
+	}
+	if scanner.erro() != nil {
+		return nil, fmt.Errorf("erro ao ler registro de %s: %w", tipo, scanner.erro())
+	}
+
+	var ausentes []int32
+	for id, encontrado := range encontrados {
+		if !encontrado {
+			ausentes = append(ausentes, id)
+		}
+	}
+	if len(ausentes) > 0 {
+		slices.Sort(ausentes)
+		if len(ausentes) == 1 {
+			return nil, fmt.Errorf("%s com ID %d %w", tipo, ausentes[0], ErrNotFound)
+		}
+		return nil, fmt.Errorf("%ss com IDs %v: %w", tipo, ausentes, ErrNotFound)
+	}
+
+	err = writer.Flush()
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao escrever %s no arquivo temporário: %w", tipo, err)
+	}
+
+	err = armazenamento.Renomear(temp, filename)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao renomear arquivo temporário: %w", err)
+	}
+	return removidos, nil
+}
+
+// adicionarLoteAoIndicePrecos anexa ao delta do índice de preços, numa única escrita,
+// as entradas dos produtos em registros, gravados a partir de offsetInicial. Sem índice,
+// ele é criado do zero.
+func adicionarLoteAoIndicePrecos(armazenamento Armazenamento, filenameProd string, indexPreco string, registros []byte, offsetInicial int64) error {
+	existe, err := indiceExiste(armazenamento, indexPreco)
+	if err != nil {
+		return err
+	}
+	if !existe {
+		return criarIndicePrecos(armazenamento, filenameProd, indexPreco)
+	}
+
+	novas := make([]entradaPreco, len(registros)/tamanhoProduto)
+	for i := range novas {
+		registro := registros[i*tamanhoProduto : (i+1)*tamanhoProduto]
+		novas[i] = entradaPreco{bytesToPreco([8]byte(registro[8:16])), offsetInicial + int64(i*tamanhoProduto)}
+	}
+
+	err = anexarAoDelta(armazenamento, indexPreco, adicaoDelta, novas)
+	if err != nil {
+		return err
+	}
+	return compactarSeNecessario(armazenamento, indexPreco)
+}
+
+// removerDoIndicePrecosEmLote regrava o índice de preços numa única passada sem as
+// entradas dos offsets em removidos (em ordem crescente), trazendo as demais para os
+// offsets que os produtos têm depois da remoção. A ordem do índice não muda: o ajuste
+// preserva a ordem dos offsets entre preços iguais.
+func removerDoIndicePrecosEmLote(armazenamento Armazenamento, filenameProd string, indexPreco string, removidos []int64) error {
+	if len(removidos) == 0 {
+		return nil
+	}
+
+	existe, err := indiceExiste(armazenamento, indexPreco)
+	if err != nil {
+		return err
+	}
+	if !existe {
+		return criarIndicePrecos(armazenamento, filenameProd, indexPreco)
+	}
+
+	encontradas := 0
+	err = reescreverIndicePrecos(armazenamento, indexPreco, func(w io.Writer, entrada []byte) error {
+		offset := int64(binary.LittleEndian.Uint64(entrada[8:16]))
+		antes, removido := slices.BinarySearch(removidos, offset)
+		if removido {
+			encontradas++
+			return nil
+		}
+
+		binary.LittleEndian.PutUint64(entrada[8:16], uint64(offset-int64(antes)*tamanhoProduto))
+		_, err := w.Write(entrada)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if encontradas != len(removidos) {
+		return invalidarIndice(armazenamento, indexPreco, fmt.Errorf("%w: %s tinha %d das %d entradas removidas", ErrCorrupt, indexPreco, encontradas, len(removidos)))
+	}
+	return nil
+}
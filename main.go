@@ -1,18 +1,25 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/binary"
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
 	"math"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 type Produto struct {
 	ID           [4]byte  // Tamanho fixo para int32 (ID) PK
 	ProductID    [4]byte  // Tamanho fixo para int32 (product_id)
-	Price        [4]byte  // Tamanho fixo para float32
+	Price        [8]byte  // Centavos em int64 (Preco)
 	Brand        [20]byte // Tamanho fixo para a marca
 	CategoryCode [20]byte // Tamanho fixo para o código da categoria
 }
@@ -34,25 +41,35 @@ type IndexAcesso struct {
 	Offset [8]byte // Posição do registro no arquivo de acessos
 }
 
+// padString completa str com zeros até length bytes. Um texto maior é cortado antes do
+// caractere que não caberia inteiro, para não gravar metade de uma sequência UTF-8.
 func padString(str string, length int) []byte {
 	padded := make([]byte, length)
+	if len(str) > length {
+		n := length
+		for n > 0 && !utf8.RuneStart(str[n]) {
+			n--
+		}
+		str = str[:n]
+	}
 	copy(padded, str)
 	return padded
 }
 
-func int32ToBytes(n int32) [4]byte {
-	var buf [4]byte
-	binary.LittleEndian.PutUint32(buf[:], uint32(n))
-	return buf
+// textoFixo decodifica um campo de texto de tamanho fixo: tira os zeros do
+// preenchimento e troca bytes que não formam UTF-8 válido (como os de registros
+// gravados antes de padString respeitar os caracteres) por U+FFFD.
+func textoFixo(b []byte) string {
+	return strings.ToValidUTF8(string(bytes.TrimRight(b, "\x00")), "\uFFFD")
 }
 
-func float32ToBytes(f float32) [4]byte {
+func int32ToBytes(n int32) [4]byte {
 	var buf [4]byte
-	binary.LittleEndian.PutUint32(buf[:], math.Float32bits(f))
+	binary.LittleEndian.PutUint32(buf[:], uint32(n))
 	return buf
 }
 
-func processCSV(filePath string, filenameProd string, filenameAccess string) error {
+func processCSV(armazenamento Armazenamento, filePath string, filenameProd string, filenameAccess string) error {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return fmt.Errorf("erro ao abrir o arquivo CSV: %w", err)
@@ -61,18 +78,23 @@ func processCSV(filePath string, filenameProd string, filenameAccess string) err
 
 	reader := csv.NewReader(file)
 
-	_, err = reader.Read()
+	cabecalho, err := reader.Read()
 	if err != nil {
 		return fmt.Errorf("erro ao ler o cabeçalho: %w", err)
 	}
+	// registrosDaLinha lê as colunas pela posição; um arquivo com outra ordem gravaria
+	// campos trocados sem erro nenhum.
+	if !slices.Equal(cabecalho, cabecalhoCSV) {
+		return fmt.Errorf("%w: cabeçalho do CSV %v, quero %v", ErrInvalidField, cabecalho, cabecalhoCSV)
+	}
 
-	fileProd, err := os.Create(filenameProd)
+	fileProd, err := criarArquivo(armazenamento, filenameProd)
 	if err != nil {
 		return fmt.Errorf("erro ao criar arquivo de produtos: %w", err)
 	}
 	defer fileProd.Close()
 
-	fileAcess, err := os.Create(filenameAccess)
+	fileAcess, err := criarArquivo(armazenamento, filenameAccess)
 	if err != nil {
 		return fmt.Errorf("erro ao criar arquivo de acessos: %w", err)
 	}
@@ -82,40 +104,27 @@ func processCSV(filePath string, filenameProd string, filenameAccess string) err
 	acessoIDCounter := int32(1)
 
 	for {
+		inicio := reader.InputOffset()
 		record, err := reader.Read()
 		if err != nil {
-			if err.Error() == "EOF" {
+			if err == io.EOF {
 				break
 			}
 			return fmt.Errorf("erro ao ler o arquivo CSV: %w", err)
 		}
 
-		productID, _ := strconv.ParseInt(record[3], 10, 32) // product_id
-		price, _ := strconv.ParseFloat(record[6], 32)       // price
-		userID, _ := strconv.ParseInt(record[7], 10, 32)    // user_id
-		userSession := record[8]                            // user_session
-		eventType := record[1]                              // event_type
-		brand := record[5]                                  // brand
-		categoryCode := record[4]                           // category_code
-
-		var produto Produto
+		produto, acesso, err := registrosDaLinha(record)
+		if err != nil {
+			return fmt.Errorf("erro ao converter linha do CSV: %w", &RecordError{Arquivo: filePath, Offset: inicio, Err: err})
+		}
 		produto.ID = int32ToBytes(produtoIDCounter)
-		produto.ProductID = int32ToBytes(int32(productID))
-		produto.Price = float32ToBytes(float32(price))
-		copy(produto.Brand[:], padString(brand, 20))
-		copy(produto.CategoryCode[:], padString(categoryCode, 20))
+		acesso.ID = int32ToBytes(acessoIDCounter)
 
 		err = binary.Write(fileProd, binary.LittleEndian, produto)
 		if err != nil {
 			return fmt.Errorf("erro ao escrever produto no arquivo binário: %w", err)
 		}
 
-		var acesso Acesso
-		acesso.ID = int32ToBytes(acessoIDCounter)
-		copy(acesso.UserSession[:], padString(userSession, 20))
-		acesso.UserID = int32ToBytes(int32(userID))
-		copy(acesso.EventType[:], padString(eventType, 10))
-
 		err = binary.Write(fileAcess, binary.LittleEndian, acesso)
 		if err != nil {
 			return fmt.Errorf("erro ao escrever acesso no arquivo: %w", err)
@@ -125,7 +134,51 @@ func processCSV(filePath string, filenameProd string, filenameAccess string) err
 		acessoIDCounter++
 	}
 
-	return nil
+	err = gravarSequencia(armazenamento, filenameProd, produtoIDCounter)
+	if err != nil {
+		return err
+	}
+
+	return gravarSequencia(armazenamento, filenameAccess, acessoIDCounter)
+}
+
+// registrosDaLinha converte uma linha do CSV, com as colunas de cabecalhoCSV, no produto
+// e no acesso correspondentes, ainda sem ID. product_id é a coluna 2; a 3, category_id,
+// não é gravada. Ao contrário de ModeloProduto.registro, a importação corta os textos
+// que não cabem: category_code passa de 20 bytes em boa parte dos dados reais.
+func registrosDaLinha(record []string) (Produto, Acesso, error) {
+	userSession := record[8]  // user_session
+	eventType := record[1]    // event_type
+	brand := record[5]        // brand
+	categoryCode := record[4] // category_code
+
+	// Um número ilegível não vira 0: isso juntaria em silêncio produtos e usuários
+	// diferentes, e um preço 0 mudaria as somas e o índice de preços.
+	productID, err := strconv.ParseInt(record[2], 10, 32) // product_id
+	if err != nil {
+		return Produto{}, Acesso{}, fmt.Errorf("%w: product_id: %w", ErrInvalidField, err)
+	}
+	userID, err := strconv.ParseInt(record[7], 10, 32) // user_id
+	if err != nil {
+		return Produto{}, Acesso{}, fmt.Errorf("%w: user_id: %w", ErrInvalidField, err)
+	}
+	price, err := lerPreco(record[6]) // price
+	if err != nil {
+		return Produto{}, Acesso{}, fmt.Errorf("%w: price: %w", ErrInvalidField, err)
+	}
+
+	var produto Produto
+	produto.ProductID = int32ToBytes(int32(productID))
+	produto.Price = precoToBytes(price)
+	copy(produto.Brand[:], padString(brand, 20))
+	copy(produto.CategoryCode[:], padString(categoryCode, 20))
+
+	var acesso Acesso
+	copy(acesso.UserSession[:], padString(userSession, 20))
+	acesso.UserID = int32ToBytes(int32(userID))
+	copy(acesso.EventType[:], padString(eventType, 10))
+
+	return produto, acesso, nil
 }
 
 func bytesToInt32(b [4]byte) int32 {
@@ -136,32 +189,15 @@ func bytesToFloat32(b [4]byte) float32 {
 	return math.Float32frombits(binary.LittleEndian.Uint32(b[:]))
 }
 
-func lerArquivoProdutos(filename string) error {
-	file, err := os.Open(filename)
-	if err != nil {
-		return fmt.Errorf("erro ao abrir o arquivo binário de produtos: %w", err)
-	}
-	defer file.Close()
-
-	var produto Produto
+func lerArquivoProdutos(armazenamento Armazenamento, filename string) error {
 	count := 0
 
-	for {
-		err := binary.Read(file, binary.LittleEndian, &produto)
+	for produto, err := range produtosDoArquivo(armazenamento, filename) {
 		if err != nil {
-			if err.Error() == "EOF" {
-				break
-			}
-			return fmt.Errorf("erro ao ler registro de produto: %w", err)
+			return err
 		}
 
-		id := bytesToInt32(produto.ID)
-		productID := bytesToInt32(produto.ProductID)
-		price := bytesToFloat32(produto.Price)
-		brand := string(produto.Brand[:])
-		categoryCode := string(produto.CategoryCode[:])
-
-		fmt.Printf("Produto - ID: %d, ProductID: %d, Preço: %.2f, Marca: %s, Categoria: %s\n", id, productID, price, brand, categoryCode)
+		fmt.Printf("Produto - %s\n", produto)
 		count++
 	}
 
@@ -169,31 +205,15 @@ func lerArquivoProdutos(filename string) error {
 	return nil
 }
 
-func lerArquivoAcessos(filename string) error {
-	file, err := os.Open(filename)
-	if err != nil {
-		return fmt.Errorf("erro ao abrir o arquivo binário de acessos: %w", err)
-	}
-	defer file.Close()
-
-	var acesso Acesso
+func lerArquivoAcessos(armazenamento Armazenamento, filename string) error {
 	count := 0
 
-	for {
-		err := binary.Read(file, binary.LittleEndian, &acesso)
+	for acesso, err := range acessosDoArquivo(armazenamento, filename) {
 		if err != nil {
-			if err.Error() == "EOF" {
-				break
-			}
-			return fmt.Errorf("erro ao ler registro de acesso: %w", err)
+			return err
 		}
 
-		id := bytesToInt32(acesso.ID)
-		userSession := string(acesso.UserSession[:])
-		userID := bytesToInt32(acesso.UserID)
-		eventType := string(acesso.EventType[:])
-
-		fmt.Printf("Acesso - ID: %d, Sessão: %s, UserID: %d, Evento: %s\n", id, userSession, userID, eventType)
+		fmt.Printf("Acesso - %s\n", acesso)
 		count++
 	}
 
@@ -201,82 +221,144 @@ func lerArquivoAcessos(filename string) error {
 	return nil
 }
 
-func inserirProduto(filename string, produto Produto) error {
-	file, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+func percorrerProdutos(armazenamento Armazenamento, filename string, fn func(produto *Produto) error) error {
+	for produto, err := range produtosDoArquivo(armazenamento, filename) {
+		if err != nil {
+			return err
+		}
+
+		err = fn(produto)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func percorrerAcessos(armazenamento Armazenamento, filename string, fn func(acesso *Acesso) error) error {
+	for acesso, err := range acessosDoArquivo(armazenamento, filename) {
+		if err != nil {
+			return err
+		}
+
+		err = fn(acesso)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// inserirProduto grava o produto no fim do arquivo com o próximo ID da sequência e devolve
+// o registro gravado. O ID que vier em produto é ignorado.
+func inserirProduto(armazenamento Armazenamento, filename string, produto Produto) (Produto, error) {
+	id, err := reservarID(armazenamento, filename, maiorIDProdutos)
 	if err != nil {
-		return fmt.Errorf("erro ao abrir o arquivo de produtos para inserção: %w", err)
+		return Produto{}, err
 	}
-	defer file.Close()
+	produto.ID = int32ToBytes(id)
 
-	err = binary.Write(file, binary.LittleEndian, produto)
+	return produto, anexarProduto(armazenamento, filename, produto)
+}
+
+// inserirProdutoComID grava o produto com o ID que ele já traz, que precisa ser maior que
+// todos os IDs já entregues pela sequência.
+func inserirProdutoComID(armazenamento Armazenamento, filename string, produto Produto) error {
+	err := reservarIDExplicito(armazenamento, filename, bytesToInt32(produto.ID), maiorIDProdutos)
 	if err != nil {
-		return fmt.Errorf("erro ao escrever produto no arquivo: %w", err)
+		return err
 	}
 
+	return anexarProduto(armazenamento, filename, produto)
+}
+
+func anexarProduto(armazenamento Armazenamento, filename string, produto Produto) error {
+	var registro [tamanhoProduto]byte
+	codificarProduto(registro[:], &produto)
+
+	_, err := anexarRegistros(armazenamento, filename, registro[:], tamanhoProduto)
+	if err != nil {
+		return fmt.Errorf("erro ao escrever produto no arquivo: %w", err)
+	}
 	return nil
 }
 
-func inserirAcesso(filename string, acesso Acesso) error {
-	file, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+// inserirAcesso grava o acesso no fim do arquivo com o próximo ID da sequência e devolve
+// o registro gravado. O ID que vier em acesso é ignorado.
+func inserirAcesso(armazenamento Armazenamento, filename string, acesso Acesso) (Acesso, error) {
+	id, err := reservarID(armazenamento, filename, maiorIDAcessos)
 	if err != nil {
-		return fmt.Errorf("erro ao abrir o arquivo de acessos para inserção: %w", err)
+		return Acesso{}, err
 	}
-	defer file.Close()
+	acesso.ID = int32ToBytes(id)
+
+	return acesso, anexarAcesso(armazenamento, filename, acesso)
+}
 
-	err = binary.Write(file, binary.LittleEndian, acesso)
+// inserirAcessoComID grava o acesso com o ID que ele já traz, que precisa ser maior que
+// todos os IDs já entregues pela sequência.
+func inserirAcessoComID(armazenamento Armazenamento, filename string, acesso Acesso) error {
+	err := reservarIDExplicito(armazenamento, filename, bytesToInt32(acesso.ID), maiorIDAcessos)
 	if err != nil {
-		return fmt.Errorf("erro ao escrever acesso no arquivo: %w", err)
+		return err
 	}
 
-	return nil
+	return anexarAcesso(armazenamento, filename, acesso)
 }
 
-func proximoIDProdutos(filename string) (int32, error) {
-	file, err := os.Open(filename)
+func anexarAcesso(armazenamento Armazenamento, filename string, acesso Acesso) error {
+	var registro [tamanhoAcesso]byte
+	codificarAcesso(registro[:], &acesso)
+
+	_, err := anexarRegistros(armazenamento, filename, registro[:], tamanhoAcesso)
 	if err != nil {
-		return 0, fmt.Errorf("erro ao abrir o arquivo de produtos: %w", err)
+		return fmt.Errorf("erro ao escrever acesso no arquivo: %w", err)
 	}
-	defer file.Close()
+	return nil
+}
 
-	var produto Produto
-	var lastID int32 = 0
+func proximoIDProdutos(armazenamento Armazenamento, filename string) (int32, error) {
+	return lerSequencia(armazenamento, filename, maiorIDProdutos)
+}
 
-	for {
-		err := binary.Read(file, binary.LittleEndian, &produto)
+// maiorIDProdutos percorre o arquivo inteiro; só é usado quando ainda não há sequência.
+func maiorIDProdutos(armazenamento Armazenamento, filename string) (int32, error) {
+	var maiorID int32 = 0
+
+	for produto, err := range produtosDoArquivo(armazenamento, filename) {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
 		if err != nil {
-			if err.Error() == "EOF" {
-				break
-			}
-			return 0, fmt.Errorf("erro ao ler registro de produto: %w", err)
+			return 0, err
 		}
-		lastID = bytesToInt32(produto.ID)
+		maiorID = max(maiorID, bytesToInt32(produto.ID))
 	}
 
-	return lastID + 1, nil
+	return maiorID, nil
 }
 
-func proximoIDAcessos(filename string) (int32, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return 0, fmt.Errorf("erro ao abrir o arquivo de acessos: %w", err)
-	}
-	defer file.Close()
+func proximoIDAcessos(armazenamento Armazenamento, filename string) (int32, error) {
+	return lerSequencia(armazenamento, filename, maiorIDAcessos)
+}
 
-	var acesso Acesso
-	var lastID int32 = 0
+// maiorIDAcessos percorre o arquivo inteiro; só é usado quando ainda não há sequência.
+func maiorIDAcessos(armazenamento Armazenamento, filename string) (int32, error) {
+	var maiorID int32 = 0
 
-	for {
-		err := binary.Read(file, binary.LittleEndian, &acesso)
+	for acesso, err := range acessosDoArquivo(armazenamento, filename) {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
 		if err != nil {
-			if err.Error() == "EOF" {
-				break
-			}
-			return 0, fmt.Errorf("erro ao ler registro de acesso: %w", err)
+			return 0, err
 		}
-		lastID = bytesToInt32(acesso.ID)
+		maiorID = max(maiorID, bytesToInt32(acesso.ID))
 	}
 
-	return lastID + 1, nil
+	return maiorID, nil
 }
 
 func bytesToArray10(b []byte) [10]byte {
@@ -291,8 +373,8 @@ func bytesToArray20(b []byte) [20]byte {
 	return arr
 }
 
-func pesquisarProduto(filename string, id int32) (Produto, error) {
-	file, err := os.Open(filename)
+func pesquisarProduto(armazenamento Armazenamento, filename string, id int32) (Produto, error) {
+	file, err := abrirArquivo(armazenamento, filename)
 	if err != nil {
 		return Produto{}, fmt.Errorf("erro ao abrir o arquivo binário de produtos: %w", err)
 	}
@@ -300,8 +382,12 @@ func pesquisarProduto(filename string, id int32) (Produto, error) {
 
 	var produto Produto
 	start := 0
-	fileInfo, _ := file.Stat()
-	size := int(fileInfo.Size() / int64(binary.Size(produto)))
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return Produto{}, fmt.Errorf("erro ao obter informações do arquivo: %w", err)
+	}
+	// size é a posição do último registro; partir do total leria além do fim do arquivo.
+	size := int(fileInfo.Size()/int64(binary.Size(produto))) - 1
 
 	for start <= size {
 		mid := (start + size) / 2
@@ -312,7 +398,7 @@ func pesquisarProduto(filename string, id int32) (Produto, error) {
 
 		err = binary.Read(file, binary.LittleEndian, &produto)
 		if err != nil {
-			return Produto{}, fmt.Errorf("erro ao ler registro de produto: %w", err)
+			return Produto{}, fmt.Errorf("erro ao ler registro de produto: %w", erroRegistro(filename, int64(mid*binary.Size(produto)), err))
 		}
 
 		midID := bytesToInt32(produto.ID)
@@ -326,11 +412,11 @@ func pesquisarProduto(filename string, id int32) (Produto, error) {
 		}
 	}
 
-	return Produto{}, fmt.Errorf("produto com ID %d não encontrado", id)
+	return Produto{}, fmt.Errorf("produto com ID %d %w", id, ErrNotFound)
 }
 
-func pesquisarAcesso(filename string, id int32) (Acesso, error) {
-	file, err := os.Open(filename)
+func pesquisarAcesso(armazenamento Armazenamento, filename string, id int32) (Acesso, error) {
+	file, err := abrirArquivo(armazenamento, filename)
 	if err != nil {
 		return Acesso{}, fmt.Errorf("erro ao abrir o arquivo binário de acessos: %w", err)
 	}
@@ -338,8 +424,12 @@ func pesquisarAcesso(filename string, id int32) (Acesso, error) {
 
 	var acesso Acesso
 	start := 0
-	fileInfo, _ := file.Stat()
-	size := int(fileInfo.Size() / int64(binary.Size(acesso)))
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return Acesso{}, fmt.Errorf("erro ao obter informações do arquivo: %w", err)
+	}
+	// size é a posição do último registro; partir do total leria além do fim do arquivo.
+	size := int(fileInfo.Size()/int64(binary.Size(acesso))) - 1
 
 	for start <= size {
 		mid := (start + size) / 2
@@ -350,7 +440,7 @@ func pesquisarAcesso(filename string, id int32) (Acesso, error) {
 
 		err = binary.Read(file, binary.LittleEndian, &acesso)
 		if err != nil {
-			return Acesso{}, fmt.Errorf("erro ao ler registro de acesso: %w", err)
+			return Acesso{}, fmt.Errorf("erro ao ler registro de acesso: %w", erroRegistro(filename, int64(mid*binary.Size(acesso)), err))
 		}
 
 		midID := bytesToInt32(acesso.ID)
@@ -364,170 +454,74 @@ func pesquisarAcesso(filename string, id int32) (Acesso, error) {
 		}
 	}
 
-	return Acesso{}, fmt.Errorf("acesso com ID %d não encontrado", id)
+	return Acesso{}, fmt.Errorf("acesso com ID %d %w", id, ErrNotFound)
 }
 
-func encontrarProdutoMaisCaro(filename string) (Produto, error) {
-	file, err := os.Open(filename)
+func criarIndiceProdutos(armazenamento Armazenamento, filenameProd string, indexProd string) error {
+	scanner, err := abrirScannerProdutos(armazenamento, filenameProd)
 	if err != nil {
-		return Produto{}, fmt.Errorf("erro ao abrir o arquivo de produtos: %w", err)
+		return err
 	}
-	defer file.Close()
+	defer scanner.fechar()
 
-	var produto Produto
-	var produtoMaisCaro Produto
-	maiorPreco := float32(0)
-
-	count := 0
-	fileInfo, _ := file.Stat()
-	size := int(fileInfo.Size() / int64(binary.Size(produto)))
+	return gravarAtomicamente(armazenamento, indexProd, func(w io.Writer) error {
+		var entrada [tamanhoIndiceID]byte
 
-	for i := 0; i < size; i++ {
-		_, err := file.Seek(int64(i*binary.Size(produto)), 0)
-		if err != nil {
-			return Produto{}, fmt.Errorf("erro ao mover o cursor para o offset: %w", err)
-		}
+		for offset, produto := range scanner.todos() {
+			codificarEntradaIndice(entrada[:], produto.ID, offset)
 
-		err = binary.Read(file, binary.LittleEndian, &produto)
-		if err != nil {
-			if err.Error() == "EOF" {
-				break
+			_, err := w.Write(entrada[:])
+			if err != nil {
+				return fmt.Errorf("erro ao escrever índice de produto: %w", err)
 			}
-			return Produto{}, fmt.Errorf("erro ao ler registro de produto: %w", err)
 		}
-
-		preco := bytesToFloat32(produto.Price)
-		if preco > maiorPreco {
-			maiorPreco = preco
-			produtoMaisCaro = produto
+		if scanner.erro() != nil {
+			return fmt.Errorf("erro ao ler registro de produto: %w", scanner.erro())
 		}
-
-		count++
-	}
-
-	if count == 0 {
-		return Produto{}, fmt.Errorf("nenhum produto encontrado")
-	}
-
-	return produtoMaisCaro, nil
+		return nil
+	})
 }
 
-func criarIndiceProdutos(filenameProd string, indexProd string) error {
-	file, err := os.Open(filenameProd)
+func userSessionMaisFrequente(armazenamento Armazenamento, filename string) (string, int, error) {
+	top, err := topKValores(armazenamento, "acessos", filename, "user_session", 1)
 	if err != nil {
-		return fmt.Errorf("erro ao abrir o arquivo de produtos: %w", err)
+		return "", 0, err
 	}
-	defer file.Close()
 
-	indexFile, err := os.Create(indexProd)
-	if err != nil {
-		return fmt.Errorf("erro ao criar arquivo de índice de produtos: %w", err)
+	if len(top) == 0 {
+		return "", 0, nil
 	}
-	defer indexFile.Close()
 
-	var produto Produto
-	offset := int64(0)
-
-	for {
-		err := binary.Read(file, binary.LittleEndian, &produto)
-		if err != nil {
-			if err.Error() == "EOF" {
-				break
-			}
-			return fmt.Errorf("erro ao ler registro de produto: %w", err)
-		}
-
-		var index IndexProduto
-		index.ID = produto.ID
-		binary.LittleEndian.PutUint64(index.Offset[:], uint64(offset))
-
-		err = binary.Write(indexFile, binary.LittleEndian, index)
-		if err != nil {
-			return fmt.Errorf("erro ao escrever índice de produto: %w", err)
-		}
-
-		offset += int64(binary.Size(produto))
-	}
-
-	return nil
+	return top[0].Valor, top[0].Count, nil
 }
 
-func userSessionMaisFrequente(filename string) (string, int, error) {
-	file, err := os.Open(filename)
+func criarIndiceAcessos(armazenamento Armazenamento, filenameAccess string, indexAccess string) error {
+	scanner, err := abrirScannerAcessos(armazenamento, filenameAccess)
 	if err != nil {
-		return "", 0, fmt.Errorf("erro ao abrir o arquivo de acessos: %w", err)
-	}
-	defer file.Close()
-
-	var acesso Acesso
-	sessaoCount := make(map[string]int)
-	for {
-		err := binary.Read(file, binary.LittleEndian, &acesso)
-		if err != nil {
-			if err.Error() == "EOF" {
-				break
-			}
-			return "", 0, fmt.Errorf("erro ao ler registro de acesso: %w", err)
-		}
-
-		sessao := string(acesso.UserSession[:])
-		sessaoCount[sessao]++
-	}
-	var sessaoMaisFrequente string
-	var maxCount int
-
-	for sessao, count := range sessaoCount {
-		if count > maxCount {
-			maxCount = count
-			sessaoMaisFrequente = sessao
-		}
-	}
-
-	return sessaoMaisFrequente, maxCount, nil
-}
-
-func criarIndiceAcessos(filenameAccess string, indexAccess string) error {
-	file, err := os.Open(filenameAccess)
-	if err != nil {
-		return fmt.Errorf("erro ao abrir o arquivo de acessos: %w", err)
+		return err
 	}
-	defer file.Close()
+	defer scanner.fechar()
 
-	indexFile, err := os.Create(indexAccess)
-	if err != nil {
-		return fmt.Errorf("erro ao criar arquivo de índice de acessos: %w", err)
-	}
-	defer indexFile.Close()
+	return gravarAtomicamente(armazenamento, indexAccess, func(w io.Writer) error {
+		var entrada [tamanhoIndiceID]byte
 
-	var acesso Acesso
-	offset := int64(0)
+		for offset, acesso := range scanner.todos() {
+			codificarEntradaIndice(entrada[:], acesso.ID, offset)
 
-	for {
-		err := binary.Read(file, binary.LittleEndian, &acesso)
-		if err != nil {
-			if err.Error() == "EOF" {
-				break
+			_, err := w.Write(entrada[:])
+			if err != nil {
+				return fmt.Errorf("erro ao escrever índice de acesso: %w", err)
 			}
-			return fmt.Errorf("erro ao ler registro de acesso: %w", err)
 		}
-
-		var index IndexAcesso
-		index.ID = acesso.ID
-		binary.LittleEndian.PutUint64(index.Offset[:], uint64(offset))
-
-		err = binary.Write(indexFile, binary.LittleEndian, index)
-		if err != nil {
-			return fmt.Errorf("erro ao escrever índice de acesso: %w", err)
+		if scanner.erro() != nil {
+			return fmt.Errorf("erro ao ler registro de acesso: %w", scanner.erro())
 		}
-
-		offset += int64(binary.Size(acesso))
-	}
-
-	return nil
+		return nil
+	})
 }
 
-func consultarProdutoComIndice(indexProd string, filenameProd string, id int32) (Produto, error) {
-	indexFile, err := os.Open(indexProd)
+func consultarProdutoComIndice(armazenamento Armazenamento, indexProd string, filenameProd string, id int32) (Produto, error) {
+	indexFile, err := abrirArquivo(armazenamento, indexProd)
 	if err != nil {
 		return Produto{}, fmt.Errorf("erro ao abrir o arquivo de índice de produtos: %w", err)
 	}
@@ -535,8 +529,11 @@ func consultarProdutoComIndice(indexProd string, filenameProd string, id int32)
 
 	var index IndexProduto
 	start := 0
-	indexFileInfo, _ := indexFile.Stat()
-	indexSize := int(indexFileInfo.Size() / int64(binary.Size(index)))
+	indexFileInfo, err := indexFile.Stat()
+	if err != nil {
+		return Produto{}, fmt.Errorf("erro ao obter informações do arquivo de índice: %w", err)
+	}
+	indexSize := int(indexFileInfo.Size()/int64(binary.Size(index))) - 1
 
 	for start <= indexSize {
 		mid := (start + indexSize) / 2
@@ -547,14 +544,14 @@ func consultarProdutoComIndice(indexProd string, filenameProd string, id int32)
 
 		err = binary.Read(indexFile, binary.LittleEndian, &index)
 		if err != nil {
-			return Produto{}, fmt.Errorf("erro ao ler registro de índice de produto: %w", err)
+			return Produto{}, fmt.Errorf("erro ao ler registro de índice de produto: %w", erroRegistro(indexProd, int64(mid*binary.Size(index)), err))
 		}
 
 		midID := bytesToInt32(index.ID)
 
 		if midID == id {
 			offset := binary.LittleEndian.Uint64(index.Offset[:])
-			produto, err := buscarProdutoPorOffset(filenameProd, int64(offset))
+			produto, err := buscarProdutoPorOffset(armazenamento, filenameProd, int64(offset))
 			return produto, err
 		} else if midID < id {
 			start = mid + 1
@@ -563,11 +560,11 @@ func consultarProdutoComIndice(indexProd string, filenameProd string, id int32)
 		}
 	}
 
-	return Produto{}, fmt.Errorf("produto com ID %d não encontrado", id)
+	return Produto{}, fmt.Errorf("produto com ID %d %w", id, ErrNotFound)
 }
 
-func consultarAcessoComIndice(indexAccess string, filenameAccess string, id int32) (Acesso, error) {
-	indexFile, err := os.Open(indexAccess)
+func consultarAcessoComIndice(armazenamento Armazenamento, indexAccess string, filenameAccess string, id int32) (Acesso, error) {
+	indexFile, err := abrirArquivo(armazenamento, indexAccess)
 	if err != nil {
 		return Acesso{}, fmt.Errorf("erro ao abrir o arquivo de índice de acessos: %w", err)
 	}
@@ -575,8 +572,11 @@ func consultarAcessoComIndice(indexAccess string, filenameAccess string, id int3
 
 	var index IndexAcesso
 	start := 0
-	indexFileInfo, _ := indexFile.Stat()
-	indexSize := int(indexFileInfo.Size() / int64(binary.Size(index)))
+	indexFileInfo, err := indexFile.Stat()
+	if err != nil {
+		return Acesso{}, fmt.Errorf("erro ao obter informações do arquivo de índice: %w", err)
+	}
+	indexSize := int(indexFileInfo.Size()/int64(binary.Size(index))) - 1
 
 	for start <= indexSize {
 		mid := (start + indexSize) / 2
@@ -587,14 +587,14 @@ func consultarAcessoComIndice(indexAccess string, filenameAccess string, id int3
 
 		err = binary.Read(indexFile, binary.LittleEndian, &index)
 		if err != nil {
-			return Acesso{}, fmt.Errorf("erro ao ler registro de índice de acesso: %w", err)
+			return Acesso{}, fmt.Errorf("erro ao ler registro de índice de acesso: %w", erroRegistro(indexAccess, int64(mid*binary.Size(index)), err))
 		}
 
 		midID := bytesToInt32(index.ID)
 
 		if midID == id {
 			offset := binary.LittleEndian.Uint64(index.Offset[:])
-			acesso, err := buscarAcessoPorOffset(filenameAccess, int64(offset))
+			acesso, err := buscarAcessoPorOffset(armazenamento, filenameAccess, int64(offset))
 			return acesso, err
 		} else if midID < id {
 			start = mid + 1
@@ -603,11 +603,196 @@ func consultarAcessoComIndice(indexAccess string, filenameAccess string, id int3
 		}
 	}
 
-	return Acesso{}, fmt.Errorf("acesso com ID %d não encontrado", id)
+	return Acesso{}, fmt.Errorf("acesso com ID %d %w", id, ErrNotFound)
 }
 
-func buscarProdutoPorOffset(filename string, offset int64) (Produto, error) {
-	file, err := os.Open(filename)
+// posicaoInicialIndice retorna a posição da primeira entrada do índice com ID >= id
+// e o total de entradas do arquivo de índice.
+func posicaoInicialIndice(indexFile Arquivo, tamanhoEntrada int, id int32) (int64, int64, error) {
+	indexFileInfo, err := indexFile.Stat()
+	if err != nil {
+		return 0, 0, fmt.Errorf("erro ao obter informações do arquivo de índice: %w", err)
+	}
+	if indexFileInfo.Size()%int64(tamanhoEntrada) != 0 {
+		return 0, 0, fmt.Errorf("%w: tamanho de %s (%d bytes) não é múltiplo da entrada de índice", ErrCorrupt, indexFile.Name(), indexFileInfo.Size())
+	}
+	total := indexFileInfo.Size() / int64(tamanhoEntrada)
+
+	var chave [4]byte
+	start, end := int64(0), total
+	for start < end {
+		mid := (start + end) / 2
+		_, err := indexFile.ReadAt(chave[:], mid*int64(tamanhoEntrada))
+		if err != nil {
+			return 0, 0, fmt.Errorf("erro ao ler registro de índice: %w", erroRegistro(indexFile.Name(), mid*int64(tamanhoEntrada), err))
+		}
+
+		if bytesToInt32(chave) < id {
+			start = mid + 1
+		} else {
+			end = mid
+		}
+	}
+
+	return start, total, nil
+}
+
+func percorrerProdutosComIndice(armazenamento Armazenamento, indexProd string, filenameProd string, idInicial int32, fn func(Produto) bool) error {
+	indexFile, err := abrirArquivo(armazenamento, indexProd)
+	if err != nil {
+		return fmt.Errorf("erro ao abrir o arquivo de índice de produtos: %w", err)
+	}
+	defer indexFile.Close()
+
+	file, err := abrirArquivo(armazenamento, filenameProd)
+	if err != nil {
+		return fmt.Errorf("erro ao abrir o arquivo de produtos: %w", err)
+	}
+	defer file.Close()
+
+	var index IndexProduto
+	var produto Produto
+	tamanhoEntrada := binary.Size(index)
+
+	pos, total, err := posicaoInicialIndice(indexFile, tamanhoEntrada, idInicial)
+	if err != nil {
+		return err
+	}
+
+	_, err = indexFile.Seek(pos*int64(tamanhoEntrada), 0)
+	if err != nil {
+		return fmt.Errorf("erro ao buscar no arquivo de índice: %w", err)
+	}
+	reader := bufio.NewReader(indexFile)
+
+	for i := pos; i < total; i++ {
+		err = binary.Read(reader, binary.LittleEndian, &index)
+		if err != nil {
+			return fmt.Errorf("erro ao ler registro de índice de produto: %w", erroRegistro(indexProd, i*int64(tamanhoEntrada), err))
+		}
+
+		offset := binary.LittleEndian.Uint64(index.Offset[:])
+		_, err = file.Seek(int64(offset), 0)
+		if err != nil {
+			return fmt.Errorf("erro ao mover o cursor para o offset: %w", err)
+		}
+
+		err = binary.Read(file, binary.LittleEndian, &produto)
+		if err != nil {
+			return fmt.Errorf("erro ao ler registro de produto: %w", erroRegistro(filenameProd, int64(offset), err))
+		}
+
+		if !fn(produto) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func percorrerAcessosComIndice(armazenamento Armazenamento, indexAccess string, filenameAccess string, idInicial int32, fn func(Acesso) bool) error {
+	indexFile, err := abrirArquivo(armazenamento, indexAccess)
+	if err != nil {
+		return fmt.Errorf("erro ao abrir o arquivo de índice de acessos: %w", err)
+	}
+	defer indexFile.Close()
+
+	file, err := abrirArquivo(armazenamento, filenameAccess)
+	if err != nil {
+		return fmt.Errorf("erro ao abrir o arquivo de acessos: %w", err)
+	}
+	defer file.Close()
+
+	var index IndexAcesso
+	var acesso Acesso
+	tamanhoEntrada := binary.Size(index)
+
+	pos, total, err := posicaoInicialIndice(indexFile, tamanhoEntrada, idInicial)
+	if err != nil {
+		return err
+	}
+
+	_, err = indexFile.Seek(pos*int64(tamanhoEntrada), 0)
+	if err != nil {
+		return fmt.Errorf("erro ao buscar no arquivo de índice: %w", err)
+	}
+	reader := bufio.NewReader(indexFile)
+
+	for i := pos; i < total; i++ {
+		err = binary.Read(reader, binary.LittleEndian, &index)
+		if err != nil {
+			return fmt.Errorf("erro ao ler registro de índice de acesso: %w", erroRegistro(indexAccess, i*int64(tamanhoEntrada), err))
+		}
+
+		offset := binary.LittleEndian.Uint64(index.Offset[:])
+		_, err = file.Seek(int64(offset), 0)
+		if err != nil {
+			return fmt.Errorf("erro ao mover o cursor para o offset: %w", err)
+		}
+
+		err = binary.Read(file, binary.LittleEndian, &acesso)
+		if err != nil {
+			return fmt.Errorf("erro ao ler registro de acesso: %w", erroRegistro(filenameAccess, int64(offset), err))
+		}
+
+		if !fn(acesso) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func consultarProdutosPorIntervalo(armazenamento Armazenamento, indexProd string, filenameProd string, idInicial int32, idFinal int32) ([]Produto, error) {
+	var produtos []Produto
+	err := percorrerProdutosComIndice(armazenamento, indexProd, filenameProd, idInicial, func(produto Produto) bool {
+		if bytesToInt32(produto.ID) > idFinal {
+			return false
+		}
+		produtos = append(produtos, produto)
+		return true
+	})
+	return produtos, err
+}
+
+func consultarProdutosAPartirDe(armazenamento Armazenamento, indexProd string, filenameProd string, idInicial int32, limite int) ([]Produto, error) {
+	var produtos []Produto
+	if limite <= 0 {
+		return produtos, nil
+	}
+	err := percorrerProdutosComIndice(armazenamento, indexProd, filenameProd, idInicial, func(produto Produto) bool {
+		produtos = append(produtos, produto)
+		return len(produtos) < limite
+	})
+	return produtos, err
+}
+
+func consultarAcessosPorIntervalo(armazenamento Armazenamento, indexAccess string, filenameAccess string, idInicial int32, idFinal int32) ([]Acesso, error) {
+	var acessos []Acesso
+	err := percorrerAcessosComIndice(armazenamento, indexAccess, filenameAccess, idInicial, func(acesso Acesso) bool {
+		if bytesToInt32(acesso.ID) > idFinal {
+			return false
+		}
+		acessos = append(acessos, acesso)
+		return true
+	})
+	return acessos, err
+}
+
+func consultarAcessosAPartirDe(armazenamento Armazenamento, indexAccess string, filenameAccess string, idInicial int32, limite int) ([]Acesso, error) {
+	var acessos []Acesso
+	if limite <= 0 {
+		return acessos, nil
+	}
+	err := percorrerAcessosComIndice(armazenamento, indexAccess, filenameAccess, idInicial, func(acesso Acesso) bool {
+		acessos = append(acessos, acesso)
+		return len(acessos) < limite
+	})
+	return acessos, err
+}
+
+func buscarProdutoPorOffset(armazenamento Armazenamento, filename string, offset int64) (Produto, error) {
+	file, err := abrirArquivo(armazenamento, filename)
 	if err != nil {
 		return Produto{}, fmt.Errorf("erro ao abrir o arquivo de produtos: %w", err)
 	}
@@ -621,14 +806,14 @@ func buscarProdutoPorOffset(filename string, offset int64) (Produto, error) {
 
 	err = binary.Read(file, binary.LittleEndian, &produto)
 	if err != nil {
-		return Produto{}, fmt.Errorf("erro ao ler registro de produto: %w", err)
+		return Produto{}, fmt.Errorf("erro ao ler registro de produto: %w", erroRegistro(filename, offset, err))
 	}
 
 	return produto, nil
 }
 
-func buscarAcessoPorOffset(filename string, offset int64) (Acesso, error) {
-	file, err := os.Open(filename)
+func buscarAcessoPorOffset(armazenamento Armazenamento, filename string, offset int64) (Acesso, error) {
+	file, err := abrirArquivo(armazenamento, filename)
 	if err != nil {
 		return Acesso{}, fmt.Errorf("erro ao abrir o arquivo de acessos: %w", err)
 	}
@@ -642,132 +827,179 @@ func buscarAcessoPorOffset(filename string, offset int64) (Acesso, error) {
 
 	err = binary.Read(file, binary.LittleEndian, &acesso)
 	if err != nil {
-		return Acesso{}, fmt.Errorf("erro ao ler registro de acesso: %w", err)
+		return Acesso{}, fmt.Errorf("erro ao ler registro de acesso: %w", erroRegistro(filename, offset, err))
 	}
 
 	return acesso, nil
 }
 
-func inserirProdutoECriarIndice(filenameProd string, filenameIndex string, produto Produto) error {
-	err := inserirProduto(filenameProd, produto)
+func inserirProdutoECriarIndice(armazenamento Armazenamento, filenameProd string, filenameIndex string, indexPreco string, produto Produto) (Produto, error) {
+	offset, err := tamanhoArquivo(armazenamento, filenameProd)
 	if err != nil {
-		return err
+		return Produto{}, err
 	}
 
-	return criarIndiceProdutos(filenameProd, filenameIndex)
-}
+	produto, err = inserirProduto(armazenamento, filenameProd, produto)
+	if err != nil {
+		return Produto{}, err
+	}
 
-func inserirAcessoECriarIndice(filenameAccess string, filenameIndex string, acesso Acesso) error {
-	err := inserirAcesso(filenameAccess, acesso)
+	existe, err := indiceExiste(armazenamento, filenameIndex)
 	if err != nil {
-		return err
+		return Produto{}, err
+	}
+	if !existe {
+		err = criarIndiceProdutos(armazenamento, filenameProd, filenameIndex)
+	} else {
+		err = adicionarAoIndice(armazenamento, filenameIndex, produto.ID, offset)
+	}
+	if err != nil {
+		return Produto{}, err
 	}
 
-	return criarIndiceAcessos(filenameAccess, filenameIndex)
+	existe, err = indiceExiste(armazenamento, indexPreco)
+	if err != nil {
+		return Produto{}, err
+	}
+	if !existe {
+		return produto, criarIndicePrecos(armazenamento, filenameProd, indexPreco)
+	}
+	return produto, adicionarAoIndicePrecos(armazenamento, indexPreco, produto.Price, offset)
 }
 
-func removerProduto(filenameProd string, id int32) error {
-	tempFile, err := os.Create("temp_produtos.bin")
+func inserirAcessoECriarIndice(armazenamento Armazenamento, filenameAccess string, filenameIndex string, acesso Acesso) (Acesso, error) {
+	offset, err := tamanhoArquivo(armazenamento, filenameAccess)
 	if err != nil {
-		return fmt.Errorf("erro ao criar arquivo temporário para produtos: %w", err)
+		return Acesso{}, err
 	}
-	defer tempFile.Close()
 
-	file, err := os.Open(filenameProd)
+	acesso, err = inserirAcesso(armazenamento, filenameAccess, acesso)
 	if err != nil {
-		return fmt.Errorf("erro ao abrir o arquivo de produtos: %w", err)
+		return Acesso{}, err
 	}
-	defer file.Close()
 
-	var produto Produto
-	found := false
+	existe, err := indiceExiste(armazenamento, filenameIndex)
+	if err != nil {
+		return Acesso{}, err
+	}
+	if !existe {
+		return acesso, criarIndiceAcessos(armazenamento, filenameAccess, filenameIndex)
+	}
+	return acesso, adicionarAoIndice(armazenamento, filenameIndex, acesso.ID, offset)
+}
 
-	for {
-		err := binary.Read(file, binary.LittleEndian, &produto)
-		if err != nil {
-			if err.Error() == "EOF" {
-				break
-			}
-			return fmt.Errorf("erro ao ler registro de produto: %w", err)
-		}
+// offsetNoIndice devolve a posição no arquivo de dados do registro com o ID pedido.
+// Serve para IndexProduto e IndexAcesso, que têm o mesmo layout.
+func offsetNoIndice(armazenamento Armazenamento, indexName string, id int32) (int64, error) {
+	indexFile, err := abrirArquivo(armazenamento, indexName)
+	if err != nil {
+		return 0, fmt.Errorf("erro ao abrir o arquivo de índice: %w", err)
+	}
+	defer indexFile.Close()
 
-		if bytesToInt32(produto.ID) == id {
-			found = true
-			continue
-		}
+	var index IndexProduto
+	tamanhoEntrada := binary.Size(index)
+
+	pos, total, err := posicaoInicialIndice(indexFile, tamanhoEntrada, id)
+	if err != nil {
+		return 0, err
+	}
 
-		err = binary.Write(tempFile, binary.LittleEndian, produto)
+	if pos < total {
+		_, err = indexFile.Seek(pos*int64(tamanhoEntrada), 0)
 		if err != nil {
-			return fmt.Errorf("erro ao escrever produto no arquivo temporário: %w", err)
+			return 0, fmt.Errorf("erro ao buscar no arquivo de índice: %w", err)
 		}
-	}
 
-	if !found {
-		return fmt.Errorf("produto com ID %d não encontrado", id)
-	}
+		err = binary.Read(indexFile, binary.LittleEndian, &index)
+		if err != nil {
+			return 0, fmt.Errorf("erro ao ler registro de índice: %w", erroRegistro(indexName, pos*int64(tamanhoEntrada), err))
+		}
 
-	err = os.Rename("temp_produtos.bin", filenameProd)
-	if err != nil {
-		return fmt.Errorf("erro ao renomear arquivo temporário: %w", err)
+		if bytesToInt32(index.ID) == id {
+			return int64(binary.LittleEndian.Uint64(index.Offset[:])), nil
+		}
 	}
 
-	return criarIndiceProdutos(filenameProd, "indice_produtos.bin")
+	return 0, fmt.Errorf("registro com ID %d %w", id, ErrNotFound)
 }
 
-func removerAcesso(filenameAccess string, id int32) error {
-	tempFile, err := os.Create("temp_acessos.bin")
+// atualizarProduto sobrescreve o produto no lugar. Se o preço mudou, só a entrada dele
+// muda de posição no índice de preços.
+func atualizarProduto(armazenamento Armazenamento, indexProd string, indexPreco string, filenameProd string, produto Produto) error {
+	offset, err := offsetNoIndice(armazenamento, indexProd, bytesToInt32(produto.ID))
 	if err != nil {
-		return fmt.Errorf("erro ao criar arquivo temporário para acessos: %w", err)
+		return err
 	}
-	defer tempFile.Close()
 
-	file, err := os.Open(filenameAccess)
+	anterior, err := buscarProdutoPorOffset(armazenamento, filenameProd, offset)
 	if err != nil {
-		return fmt.Errorf("erro ao abrir o arquivo de acessos: %w", err)
+		return err
 	}
-	defer file.Close()
 
-	var acesso Acesso
-	found := false
+	var registro [tamanhoProduto]byte
+	codificarProduto(registro[:], &produto)
 
-	for {
-		err := binary.Read(file, binary.LittleEndian, &acesso)
-		if err != nil {
-			if err.Error() == "EOF" {
-				break
-			}
-			return fmt.Errorf("erro ao ler registro de acesso: %w", err)
-		}
+	err = sobrescreverRegistro(armazenamento, filenameProd, offset, registro[:])
+	if err != nil {
+		return fmt.Errorf("erro ao escrever produto no arquivo: %w", err)
+	}
 
-		if bytesToInt32(acesso.ID) == id {
-			found = true
-			continue
-		}
+	// O índice de IDs continua válido; a data dele é avançada para não parecer desatualizado.
+	if err := tocarIndice(armazenamento, indexProd); err != nil {
+		return err
+	}
 
-		err = binary.Write(tempFile, binary.LittleEndian, acesso)
-		if err != nil {
-			return fmt.Errorf("erro ao escrever acesso no arquivo temporário: %w", err)
-		}
+	if anterior.Price == produto.Price {
+		return tocarIndicePrecos(armazenamento, indexPreco)
 	}
+	if err := removerDoIndicePrecos(armazenamento, indexPreco, anterior.Price, offset); err != nil {
+		return err
+	}
+	return adicionarAoIndicePrecos(armazenamento, indexPreco, produto.Price, offset)
+}
 
-	if !found {
-		return fmt.Errorf("acesso com ID %d não encontrado", id)
+func atualizarAcesso(armazenamento Armazenamento, indexAccess string, filenameAccess string, acesso Acesso) error {
+	offset, err := offsetNoIndice(armazenamento, indexAccess, bytesToInt32(acesso.ID))
+	if err != nil {
+		return err
 	}
 
-	err = os.Rename("temp_acessos.bin", filenameAccess)
+	var registro [tamanhoAcesso]byte
+	codificarAcesso(registro[:], &acesso)
+
+	err = sobrescreverRegistro(armazenamento, filenameAccess, offset, registro[:])
 	if err != nil {
-		return fmt.Errorf("erro ao renomear arquivo temporário: %w", err)
+		return fmt.Errorf("erro ao escrever acesso no arquivo: %w", err)
 	}
 
-	return criarIndiceAcessos(filenameAccess, "indice_acessos.bin")
+	// O índice continua válido; a data dele é avançada para não parecer desatualizado.
+	return tocarIndice(armazenamento, indexAccess)
+}
+
+func removerProduto(armazenamento Armazenamento, filenameProd string, indexProd string, indexPreco string, id int32) error {
+	return removerProdutosEmLote(armazenamento, filenameProd, indexProd, indexPreco, []int32{id})
+}
+
+func removerAcesso(armazenamento Armazenamento, filenameAccess string, indexAccess string, id int32) error {
+	return removerAcessosEmLote(armazenamento, filenameAccess, indexAccess, []int32{id})
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if err := executarComando(os.Args[1], os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	armazenamento := arquivosPadrao().Armazenamento
 	filePath := "t.csv"
 	filenameProd := "produtos.bin"
 	filenameAccess := "acessos.bin"
 
-	if err := processCSV(filePath, filenameProd, filenameAccess); err != nil {
+	if err := processCSV(armazenamento, filePath, filenameProd, filenameAccess); err != nil {
 		fmt.Println(err)
 		return
 	}
@@ -775,217 +1007,173 @@ func main() {
 	fmt.Println("Arquivos binários criados com sucesso!")
 
 	fmt.Println("Lendo arquivo de produtos:")
-	err := lerArquivoProdutos(filenameProd)
+	err := lerArquivoProdutos(armazenamento, filenameProd)
 	if err != nil {
 		fmt.Println(err)
 	}
 
 	fmt.Println("\nLendo arquivo de acessos:")
-	err = lerArquivoAcessos(filenameAccess)
+	err = lerArquivoAcessos(armazenamento, filenameAccess)
 	if err != nil {
 		fmt.Println(err)
 	}
 
-	novoProduto := Produto{
-		ID:           int32ToBytes(0),
-		ProductID:    int32ToBytes(12345),
-		Price:        float32ToBytes(99.99),
-		Brand:        bytesToArray20(padString("Nova Marca", 20)),
-		CategoryCode: bytesToArray20(padString("Nova Categoria", 20)),
-	}
-	proximoID, err := proximoIDProdutos("produtos.bin")
+	novoProduto, err := ModeloProduto{ProductID: 12345, Price: 9999, Brand: "Nova Marca", CategoryCode: "Nova Categoria"}.registro()
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
-	novoProduto.ID = int32ToBytes(proximoID)
-
-	if err := inserirProduto("produtos.bin", novoProduto); err != nil {
+	if _, err := inserirProduto(armazenamento, "produtos.bin", novoProduto); err != nil {
 		fmt.Println(err)
 	}
 
-	novoAcesso := Acesso{
-		ID:          int32ToBytes(0),
-		UserSession: bytesToArray20(padString("sessao123", 20)),
-		UserID:      int32ToBytes(67890),
-		EventType:   bytesToArray10(padString("view", 10)),
-	}
-	proximoIDAccess, err := proximoIDAcessos("acessos.bin")
+	novoAcesso, err := ModeloAcesso{UserSession: "sessao123", UserID: 67890, EventType: "view"}.registro()
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
-	novoAcesso.ID = int32ToBytes(proximoIDAccess)
-
-	if err := inserirAcesso("acessos.bin", novoAcesso); err != nil {
+	if _, err := inserirAcesso(armazenamento, "acessos.bin", novoAcesso); err != nil {
 		fmt.Println(err)
 	}
 
 	fmt.Println("Lendo arquivo de produtos:")
-	err = lerArquivoProdutos(filenameProd)
+	err = lerArquivoProdutos(armazenamento, filenameProd)
 	if err != nil {
 		fmt.Println(err)
 	}
 
 	fmt.Println("\nLendo arquivo de acessos:")
-	err = lerArquivoAcessos(filenameAccess)
+	err = lerArquivoAcessos(armazenamento, filenameAccess)
 	if err != nil {
 		fmt.Println(err)
 	}
 
 	produtoIDParaPesquisar := int32(999)
-	produtoEncontrado, err := pesquisarProduto(filenameProd, produtoIDParaPesquisar)
+	produtoEncontrado, err := pesquisarProduto(armazenamento, filenameProd, produtoIDParaPesquisar)
 	if err != nil {
 		fmt.Println(err)
 	} else {
-		fmt.Printf("Produto encontrado: ID: %d, ProductID: %d, Preço: %.2f, Marca: %s, Categoria: %s\n",
-			bytesToInt32(produtoEncontrado.ID),
-			bytesToInt32(produtoEncontrado.ProductID),
-			bytesToFloat32(produtoEncontrado.Price),
-			string(produtoEncontrado.Brand[:]),
-			string(produtoEncontrado.CategoryCode[:]),
-		)
+		fmt.Printf("Produto encontrado: %s\n", produtoEncontrado)
 	}
 
 	acessoIDParaPesquisar := int32(999)
-	acessoEncontrado, err := pesquisarAcesso(filenameAccess, acessoIDParaPesquisar)
+	acessoEncontrado, err := pesquisarAcesso(armazenamento, filenameAccess, acessoIDParaPesquisar)
 	if err != nil {
 		fmt.Println(err)
 	} else {
-		fmt.Printf("Acesso encontrado: ID: %d, Sessão: %s, UserID: %d, Evento: %s\n",
-			bytesToInt32(acessoEncontrado.ID),
-			string(acessoEncontrado.UserSession[:]),
-			bytesToInt32(acessoEncontrado.UserID),
-			string(acessoEncontrado.EventType[:]),
-		)
+		fmt.Printf("Acesso encontrado: %s\n", acessoEncontrado)
 	}
 
-	produtoMaisCaro, err := encontrarProdutoMaisCaro(filenameProd)
-	if err != nil {
-		fmt.Println(err)
-	} else {
-		fmt.Printf("Produto mais caro: ID: %d, ProductID: %d, Preço: %.2f, Marca: %s, Categoria: %s\n",
-			bytesToInt32(produtoMaisCaro.ID),
-			bytesToInt32(produtoMaisCaro.ProductID),
-			bytesToFloat32(produtoMaisCaro.Price),
-			string(produtoMaisCaro.Brand[:]),
-			string(produtoMaisCaro.CategoryCode[:]),
-		)
-	}
+	indexProd := "indice_produtos.dat"
+	indexAccess := "indice_acessos.dat"
+	indexPreco := "indice_precos.dat"
 
-	sessao, count, err := userSessionMaisFrequente(filenameAccess)
+	sessao, count, err := userSessionMaisFrequente(armazenamento, filenameAccess)
 	if err != nil {
 		fmt.Println(err)
 	} else {
 		fmt.Printf("A UserSession mais frequente é: %s, com %d ocorrências.\n", sessao, count)
 	}
 
-	indexProd := "indice_produtos.dat"
-	indexAccess := "indice_acessos.dat"
-
-	err = criarIndiceProdutos(filenameProd, indexProd)
+	err = criarIndiceProdutos(armazenamento, filenameProd, indexProd)
 	if err != nil {
 		fmt.Println("Erro ao criar índice de produtos:", err)
 		return
 	}
 
-	err = criarIndiceAcessos(filenameAccess, indexAccess)
+	err = criarIndiceAcessos(armazenamento, filenameAccess, indexAccess)
 	if err != nil {
 		fmt.Println("Erro ao criar índice de acessos:", err)
 		return
 	}
 
-	produtoIDParaConsultar := int32(998)
-	produtoEncontrado, err = consultarProdutoComIndice(indexProd, filenameProd, produtoIDParaConsultar)
+	err = criarIndicePrecos(armazenamento, filenameProd, indexPreco)
+	if err != nil {
+		fmt.Println("Erro ao criar índice de preços:", err)
+		return
+	}
+
+	produtoMaisCaro, err := encontrarProdutoMaisCaro(armazenamento, indexPreco, filenameProd)
 	if err != nil {
 		fmt.Println(err)
 	} else {
-		fmt.Printf("Produto encontrado: ID: %d, ProductID: %d, Preço: %.2f, Marca: %s, Categoria: %s\n",
-			bytesToInt32(produtoEncontrado.ID),
-			bytesToInt32(produtoEncontrado.ProductID),
-			bytesToFloat32(produtoEncontrado.Price),
-			string(produtoEncontrado.Brand[:]),
-			string(produtoEncontrado.CategoryCode[:]),
-		)
+		fmt.Printf("Produto mais caro: %s\n", produtoMaisCaro)
 	}
 
-	acessoIDParaConsultar := int32(998)
-	acessoEncontrado, err = consultarAcessoComIndice(indexAccess, filenameAccess, acessoIDParaConsultar)
+	produtosFaixa, err := consultarProdutosPorFaixaDePreco(armazenamento, indexPreco, filenameProd, 50*escalaPreco, 200*escalaPreco)
 	if err != nil {
 		fmt.Println(err)
 	} else {
-		fmt.Printf("Acesso encontrado: ID: %d, Sessão: %s, UserID: %d, Evento: %s\n",
-			bytesToInt32(acessoEncontrado.ID),
-			string(acessoEncontrado.UserSession[:]),
-			bytesToInt32(acessoEncontrado.UserID),
-			string(acessoEncontrado.EventType[:]),
-		)
+		fmt.Printf("Produtos com preço entre 50.00 e 200.00: %d\n", len(produtosFaixa))
 	}
 
-	novoProduto = Produto{
-		ID:           int32ToBytes(0),
-		ProductID:    int32ToBytes(12345),
-		Price:        float32ToBytes(99.99),
-		Brand:        bytesToArray20(padString("Nova Marca", 20)),
-		CategoryCode: bytesToArray20(padString("Nova Categoria", 20)),
+	produtosBaratos, err := topProdutosPorPreco(armazenamento, indexPreco, filenameProd, 3, false)
+	if err != nil {
+		fmt.Println(err)
+	} else {
+		for _, produto := range produtosBaratos {
+			fmt.Printf("Produto barato: ID: %d, Preço: %s\n", bytesToInt32(produto.ID), bytesToPreco(produto.Price))
+		}
 	}
-	proximoID, err = proximoIDProdutos(filenameProd)
+
+	produtoIDParaConsultar := int32(998)
+	produtoEncontrado, err = consultarProdutoComIndice(armazenamento, indexProd, filenameProd, produtoIDParaConsultar)
 	if err != nil {
 		fmt.Println(err)
-		return
+	} else {
+		fmt.Printf("Produto encontrado: %s\n", produtoEncontrado)
 	}
-	novoProduto.ID = int32ToBytes(proximoID)
 
-	if err := inserirProdutoECriarIndice("produtos.bin", indexProd, novoProduto); err != nil {
+	acessoIDParaConsultar := int32(998)
+	acessoEncontrado, err = consultarAcessoComIndice(armazenamento, indexAccess, filenameAccess, acessoIDParaConsultar)
+	if err != nil {
 		fmt.Println(err)
+	} else {
+		fmt.Printf("Acesso encontrado: %s\n", acessoEncontrado)
 	}
 
-	novoAcesso = Acesso{
-		ID:          int32ToBytes(0),
-		UserSession: bytesToArray20(padString("sessao123", 20)),
-		UserID:      int32ToBytes(67890),
-		EventType:   bytesToArray10(padString("view", 10)),
+	produtosIntervalo, err := consultarProdutosPorIntervalo(armazenamento, indexProd, filenameProd, 990, 995)
+	if err != nil {
+		fmt.Println(err)
+	} else {
+		fmt.Printf("Produtos com ID entre 990 e 995: %d\n", len(produtosIntervalo))
 	}
-	proximoIDAccess, err = proximoIDAcessos(filenameAccess)
+
+	acessosPagina, err := consultarAcessosAPartirDe(armazenamento, indexAccess, filenameAccess, 990, 5)
 	if err != nil {
 		fmt.Println(err)
-		return
+	} else {
+		for _, acesso := range acessosPagina {
+			fmt.Printf("Acesso - %s\n", acesso)
+		}
+	}
+
+	if _, err := inserirProdutoECriarIndice(armazenamento, "produtos.bin", indexProd, indexPreco, novoProduto); err != nil {
+		fmt.Println(err)
 	}
-	novoAcesso.ID = int32ToBytes(proximoIDAccess)
 
-	if err := inserirAcessoECriarIndice("acessos.bin", indexAccess, novoAcesso); err != nil {
+	if _, err := inserirAcessoECriarIndice(armazenamento, "acessos.bin", indexAccess, novoAcesso); err != nil {
 		fmt.Println(err)
 	}
 
 	produtoIDParaConsultar = int32(1001)
-	produtoEncontrado, err = consultarProdutoComIndice(indexProd, filenameProd, produtoIDParaConsultar)
+	produtoEncontrado, err = consultarProdutoComIndice(armazenamento, indexProd, filenameProd, produtoIDParaConsultar)
 	if err != nil {
 		fmt.Println(err)
 	} else {
-		fmt.Printf("Produto encontrado: ID: %d, ProductID: %d, Preço: %.2f, Marca: %s, Categoria: %s\n",
-			bytesToInt32(produtoEncontrado.ID),
-			bytesToInt32(produtoEncontrado.ProductID),
-			bytesToFloat32(produtoEncontrado.Price),
-			string(produtoEncontrado.Brand[:]),
-			string(produtoEncontrado.CategoryCode[:]),
-		)
+		fmt.Printf("Produto encontrado: %s\n", produtoEncontrado)
 	}
 
 	acessoIDParaConsultar = int32(1001)
-	acessoEncontrado, err = consultarAcessoComIndice(indexAccess, filenameAccess, acessoIDParaConsultar)
+	acessoEncontrado, err = consultarAcessoComIndice(armazenamento, indexAccess, filenameAccess, acessoIDParaConsultar)
 	if err != nil {
 		fmt.Println(err)
 	} else {
-		fmt.Printf("Acesso encontrado: ID: %d, Sessão: %s, UserID: %d, Evento: %s\n",
-			bytesToInt32(acessoEncontrado.ID),
-			string(acessoEncontrado.UserSession[:]),
-			bytesToInt32(acessoEncontrado.UserID),
-			string(acessoEncontrado.EventType[:]),
-		)
+		fmt.Printf("Acesso encontrado: %s\n", acessoEncontrado)
 	}
 
 	produtoIdParaRemover := int32(1001)
-	err = removerProduto(filenameProd, produtoIdParaRemover)
+	err = removerProduto(armazenamento, filenameProd, indexProd, indexPreco, produtoIdParaRemover)
 	if err != nil {
 		fmt.Println(err)
 	} else {
@@ -993,7 +1181,7 @@ func main() {
 	}
 
 	acessoIdParaRemover := int32(1001)
-	err = removerAcesso(filenameAccess, acessoIdParaRemover)
+	err = removerAcesso(armazenamento, filenameAccess, indexAccess, acessoIdParaRemover)
 	if err != nil {
 		fmt.Println(err)
 	} else {
@@ -1001,30 +1189,19 @@ func main() {
 	}
 
 	produtoIDParaConsultar = int32(1001)
-	produtoEncontrado, err = consultarProdutoComIndice(indexProd, filenameProd, produtoIDParaConsultar)
+	produtoEncontrado, err = consultarProdutoComIndice(armazenamento, indexProd, filenameProd, produtoIDParaConsultar)
 	if err != nil {
 		fmt.Println(err)
 	} else {
-		fmt.Printf("Produto encontrado: ID: %d, ProductID: %d, Preço: %.2f, Marca: %s, Categoria: %s\n",
-			bytesToInt32(produtoEncontrado.ID),
-			bytesToInt32(produtoEncontrado.ProductID),
-			bytesToFloat32(produtoEncontrado.Price),
-			string(produtoEncontrado.Brand[:]),
-			string(produtoEncontrado.CategoryCode[:]),
-		)
+		fmt.Printf("Produto encontrado: %s\n", produtoEncontrado)
 	}
 
 	acessoIDParaConsultar = int32(1001)
-	acessoEncontrado, err = consultarAcessoComIndice(indexAccess, filenameAccess, acessoIDParaConsultar)
+	acessoEncontrado, err = consultarAcessoComIndice(armazenamento, indexAccess, filenameAccess, acessoIDParaConsultar)
 	if err != nil {
 		fmt.Println(err)
 	} else {
-		fmt.Printf("Acesso encontrado: ID: %d, Sessão: %s, UserID: %d, Evento: %s\n",
-			bytesToInt32(acessoEncontrado.ID),
-			string(acessoEncontrado.UserSession[:]),
-			bytesToInt32(acessoEncontrado.UserID),
-			string(acessoEncontrado.EventType[:]),
-		)
+		fmt.Printf("Acesso encontrado: %s\n", acessoEncontrado)
 	}
 
 }
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCodificarProduto(t *testing.T) {
+	produto := Produto{
+		ID:           int32ToBytes(-7),
+		ProductID:    int32ToBytes(1 << 30),
+		Price:        precoToBytes(-123456),
+		Brand:        bytesToArray20(padString("marca", 20)),
+		CategoryCode: bytesToArray20(padString("eletrônicos.celular", 20)),
+	}
+
+	var registro [tamanhoProduto]byte
+	codificarProduto(registro[:], &produto)
+	var decodificado Produto
+	decodificarProduto(registro[:], &decodificado)
+	if decodificado != produto {
+		t.Errorf("decodificado = %+v, quero %+v", decodificado, produto)
+	}
+
+	// O layout é o mesmo que binary.Write gravava antes do scanner.
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, produto); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), registro[:]) {
+		t.Errorf("codificarProduto = %x, binary.Write = %x", registro, buf.Bytes())
+	}
+}
+
+func TestCodificarAcesso(t *testing.T) {
+	acesso := Acesso{
+		ID:          int32ToBytes(1<<31 - 1),
+		UserSession: bytesToArray20(padString("sessão-abc", 20)),
+		UserID:      int32ToBytes(-1),
+		EventType:   bytesToArray10(padString("purchase", 10)),
+	}
+
+	var registro [tamanhoAcesso]byte
+	codificarAcesso(registro[:], &acesso)
+	var decodificado Acesso
+	decodificarAcesso(registro[:], &decodificado)
+	if decodificado != acesso {
+		t.Errorf("decodificado = %+v, quero %+v", decodificado, acesso)
+	}
+
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, acesso); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), registro[:]) {
+		t.Errorf("codificarAcesso = %x, binary.Write = %x", registro, buf.Bytes())
+	}
+}
+
+func TestRegistrosDaLinha(t *testing.T) {
+	linha := []string{"2019-10-01 00:00:00 UTC", "cart", "7219", "1", "jardinagem.irrigação", "marca", "640.44", "2", "sess25"}
+	produto, acesso, err := registrosDaLinha(linha)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id := bytesToInt32(produto.ProductID); id != 7219 {
+		t.Errorf("product_id = %d, quero 7219", id)
+	}
+	if preco := bytesToPreco(produto.Price); preco != 64044 {
+		t.Errorf("price = %d centavos, quero 64044", preco)
+	}
+	if brand := textoFixo(produto.Brand[:]); brand != "marca" {
+		t.Errorf("brand = %q, quero \"marca\"", brand)
+	}
+	// category_code passa de 20 bytes e é cortado antes do caractere que não cabe.
+	if categoria := textoFixo(produto.CategoryCode[:]); categoria != "jardinagem.irrigaç" {
+		t.Errorf("category_code = %q, quero \"jardinagem.irrigaç\"", categoria)
+	}
+	if id := bytesToInt32(acesso.UserID); id != 2 {
+		t.Errorf("user_id = %d, quero 2", id)
+	}
+	if sessao := textoFixo(acesso.UserSession[:]); sessao != "sess25" {
+		t.Errorf("user_session = %q, quero \"sess25\"", sessao)
+	}
+	if evento := textoFixo(acesso.EventType[:]); evento != "cart" {
+		t.Errorf("event_type = %q, quero \"cart\"", evento)
+	}
+	if produto.ID != [4]byte{} || acesso.ID != [4]byte{} {
+		t.Error("registrosDaLinha não deve atribuir IDs")
+	}
+}
+
+func TestRegistrosDaLinhaCampoInvalido(t *testing.T) {
+	for _, caso := range []struct {
+		campo string
+		linha []string
+	}{
+		{"product_id", []string{"2019-10-01 00:00:00 UTC", "view", "", "1", "cat", "m", "1.00", "2", "s"}},
+		{"product_id", []string{"2019-10-01 00:00:00 UTC", "view", "99999999999", "1", "cat", "m", "1.00", "2", "s"}},
+		{"user_id", []string{"2019-10-01 00:00:00 UTC", "view", "7", "1", "cat", "m", "1.00", "dois", "s"}},
+		{"price", []string{"2019-10-01 00:00:00 UTC", "view", "7", "1", "cat", "m", "1,00", "2", "s"}},
+	} {
+		_, _, err := registrosDaLinha(caso.linha)
+		if !errors.Is(err, ErrInvalidField) || !strings.Contains(err.Error(), caso.campo) {
+			t.Errorf("registrosDaLinha(%q) = %v, quero ErrInvalidField em %s", caso.linha, err, caso.campo)
+		}
+	}
+}
+
+func TestProcessCSV(t *testing.T) {
+	dir := t.TempDir()
+	csv := filepath.Join(dir, "t.csv")
+	conteudo := "event_time,event_type,product_id,category_id,category_code,brand,price,user_id,user_session\n" +
+		"2019-10-01 00:00:00 UTC,view,2033,1,cat.3,brand2,650.37,49,sess29\n" +
+		"2019-10-01 00:00:00 UTC,cart,7219,1,cat.2,brand1,0.5,2,sess25\n" +
+		"2019-10-01 00:00:01 UTC,purchase,15,1,cat.1,,12,7,sess1\n"
+	if err := os.WriteFile(csv, []byte(conteudo), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	armazenamento := novoArmazenamentoMemoria()
+	if err := processCSV(armazenamento, csv, "produtos.bin", "acessos.bin"); err != nil {
+		t.Fatal(err)
+	}
+
+	var produtos []string
+	for produto, err := range produtosDoArquivo(armazenamento, "produtos.bin") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		produtos = append(produtos, fmt.Sprintf("%d:%d:%s:%s", bytesToInt32(produto.ID), bytesToInt32(produto.ProductID), bytesToPreco(produto.Price), textoFixo(produto.Brand[:])))
+	}
+	if quero := []string{"1:2033:650.37:brand2", "2:7219:0.50:brand1", "3:15:12.00:"}; !slices.Equal(produtos, quero) {
+		t.Errorf("produtos = %v, quero %v", produtos, quero)
+	}
+
+	var acessos []string
+	for acesso, err := range acessosDoArquivo(armazenamento, "acessos.bin") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		acessos = append(acessos, fmt.Sprintf("%d:%s:%d", bytesToInt32(acesso.ID), textoFixo(acesso.EventType[:]), bytesToInt32(acesso.UserID)))
+	}
+	if quero := []string{"1:view:49", "2:cart:2", "3:purchase:7"}; !slices.Equal(acessos, quero) {
+		t.Errorf("acessos = %v, quero %v", acessos, quero)
+	}
+
+	// A sequência continua depois do último ID importado.
+	for _, filename := range []string{"produtos.bin", "acessos.bin"} {
+		proximo, err := lerSequencia(armazenamento, filename, func(Armazenamento, string) (int32, error) {
+			return 0, errors.New("sequência não gravada")
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if proximo != 4 {
+			t.Errorf("próximo ID de %s = %d, quero 4", filename, proximo)
+		}
+	}
+}
+
+func TestProcessCSVSemArquivo(t *testing.T) {
+	err := processCSV(novoArmazenamentoMemoria(), filepath.Join(t.TempDir(), "nao-existe.csv"), "produtos.bin", "acessos.bin")
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("processCSV sem CSV = %v, quero os.ErrNotExist", err)
+	}
+}
+
+func TestProcessCSVPrecoInvalido(t *testing.T) {
+	csv := filepath.Join(t.TempDir(), "t.csv")
+	cabecalho := "event_time,event_type,product_id,category_id,category_code,brand,price,user_id,user_session\n"
+	valida := "2019-10-01 00:00:00 UTC,view,2033,1,cat.3,brand2,650.37,49,sess29\n"
+	invalida := "2019-10-01 00:00:00 UTC,cart,7219,1,cat.2,brand1,6x5,2,sess25\n"
+	if err := os.WriteFile(csv, []byte(cabecalho+valida+invalida), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := processCSV(novoArmazenamentoMemoria(), csv, "produtos.bin", "acessos.bin")
+	var erroLinha *RecordError
+	if !errors.As(err, &erroLinha) || !errors.Is(err, ErrInvalidField) {
+		t.Fatalf("processCSV com preço inválido = %v, quero *RecordError com ErrInvalidField", err)
+	}
+	if quero := int64(len(cabecalho) + len(valida)); erroLinha.Arquivo != csv || erroLinha.Offset != quero {
+		t.Errorf("erro em %s offset %d, quero %s offset %d", erroLinha.Arquivo, erroLinha.Offset, csv, quero)
+	}
+}
+
+// Até a correção da coluna de product_id, ele era lido de category_id. Um CSV com as
+// duas colunas trocadas de lugar tem de ser recusado, não importado com os campos
+// trocados.
+func TestProcessCSVCabecalho(t *testing.T) {
+	csv := filepath.Join(t.TempDir(), "t.csv")
+	conteudo := "event_time,event_type,category_id,product_id,category_code,brand,price,user_id,user_session\n" +
+		"2019-10-01 00:00:00 UTC,view,1,2033,cat.3,brand2,650.37,49,sess29\n"
+	if err := os.WriteFile(csv, []byte(conteudo), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := processCSV(novoArmazenamentoMemoria(), csv, "produtos.bin", "acessos.bin")
+	if !errors.Is(err, ErrInvalidField) {
+		t.Fatalf("processCSV com colunas trocadas = %v, quero ErrInvalidField", err)
+	}
+}
+
+// gravarBancoBusca grava, em ordem, um produto e um acesso para cada ID, com os índices
+// de IDs. O product_id e o user_id de cada registro são o próprio ID vezes 10.
+func gravarBancoBusca(tb testing.TB, armazenamento Armazenamento, ids []int32) Arquivos {
+	tb.Helper()
+	arquivos := arquivosPadrao()
+	arquivos.Armazenamento = armazenamento
+
+	fileProd, err := criarArquivo(armazenamento, arquivos.Produtos)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer fileProd.Close()
+	fileAccess, err := criarArquivo(armazenamento, arquivos.Acessos)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer fileAccess.Close()
+
+	var registroProd [tamanhoProduto]byte
+	var registroAcesso [tamanhoAcesso]byte
+	for _, id := range ids {
+		produto := Produto{ID: int32ToBytes(id), ProductID: int32ToBytes(id * 10), Price: precoToBytes(Preco(id))}
+		codificarProduto(registroProd[:], &produto)
+		if _, err := fileProd.Write(registroProd[:]); err != nil {
+			tb.Fatal(err)
+		}
+		acesso := Acesso{ID: int32ToBytes(id), UserID: int32ToBytes(id * 10)}
+		codificarAcesso(registroAcesso[:], &acesso)
+		if _, err := fileAccess.Write(registroAcesso[:]); err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	if err := criarIndiceProdutos(armazenamento, arquivos.Produtos, arquivos.IndiceProdutos); err != nil {
+		tb.Fatal(err)
+	}
+	if err := criarIndiceAcessos(armazenamento, arquivos.Acessos, arquivos.IndiceAcessos); err != nil {
+		tb.Fatal(err)
+	}
+	return arquivos
+}
+
+// varrerAcessos é a referência das buscas de acessos: lê o arquivo inteiro.
+func varrerAcessos(armazenamento Armazenamento, filename string, id int32) (Acesso, error) {
+	for acesso, err := range acessosDoArquivo(armazenamento, filename) {
+		if err != nil {
+			return Acesso{}, err
+		}
+		if bytesToInt32(acesso.ID) == id {
+			return *acesso, nil
+		}
+	}
+	return Acesso{}, fmt.Errorf("acesso com ID %d %w", id, ErrNotFound)
+}
+
+// conferirBuscas compara as buscas binárias, no arquivo de dados e pelo índice, com a
+// varredura linear para id.
+func conferirBuscas(tb testing.TB, arquivos Arquivos, id int32) {
+	tb.Helper()
+	armazenamento := arquivos.Armazenamento
+
+	esperado, errEsperado := buscarPorVarredura(armazenamento, arquivos.Produtos, id)
+	if errEsperado != nil && !errors.Is(errEsperado, ErrNotFound) {
+		tb.Fatal(errEsperado)
+	}
+	for nome, buscar := range map[string]func() (Produto, error){
+		"pesquisarProduto": func() (Produto, error) { return pesquisarProduto(armazenamento, arquivos.Produtos, id) },
+		"consultarProdutoComIndice": func() (Produto, error) {
+			return consultarProdutoComIndice(armazenamento, arquivos.IndiceProdutos, arquivos.Produtos, id)
+		},
+	} {
+		produto, err := buscar()
+		if errors.Is(err, ErrNotFound) != errors.Is(errEsperado, ErrNotFound) || err != nil && !errors.Is(err, ErrNotFound) {
+			tb.Errorf("%s(%d): erro %v, a varredura deu %v", nome, id, err, errEsperado)
+		} else if produto != esperado {
+			tb.Errorf("%s(%d) = %+v, a varredura deu %+v", nome, id, produto, esperado)
+		}
+	}
+
+	esperadoAcesso, errEsperado := varrerAcessos(armazenamento, arquivos.Acessos, id)
+	if errEsperado != nil && !errors.Is(errEsperado, ErrNotFound) {
+		tb.Fatal(errEsperado)
+	}
+	for nome, buscar := range map[string]func() (Acesso, error){
+		"pesquisarAcesso": func() (Acesso, error) { return pesquisarAcesso(armazenamento, arquivos.Acessos, id) },
+		"consultarAcessoComIndice": func() (Acesso, error) {
+			return consultarAcessoComIndice(armazenamento, arquivos.IndiceAcessos, arquivos.Acessos, id)
+		},
+	} {
+		acesso, err := buscar()
+		if errors.Is(err, ErrNotFound) != errors.Is(errEsperado, ErrNotFound) || err != nil && !errors.Is(err, ErrNotFound) {
+			tb.Errorf("%s(%d): erro %v, a varredura deu %v", nome, id, err, errEsperado)
+		} else if acesso != esperadoAcesso {
+			tb.Errorf("%s(%d) = %+v, a varredura deu %+v", nome, id, acesso, esperadoAcesso)
+		}
+	}
+}
+
+func TestPesquisarProduto(t *testing.T) {
+	for _, caso := range []struct {
+		nome string
+		ids  []int32
+	}{
+		{"vazio", nil},
+		{"um registro", []int32{1}},
+		{"dois registros", []int32{1, 2}},
+		{"consecutivos", []int32{1, 2, 3, 4, 5, 6, 7, 8}},
+		{"com buracos", []int32{2, 3, 5, 8, 13, 21, 34}},
+	} {
+		t.Run(caso.nome, func(t *testing.T) {
+			arquivos := gravarBancoBusca(t, novoArmazenamentoMemoria(), caso.ids)
+
+			// Antes do primeiro, o primeiro, todos os do meio (presentes ou não), o
+			// último e um depois do fim.
+			ultimo := int32(0)
+			if len(caso.ids) > 0 {
+				ultimo = caso.ids[len(caso.ids)-1]
+			}
+			for id := int32(-1); id <= ultimo+2; id++ {
+				conferirBuscas(t, arquivos, id)
+			}
+		})
+	}
+}
+
+func TestRemoverProduto(t *testing.T) {
+	b := abrirBancoTeste(t)
+	produtos := make([]Produto, 6)
+	for i := range produtos {
+		produtos[i] = produtoTeste(t, int32(i), Preco(100*(i%3)), "m")
+	}
+	if _, err := b.inserirProdutos(produtos); err != nil {
+		t.Fatal(err)
+	}
+	arquivos := b.arquivos
+
+	// Primeiro, último e um do meio.
+	for _, id := range []int32{1, 6, 3} {
+		if err := removerProduto(arquivos.Armazenamento, arquivos.Produtos, arquivos.IndiceProdutos, arquivos.IndicePrecos, id); err != nil {
+			t.Fatalf("removerProduto(%d) = %v", id, err)
+		}
+	}
+	err := removerProduto(arquivos.Armazenamento, arquivos.Produtos, arquivos.IndiceProdutos, arquivos.IndicePrecos, 3)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("removerProduto de ID já removido = %v, quero ErrNotFound", err)
+	}
+
+	var ids []int32
+	for produto, err := range produtosDoArquivo(arquivos.Armazenamento, arquivos.Produtos) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, bytesToInt32(produto.ID))
+	}
+	if quero := []int32{2, 4, 5}; !slices.Equal(ids, quero) {
+		t.Errorf("IDs depois das remoções = %v, quero %v", ids, quero)
+	}
+	for id := int32(0); id <= 8; id++ {
+		conferirBuscas(t, arquivos, id)
+	}
+
+	problemas, err := verificarArquivos(arquivos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problemas) > 0 {
+		t.Errorf("arquivos inconsistentes depois das remoções: %v", problemas)
+	}
+}
+
+// FuzzPesquisarProduto gera IDs crescentes a partir dos saltos em dados e confere as
+// buscas binárias contra a varredura para id e para os extremos do arquivo.
+func FuzzPesquisarProduto(f *testing.F) {
+	f.Add([]byte{}, int32(1))
+	f.Add([]byte{0, 0, 0}, int32(3))
+	f.Add([]byte{1, 5, 0, 200}, int32(8))
+	f.Add([]byte{255, 255}, int32(-1))
+	f.Fuzz(func(t *testing.T, dados []byte, id int32) {
+		if len(dados) > 200 {
+			dados = dados[:200]
+		}
+		ids := make([]int32, len(dados))
+		anterior := int32(0)
+		for i, salto := range dados {
+			anterior += 1 + int32(salto)
+			ids[i] = anterior
+		}
+
+		arquivos := gravarBancoBusca(t, novoArmazenamentoMemoria(), ids)
+		conferirBuscas(t, arquivos, id)
+		if len(ids) > 0 {
+			conferirBuscas(t, arquivos, ids[0])
+			conferirBuscas(t, arquivos, ids[len(ids)-1])
+			conferirBuscas(t, arquivos, ids[len(ids)-1]+1)
+		}
+	})
+}
+
+// FuzzRegistrosDaLinha confere que qualquer linha de 9 campos vira registros sem pânico,
+// com textos cortados em UTF-8 válido e iguais ao original quando cabem, e que as linhas
+// com preço inválido são recusadas.
+func FuzzRegistrosDaLinha(f *testing.F) {
+	f.Add("view", "2033", "cat.3", "brand2", "650.37", "49", "sess29")
+	f.Add("purchase", "-1", "eletrônicos.smartphone.capa", "", "abc", "x", "sessão muito comprida para caber")
+	f.Add("", "99999999999", "\xff\xfe", "ç", "1e5", "", "")
+	f.Add("cart", "12", "cat", "m", "1.5", "", "sess")
+	f.Fuzz(func(t *testing.T, evento, productID, categoria, marca, preco, userID, sessao string) {
+		linha := []string{"2019-10-01 00:00:00 UTC", evento, productID, "1", categoria, marca, preco, userID, sessao}
+		produto, acesso, err := registrosDaLinha(linha)
+		produtoLido, errProduto := strconv.ParseInt(productID, 10, 32)
+		usuarioLido, errUsuario := strconv.ParseInt(userID, 10, 32)
+		valor, errPreco := lerPreco(preco)
+		if errProduto != nil || errUsuario != nil || errPreco != nil {
+			if !errors.Is(err, ErrInvalidField) {
+				t.Fatalf("linha com product_id %q, user_id %q e preço %q aceita, erro %v", productID, userID, preco, err)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytesToPreco(produto.Price) != valor {
+			t.Errorf("preço %q gravado como %s, quero %s", preco, bytesToPreco(produto.Price), valor)
+		}
+		if int64(bytesToInt32(produto.ProductID)) != produtoLido || int64(bytesToInt32(acesso.UserID)) != usuarioLido {
+			t.Errorf("product_id %q e user_id %q gravados como %d e %d", productID, userID, bytesToInt32(produto.ProductID), bytesToInt32(acesso.UserID))
+		}
+
+		for _, campo := range []struct {
+			original string
+			gravado  []byte
+		}{
+			{categoria, produto.CategoryCode[:]},
+			{marca, produto.Brand[:]},
+			{sessao, acesso.UserSession[:]},
+			{evento, acesso.EventType[:]},
+		} {
+			texto := textoFixo(campo.gravado)
+			if !utf8.ValidString(texto) {
+				t.Errorf("%q gravado como texto inválido %q", campo.original, texto)
+			}
+			cabe := len(campo.original) <= len(campo.gravado) && utf8.ValidString(campo.original) && !strings.Contains(campo.original, "\x00")
+			if cabe && texto != campo.original {
+				t.Errorf("%q gravado como %q", campo.original, texto)
+			}
+			if utf8.ValidString(campo.original) && !strings.HasPrefix(campo.original, texto) {
+				t.Errorf("%q gravado como %q, que não é prefixo do original", campo.original, texto)
+			}
+		}
+	})
+}
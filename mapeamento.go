@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+)
+
+// arquivoMapeado é uma visão somente leitura de um arquivo inteiro em memória.
+type arquivoMapeado struct {
+	nome  string
+	dados []byte
+	// copia indica que dados foi lido para a memória em vez de mapeado.
+	copia bool
+}
+
+func mapearArquivo(armazenamento Armazenamento, nome string) (*arquivoMapeado, error) {
+	file, err := abrirArquivo(armazenamento, nome)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir o arquivo %s: %w", nome, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao obter informações do arquivo %s: %w", nome, err)
+	}
+
+	m := &arquivoMapeado{nome: nome}
+	// mmap não aceita tamanho zero; um arquivo vazio fica com dados nil.
+	if info.Size() == 0 {
+		return m, nil
+	}
+
+	// Só arquivos do sistema operacional podem ser mapeados; os de outros armazenamentos
+	// são copiados para a memória, que para os leitores dá no mesmo.
+	osFile, ok := file.(*os.File)
+	if !ok {
+		m.dados = make([]byte, info.Size())
+		m.copia = true
+		_, err = io.ReadFull(file, m.dados)
+	} else {
+		m.dados, err = mapear(osFile, int(info.Size()))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao mapear o arquivo %s: %w", nome, err)
+	}
+	return m, nil
+}
+
+func (m *arquivoMapeado) fechar() error {
+	if m == nil || m.dados == nil {
+		return nil
+	}
+	if m.copia {
+		m.dados = nil
+		return nil
+	}
+	err := desmapear(m.dados)
+	m.dados = nil
+	return err
+}
+
+// offsetNoIndice faz a busca binária de id num índice de ID mapeado.
+func (m *arquivoMapeado) offsetNoIndice(id int32) (int64, error) {
+	if len(m.dados)%tamanhoIndiceID != 0 {
+		return 0, fmt.Errorf("%w: tamanho de %s (%d bytes) não é múltiplo da entrada de índice", ErrCorrupt, m.nome, len(m.dados))
+	}
+
+	total := len(m.dados) / tamanhoIndiceID
+	pos := sort.Search(total, func(i int) bool {
+		entrada := m.dados[i*tamanhoIndiceID:]
+		return int32(binary.LittleEndian.Uint32(entrada[0:4])) >= id
+	})
+	if pos == total {
+		return 0, fmt.Errorf("registro com ID %d %w", id, ErrNotFound)
+	}
+
+	entrada := m.dados[pos*tamanhoIndiceID : (pos+1)*tamanhoIndiceID]
+	if int32(binary.LittleEndian.Uint32(entrada[0:4])) != id {
+		return 0, fmt.Errorf("registro com ID %d %w", id, ErrNotFound)
+	}
+	return int64(binary.LittleEndian.Uint64(entrada[4:12])), nil
+}
+
+// registro devolve os bytes do registro em offset, sem copiar.
+func (m *arquivoMapeado) registro(offset int64, tamanho int) ([]byte, error) {
+	if offset < 0 || offset+int64(tamanho) > int64(len(m.dados)) {
+		return nil, erroRegistro(m.nome, offset, fmt.Errorf("%w: offset fora do arquivo (%d bytes)", ErrCorrupt, len(m.dados)))
+	}
+	return m.dados[offset : offset+int64(tamanho)], nil
+}
+
+// mapasBanco mantém mapeados os arquivos de dados e os índices de ID de um Banco.
+type mapasBanco struct {
+	produtos       *arquivoMapeado
+	acessos        *arquivoMapeado
+	indiceProdutos *arquivoMapeado
+	indiceAcessos  *arquivoMapeado
+}
+
+func mapearBanco(arquivos Arquivos) (*mapasBanco, error) {
+	mapas := &mapasBanco{}
+	destinos := []struct {
+		mapa **arquivoMapeado
+		nome string
+	}{
+		{&mapas.produtos, arquivos.Produtos},
+		{&mapas.acessos, arquivos.Acessos},
+		{&mapas.indiceProdutos, arquivos.IndiceProdutos},
+		{&mapas.indiceAcessos, arquivos.IndiceAcessos},
+	}
+
+	for _, destino := range destinos {
+		m, err := mapearArquivo(arquivos.Armazenamento, destino.nome)
+		if err != nil {
+			mapas.fechar()
+			return nil, err
+		}
+		*destino.mapa = m
+	}
+	return mapas, nil
+}
+
+func (m *mapasBanco) fechar() error {
+	var primeiro error
+	for _, mapa := range []*arquivoMapeado{m.produtos, m.acessos, m.indiceProdutos, m.indiceAcessos} {
+		if err := mapa.fechar(); err != nil && primeiro == nil {
+			primeiro = err
+		}
+	}
+	return primeiro
+}
+
+func (m *mapasBanco) consultarProduto(id int32) (Produto, error) {
+	offset, err := m.indiceProdutos.offsetNoIndice(id)
+	if errors.Is(err, ErrNotFound) {
+		return Produto{}, fmt.Errorf("produto com ID %d %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return Produto{}, err
+	}
+
+	b, err := m.produtos.registro(offset, tamanhoProduto)
+	if err != nil {
+		return Produto{}, fmt.Errorf("erro ao ler registro de produto: %w", err)
+	}
+
+	var produto Produto
+	decodificarProduto(b, &produto)
+	return produto, nil
+}
+
+func (m *mapasBanco) consultarAcesso(id int32) (Acesso, error) {
+	offset, err := m.indiceAcessos.offsetNoIndice(id)
+	if errors.Is(err, ErrNotFound) {
+		return Acesso{}, fmt.Errorf("acesso com ID %d %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return Acesso{}, err
+	}
+
+	b, err := m.acessos.registro(offset, tamanhoAcesso)
+	if err != nil {
+		return Acesso{}, fmt.Errorf("erro ao ler registro de acesso: %w", err)
+	}
+
+	var acesso Acesso
+	decodificarAcesso(b, &acesso)
+	return acesso, nil
+}
+
+func (m *mapasBanco) consultarProdutos(ids []int32) ([]*Produto, []int32, error) {
+	produtos := make([]*Produto, len(ids))
+	var ausentes []int32
+	for i, id := range ids {
+		produto, err := m.consultarProduto(id)
+		if errors.Is(err, ErrNotFound) {
+			ausentes = append(ausentes, id)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		produtos[i] = &produto
+	}
+
+	slices.Sort(ausentes)
+	return produtos, slices.Compact(ausentes), nil
+}
+
+func (m *mapasBanco) consultarAcessos(ids []int32) ([]*Acesso, []int32, error) {
+	acessos := make([]*Acesso, len(ids))
+	var ausentes []int32
+	for i, id := range ids {
+		acesso, err := m.consultarAcesso(id)
+		if errors.Is(err, ErrNotFound) {
+			ausentes = append(ausentes, id)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		acessos[i] = &acesso
+	}
+
+	slices.Sort(ausentes)
+	return acessos, slices.Compact(ausentes), nil
+}
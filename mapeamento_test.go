@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestBancoMapeadoDepoisDeEscritas(t *testing.T) {
+	arquivos := arquivosNoDiretorio(t.TempDir())
+	b, err := abrirBancoMapeado(arquivos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.fechar()
+
+	// Com o banco ainda vazio, nada está mapeado e a consulta não acha nada.
+	if _, err := b.consultarProduto(1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("consultarProduto num banco vazio = %v, quero ErrNotFound", err)
+	}
+
+	produtos, err := b.inserirProdutos([]Produto{produtoTeste(t, 10, 100, "a"), produtoTeste(t, 20, 200, "b"), produtoTeste(t, 30, 300, "c")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	acesso, err := b.inserirAcesso(acessoTeste(t, "sess", 7, "view"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Cada escrita remapeia: a leitura seguinte já vê o arquivo novo.
+	conferir := func(momento string, quero map[int32]Produto) {
+		t.Helper()
+		if b.mapas == nil {
+			t.Fatalf("%s: o banco deixou de estar mapeado", momento)
+		}
+		for id := int32(1); id <= 4; id++ {
+			produto, err := b.consultarProduto(id)
+			if esperado, ok := quero[id]; ok {
+				if err != nil || produto != esperado {
+					t.Errorf("%s: produto %d = %+v, %v; quero %+v", momento, id, produto, err, esperado)
+				}
+			} else if !errors.Is(err, ErrNotFound) {
+				t.Errorf("%s: produto %d = %v, quero ErrNotFound", momento, id, err)
+			}
+		}
+	}
+	conferir("depois de inserir", map[int32]Produto{1: produtos[0], 2: produtos[1], 3: produtos[2]})
+
+	produtos[1].Price = precoToBytes(250)
+	if err := b.atualizarProduto(produtos[1]); err != nil {
+		t.Fatal(err)
+	}
+	conferir("depois de atualizar", map[int32]Produto{1: produtos[0], 2: produtos[1], 3: produtos[2]})
+
+	if err := b.removerProduto(1); err != nil {
+		t.Fatal(err)
+	}
+	conferir("depois de remover", map[int32]Produto{2: produtos[1], 3: produtos[2]})
+
+	novo, err := b.inserirProduto(produtoTeste(t, 40, 400, "d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conferir("depois de inserir de novo", map[int32]Produto{2: produtos[1], 3: produtos[2], 4: novo})
+
+	encontrados, ausentes, err := b.consultarProdutos([]int32{4, 1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(encontrados) != 3 || encontrados[0] == nil || *encontrados[0] != novo || encontrados[1] != nil || *encontrados[2] != produtos[1] || !slices.Equal(ausentes, []int32{1}) {
+		t.Errorf("consultarProdutos(4, 1, 2) = %v, ausentes %v", encontrados, ausentes)
+	}
+
+	lido, err := b.consultarAcesso(bytesToInt32(acesso.ID))
+	if err != nil || lido != acesso {
+		t.Errorf("consultarAcesso = %+v, %v; quero %+v", lido, err, acesso)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+)
+
+// Até os preços passarem a ser Preco, o produto tinha 52 bytes, com o preço em float32
+// nos bytes 8:12 e marca e categoria logo depois. migrarPrecos converte um arquivo
+// nesse formato para o atual.
+const tamanhoProdutoFloat32 = 52
+
+// formatoFloat32 diz se filename parece estar no formato antigo: o tamanho fecha com
+// registros de 52 bytes e os primeiros IDs lidos assim são crescentes, o que não
+// acontece quando lidos com 56 bytes.
+func formatoFloat32(armazenamento Armazenamento, filename string) (bool, error) {
+	info, err := armazenamento.Info(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("erro ao verificar o arquivo %s: %w", filename, err)
+	}
+	if info.Size() == 0 || info.Size()%tamanhoProdutoFloat32 != 0 {
+		return false, nil
+	}
+
+	inicio, err := lerTrecho(armazenamento, filename, 0, int(min(info.Size(), 8*tamanhoProduto)))
+	if err != nil {
+		return false, err
+	}
+	antigo := idsCrescentes(inicio, tamanhoProdutoFloat32)
+	if info.Size()%tamanhoProduto != 0 {
+		return antigo, nil
+	}
+	return antigo && !idsCrescentes(inicio, tamanhoProduto), nil
+}
+
+// conferirFormatoPrecos recusa um arquivo de produtos no formato antigo: lido com
+// registros de 56 bytes, ele daria produtos embaralhados em vez de um erro.
+func conferirFormatoPrecos(armazenamento Armazenamento, filenameProd string) error {
+	antigo, err := formatoFloat32(armazenamento, filenameProd)
+	if err != nil {
+		return err
+	}
+	if antigo {
+		return fmt.Errorf("%w: %s tem preços em float32, do formato antigo; converta com o comando migrar-precos", ErrCorrupt, filenameProd)
+	}
+	return nil
+}
+
+func idsCrescentes(b []byte, tamanho int) bool {
+	var anterior int32
+	for i := 0; i+tamanho <= len(b); i += tamanho {
+		id := bytesToInt32([4]byte(b[i : i+4]))
+		if id <= anterior {
+			return false
+		}
+		anterior = id
+	}
+	return true
+}
+
+// migrarPrecos reescreve os produtos do formato float32 para centavos e recria os
+// índices. Com backup, o arquivo original é copiado antes para <produtos>.float32.
+// Devolve quantos produtos foram convertidos; um arquivo já no formato atual não é
+// tocado e dá 0, então rodar a migração de novo não muda nada.
+func migrarPrecos(arquivos Arquivos, backup bool) (int, error) {
+	antigo, err := formatoFloat32(arquivos.Armazenamento, arquivos.Produtos)
+	if err != nil {
+		return 0, err
+	}
+	if !antigo {
+		return 0, nil
+	}
+
+	// Uma atualização interrompida antes da migração ainda tem registros de 52 bytes.
+	if err := aplicarDiario(arquivos.Armazenamento, arquivos.Produtos, tamanhoProdutoFloat32); err != nil {
+		return 0, err
+	}
+
+	dados, err := lerArquivoInteiro(arquivos.Armazenamento, arquivos.Produtos)
+	if err != nil {
+		return 0, fmt.Errorf("erro ao ler o arquivo %s: %w", arquivos.Produtos, err)
+	}
+
+	if backup {
+		err = gravarAtomicamente(arquivos.Armazenamento, arquivoBackupFloat32(arquivos.Produtos), func(w io.Writer) error {
+			_, err := w.Write(dados)
+			return err
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := len(dados) / tamanhoProdutoFloat32
+	err = gravarAtomicamente(arquivos.Armazenamento, arquivos.Produtos, func(w io.Writer) error {
+		var registro [tamanhoProduto]byte
+		for i := range n {
+			offset := int64(i * tamanhoProdutoFloat32)
+			produto, err := produtoDoFormatoFloat32(dados[offset : offset+tamanhoProdutoFloat32])
+			if err != nil {
+				return erroRegistro(arquivos.Produtos, offset, err)
+			}
+			codificarProduto(registro[:], &produto)
+			if _, err := w.Write(registro[:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, reindexar(arquivos)
+}
+
+func arquivoBackupFloat32(filename string) string {
+	return filename + ".float32"
+}
+
+func produtoDoFormatoFloat32(b []byte) (Produto, error) {
+	preco, err := precoDeFloat32(bytesToFloat32([4]byte(b[8:12])))
+	if err != nil {
+		return Produto{}, err
+	}
+
+	var produto Produto
+	copy(produto.ID[:], b[0:4])
+	copy(produto.ProductID[:], b[4:8])
+	produto.Price = precoToBytes(preco)
+	copy(produto.Brand[:], b[12:32])
+	copy(produto.CategoryCode[:], b[32:52])
+	return produto, nil
+}
+
+// precoDeFloat32 converte um preço do formato antigo pelo menor decimal que representa
+// o float32, arredondando para o centavo mais próximo.
+func precoDeFloat32(f float32) (Preco, error) {
+	if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+		return 0, fmt.Errorf("preço %v não é um número finito", f)
+	}
+	return Preco(math.Round(float32ParaFloat64(f) * escalaPreco)), nil
+}
+
+// float32ParaFloat64 converte pelo menor decimal que representa o float32,
+// para que 99.99 continue 99.99 e não 99.98999786376953.
+func float32ParaFloat64(f float32) float64 {
+	n, _ := strconv.ParseFloat(strconv.FormatFloat(float64(f), 'f', -1, 32), 64)
+	return n
+}
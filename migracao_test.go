@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// gravarProdutosFloat32 grava os preços como o formato antigo gravava: registros de 52
+// bytes com o preço em float32, IDs a partir de 1.
+func gravarProdutosFloat32(t *testing.T, filename string, precos []float32) {
+	t.Helper()
+	var dados []byte
+	for i, preco := range precos {
+		registro := make([]byte, tamanhoProdutoFloat32)
+		binary.LittleEndian.PutUint32(registro[0:4], uint32(i+1))
+		binary.LittleEndian.PutUint32(registro[4:8], uint32(100+i))
+		binary.LittleEndian.PutUint32(registro[8:12], math.Float32bits(preco))
+		copy(registro[12:32], padString("marca", 20))
+		copy(registro[32:52], padString("categoria", 20))
+		dados = append(dados, registro...)
+	}
+	if err := os.WriteFile(filename, dados, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrarPrecos(t *testing.T) {
+	silenciarLogs(t)
+	arquivos := arquivosNoDiretorio(t.TempDir())
+	// 14 registros de 52 bytes também fecham com os de 56: sem a checagem de formato, o
+	// arquivo seria lido sem erro, com os produtos embaralhados.
+	precosFloat32 := []float32{99.99, 0.1, 1234.5, 0.1, 7, 1, 2, 3, 4, 5, 6, 8, 9, 10}
+	gravarProdutosFloat32(t, arquivos.Produtos, precosFloat32)
+	original, err := os.ReadFile(arquivos.Produtos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := abrirBanco(arquivos); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("abrirBanco com o formato antigo = %v, quero ErrCorrupt", err)
+	}
+	for _, comando := range [][]string{
+		{"agrupar", "-por", "brand", "-produtos", arquivos.Produtos},
+		{"top", "-tabela", "produtos", "-campo", "brand", "-produtos", arquivos.Produtos},
+		{"funil", "-produtos", arquivos.Produtos, "-acessos", arquivos.Acessos},
+	} {
+		if err := executarComando(comando[0], comando[1:]); !errors.Is(err, ErrCorrupt) || !strings.Contains(err.Error(), "migrar-precos") {
+			t.Errorf("%s com o formato antigo = %v, quero o erro de abrirBanco", comando[0], err)
+		}
+	}
+
+	n, err := migrarPrecos(arquivos, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(precosFloat32) {
+		t.Errorf("migrarPrecos converteu %d produtos, quero %d", n, len(precosFloat32))
+	}
+	if backup, err := os.ReadFile(arquivoBackupFloat32(arquivos.Produtos)); err != nil || !bytes.Equal(backup, original) {
+		t.Errorf("backup difere do original (%v)", err)
+	}
+
+	var precos []Preco
+	for produto, err := range produtosDoArquivo(arquivos.Armazenamento, arquivos.Produtos) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		precos = append(precos, bytesToPreco(produto.Price))
+	}
+	if quero := []Preco{9999, 10, 123450, 10, 700, 100, 200, 300, 400, 500, 600, 800, 900, 1000}; !slices.Equal(precos, quero) {
+		t.Errorf("preços migrados = %v, quero %v", precos, quero)
+	}
+
+	b, err := abrirBanco(arquivos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id := int32(1); id <= int32(len(precosFloat32)); id++ {
+		offset, err := offsetNoIndice(arquivos.Armazenamento, arquivos.IndiceProdutos, id)
+		if err != nil || offset != int64(id-1)*tamanhoProduto {
+			t.Errorf("índice de IDs: produto %d no offset %d (%v), quero %d", id, offset, err, int64(id-1)*tamanhoProduto)
+		}
+	}
+	conferirIndicePrecos(t, b.arquivos)
+	baratos, err := consultarProdutosPorFaixaDePreco(arquivos.Armazenamento, arquivos.IndicePrecos, arquivos.Produtos, 10, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(baratos) != 2 || bytesToInt32(baratos[0].ID) != 2 || bytesToInt32(baratos[1].ID) != 4 {
+		t.Errorf("produtos de 0.10 = %v, quero os de ID 2 e 4", baratos)
+	}
+
+	// Rodar de novo não converte nada nem mexe nos arquivos.
+	antes := make(map[string][]byte)
+	nomes := []string{arquivos.Produtos, arquivos.IndiceProdutos, arquivos.IndicePrecos, arquivoBackupFloat32(arquivos.Produtos)}
+	for _, nome := range nomes {
+		if antes[nome], err = os.ReadFile(nome); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := migrarPrecos(arquivos, true); n != 0 || err != nil {
+		t.Fatalf("segunda migração = %d, %v; quero 0, nil", n, err)
+	}
+	for _, nome := range nomes {
+		if depois, err := os.ReadFile(nome); err != nil || !bytes.Equal(depois, antes[nome]) {
+			t.Errorf("segunda migração mudou %s (%v)", nome, err)
+		}
+	}
+
+	problemas, err := verificarArquivos(arquivos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problemas) > 0 {
+		t.Errorf("arquivos inconsistentes depois da migração: %v", problemas)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+func mapear(file *os.File, tamanho int) ([]byte, error) {
+	return syscall.Mmap(int(file.Fd()), 0, tamanho, syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func desmapear(dados []byte) error {
+	return syscall.Munmap(dados)
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// Sem mmap, o "mapeamento" é uma cópia do arquivo em memória. Como o Banco remapeia
+// depois de toda escrita, a cópia nunca fica desatualizada para os leitores.
+func mapear(file *os.File, tamanho int) ([]byte, error) {
+	dados := make([]byte, tamanho)
+	_, err := io.ReadFull(file, dados)
+	if err != nil {
+		return nil, err
+	}
+	return dados, nil
+}
+
+func desmapear(dados []byte) error {
+	return nil
+}
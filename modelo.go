@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ModeloProduto é o produto com tipos comuns de Go, para o código da aplicação. Produto
+// continua sendo o layout gravado no disco: registro codifica o modelo nele e
+// modeloDoProduto faz o caminho de volta.
+type ModeloProduto struct {
+	ID           int32  `json:"id"`
+	ProductID    int32  `json:"product_id"`
+	Price        Preco  `json:"price"`
+	Brand        string `json:"brand"`
+	CategoryCode string `json:"category_code"`
+}
+
+type ModeloAcesso struct {
+	ID          int32  `json:"id"`
+	UserSession string `json:"user_session"`
+	UserID      int32  `json:"user_id"`
+	EventType   string `json:"event_type"`
+}
+
+// novoModeloProduto cria um produto ainda sem ID, que é atribuído pela sequência na
+// inserção.
+func novoModeloProduto(productID int32, price Preco, brand string, categoryCode string) (ModeloProduto, error) {
+	m := ModeloProduto{ProductID: productID, Price: price, Brand: brand, CategoryCode: categoryCode}
+	return m, m.validar()
+}
+
+func novoModeloAcesso(userSession string, userID int32, eventType string) (ModeloAcesso, error) {
+	m := ModeloAcesso{UserSession: userSession, UserID: userID, EventType: eventType}
+	return m, m.validar()
+}
+
+// validarTexto confere se valor cabe no campo de tamanho bytes e volta igual da
+// leitura: sem zeros, que textoFixo trataria como preenchimento, e em UTF-8 válido.
+func validarTexto(campo string, valor string, tamanho int) error {
+	switch {
+	case len(valor) > tamanho:
+		return fmt.Errorf("%w: %s excede %d bytes", ErrInvalidField, campo, tamanho)
+	case !utf8.ValidString(valor):
+		return fmt.Errorf("%w: %s não é UTF-8 válido", ErrInvalidField, campo)
+	case strings.IndexByte(valor, 0) >= 0:
+		return fmt.Errorf("%w: %s contém o byte zero", ErrInvalidField, campo)
+	}
+	return nil
+}
+
+func (m ModeloProduto) validar() error {
+	if err := validarTexto("brand", m.Brand, len(Produto{}.Brand)); err != nil {
+		return err
+	}
+	return validarTexto("category_code", m.CategoryCode, len(Produto{}.CategoryCode))
+}
+
+func (m ModeloAcesso) validar() error {
+	if err := validarTexto("user_session", m.UserSession, len(Acesso{}.UserSession)); err != nil {
+		return err
+	}
+	return validarTexto("event_type", m.EventType, len(Acesso{}.EventType))
+}
+
+// registro codifica o produto no layout do disco. Diferente da importação do CSV, que
+// corta os textos longos, aqui um valor que não cabe é um erro.
+func (m ModeloProduto) registro() (Produto, error) {
+	if err := m.validar(); err != nil {
+		return Produto{}, err
+	}
+
+	return Produto{
+		ID:           int32ToBytes(m.ID),
+		ProductID:    int32ToBytes(m.ProductID),
+		Price:        precoToBytes(m.Price),
+		Brand:        bytesToArray20(padString(m.Brand, 20)),
+		CategoryCode: bytesToArray20(padString(m.CategoryCode, 20)),
+	}, nil
+}
+
+func (m ModeloAcesso) registro() (Acesso, error) {
+	if err := m.validar(); err != nil {
+		return Acesso{}, err
+	}
+
+	return Acesso{
+		ID:          int32ToBytes(m.ID),
+		UserSession: bytesToArray20(padString(m.UserSession, 20)),
+		UserID:      int32ToBytes(m.UserID),
+		EventType:   bytesToArray10(padString(m.EventType, 10)),
+	}, nil
+}
+
+func modeloDoProduto(produto Produto) ModeloProduto {
+	return ModeloProduto{
+		ID:           bytesToInt32(produto.ID),
+		ProductID:    bytesToInt32(produto.ProductID),
+		Price:        bytesToPreco(produto.Price),
+		Brand:        textoFixo(produto.Brand[:]),
+		CategoryCode: textoFixo(produto.CategoryCode[:]),
+	}
+}
+
+func modeloDoAcesso(acesso Acesso) ModeloAcesso {
+	return ModeloAcesso{
+		ID:          bytesToInt32(acesso.ID),
+		UserSession: textoFixo(acesso.UserSession[:]),
+		UserID:      bytesToInt32(acesso.UserID),
+		EventType:   textoFixo(acesso.EventType[:]),
+	}
+}
+
+func (m ModeloProduto) String() string {
+	return fmt.Sprintf("ID: %d, ProductID: %d, Preço: %s, Marca: %s, Categoria: %s", m.ID, m.ProductID, m.Price, m.Brand, m.CategoryCode)
+}
+
+func (m ModeloAcesso) String() string {
+	return fmt.Sprintf("ID: %d, Sessão: %s, UserID: %d, Evento: %s", m.ID, m.UserSession, m.UserID, m.EventType)
+}
+
+func (p Produto) String() string {
+	return modeloDoProduto(p).String()
+}
+
+func (a Acesso) String() string {
+	return modeloDoAcesso(a).String()
+}
+
+// MarshalJSON usa a mesma forma das respostas da API HTTP.
+func (p Produto) MarshalJSON() ([]byte, error) {
+	return json.Marshal(modeloDoProduto(p))
+}
+
+func (a Acesso) MarshalJSON() ([]byte, error) {
+	return json.Marshal(modeloDoAcesso(a))
+}
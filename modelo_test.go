@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestModeloRegistroValida(t *testing.T) {
+	casos := []struct {
+		nome   string
+		modelo interface{ validar() error }
+		campo  string
+	}{
+		{"brand com 21 bytes", ModeloProduto{Brand: strings.Repeat("m", 21)}, "brand"},
+		{"brand com 20 bytes em 19 caracteres", ModeloProduto{Brand: strings.Repeat("m", 18) + "ç"}, ""},
+		{"brand que só passa de 20 bytes pelo acento", ModeloProduto{Brand: strings.Repeat("m", 19) + "ç"}, "brand"},
+		{"category_code com UTF-8 inválido", ModeloProduto{CategoryCode: "cat\xff"}, "category_code"},
+		{"category_code com byte zero", ModeloProduto{CategoryCode: "ca\x00t"}, "category_code"},
+		{"user_session com 21 bytes", ModeloAcesso{UserSession: strings.Repeat("s", 21)}, "user_session"},
+		{"event_type com 11 bytes", ModeloAcesso{EventType: "remove_cart"}, "event_type"},
+		{"event_type com 10 bytes", ModeloAcesso{EventType: "remove_car"}, ""},
+		{"event_type com byte zero", ModeloAcesso{EventType: "view\x00"}, "event_type"},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nome, func(t *testing.T) {
+			var err error
+			switch m := caso.modelo.(type) {
+			case ModeloProduto:
+				var produto Produto
+				if produto, err = m.registro(); err == nil && modeloDoProduto(produto) != m {
+					t.Errorf("registro não volta igual: %+v, quero %+v", modeloDoProduto(produto), m)
+				}
+			case ModeloAcesso:
+				var acesso Acesso
+				if acesso, err = m.registro(); err == nil && modeloDoAcesso(acesso) != m {
+					t.Errorf("registro não volta igual: %+v, quero %+v", modeloDoAcesso(acesso), m)
+				}
+			}
+
+			if caso.campo == "" {
+				if err != nil {
+					t.Errorf("registro = %v, quero nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidField) || !strings.Contains(err.Error(), caso.campo) {
+				t.Errorf("registro = %v, quero ErrInvalidField em %s", err, caso.campo)
+			}
+		})
+	}
+}
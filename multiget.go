@@ -0,0 +1,154 @@
+package main
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// consultarVariosProdutos busca muitos IDs de uma vez. Os IDs são ordenados e o índice
+// é percorrido uma única vez a partir do menor deles; os registros achados são lidos
+// em ordem de offset. O resultado segue a ordem de ids, com nil onde o produto não
+// existe, e ausentes lista (ordenados e sem repetição) os IDs não encontrados.
+func consultarVariosProdutos(armazenamento Armazenamento, indexProd string, filenameProd string, ids []int32) ([]*Produto, []int32, error) {
+	offsets, ausentes, err := offsetsNoIndice(armazenamento, indexProd, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	registros, err := lerRegistrosPorOffset(armazenamento, filenameProd, offsets, tamanhoProduto)
+	if err != nil {
+		return nil, nil, fmt.Errorf("erro ao ler registro de produto: %w", err)
+	}
+
+	produtos := make([]*Produto, len(ids))
+	lidos := make(map[int32]*Produto, len(registros))
+	for i, id := range ids {
+		b, ok := registros[id]
+		if !ok {
+			continue
+		}
+		if lidos[id] == nil {
+			lidos[id] = &Produto{}
+			decodificarProduto(b, lidos[id])
+		}
+		produtos[i] = lidos[id]
+	}
+	return produtos, ausentes, nil
+}
+
+func consultarVariosAcessos(armazenamento Armazenamento, indexAccess string, filenameAccess string, ids []int32) ([]*Acesso, []int32, error) {
+	offsets, ausentes, err := offsetsNoIndice(armazenamento, indexAccess, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	registros, err := lerRegistrosPorOffset(armazenamento, filenameAccess, offsets, tamanhoAcesso)
+	if err != nil {
+		return nil, nil, fmt.Errorf("erro ao ler registro de acesso: %w", err)
+	}
+
+	acessos := make([]*Acesso, len(ids))
+	lidos := make(map[int32]*Acesso, len(registros))
+	for i, id := range ids {
+		b, ok := registros[id]
+		if !ok {
+			continue
+		}
+		if lidos[id] == nil {
+			lidos[id] = &Acesso{}
+			decodificarAcesso(b, lidos[id])
+		}
+		acessos[i] = lidos[id]
+	}
+	return acessos, ausentes, nil
+}
+
+// offsetsNoIndice faz o merge entre os IDs pedidos, já ordenados, e as entradas do
+// índice, lidas em sequência a partir da posição do menor ID.
+func offsetsNoIndice(armazenamento Armazenamento, indexName string, ids []int32) (map[int32]int64, []int32, error) {
+	pendentes := slices.Clone(ids)
+	slices.Sort(pendentes)
+	pendentes = slices.Compact(pendentes)
+
+	offsets := make(map[int32]int64, len(pendentes))
+	if len(pendentes) == 0 {
+		return offsets, nil, nil
+	}
+
+	indexFile, err := abrirArquivo(armazenamento, indexName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("erro ao abrir o arquivo de índice: %w", err)
+	}
+	defer indexFile.Close()
+
+	pos, total, err := posicaoInicialIndice(indexFile, tamanhoIndiceID, pendentes[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ausentes []int32
+	leitor := io.NewSectionReader(indexFile, pos*tamanhoIndiceID, (total-pos)*tamanhoIndiceID)
+	scanner := novoScannerRegistros(leitor, indexName, tamanhoIndiceID)
+	// Os offsets dos erros continuam relativos ao início do índice.
+	scanner.fim = pos * tamanhoIndiceID
+
+	for len(pendentes) > 0 && scanner.ler() {
+		id := int32(binary.LittleEndian.Uint32(scanner.registro[0:4]))
+
+		for len(pendentes) > 0 && pendentes[0] < id {
+			ausentes = append(ausentes, pendentes[0])
+			pendentes = pendentes[1:]
+		}
+		if len(pendentes) > 0 && pendentes[0] == id {
+			offsets[id] = int64(binary.LittleEndian.Uint64(scanner.registro[4:12]))
+			pendentes = pendentes[1:]
+		}
+	}
+	if scanner.erro() != nil {
+		return nil, nil, fmt.Errorf("erro ao ler registro de índice: %w", scanner.erro())
+	}
+
+	ausentes = append(ausentes, pendentes...)
+	return offsets, ausentes, nil
+}
+
+// lerRegistrosPorOffset lê os registros de filename nos offsets pedidos, avançando pelo
+// arquivo em ordem crescente de offset.
+func lerRegistrosPorOffset(armazenamento Armazenamento, filename string, offsets map[int32]int64, tamanho int) (map[int32][]byte, error) {
+	registros := make(map[int32][]byte, len(offsets))
+	if len(offsets) == 0 {
+		return registros, nil
+	}
+
+	file, err := abrirArquivo(armazenamento, filename)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir o arquivo %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	type alvo struct {
+		id     int32
+		offset int64
+	}
+	alvos := make([]alvo, 0, len(offsets))
+	for id, offset := range offsets {
+		alvos = append(alvos, alvo{id, offset})
+	}
+	slices.SortFunc(alvos, func(a, b alvo) int {
+		return cmp.Compare(a.offset, b.offset)
+	})
+
+	dados := make([]byte, len(alvos)*tamanho)
+	for i, a := range alvos {
+		b := dados[i*tamanho : (i+1)*tamanho]
+		_, err := file.ReadAt(b, a.offset)
+		if err != nil {
+			return nil, erroRegistro(filename, a.offset, err)
+		}
+		registros[a.id] = b
+	}
+	return registros, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestConsultarVariosComAusentesERepetidos(t *testing.T) {
+	var gravados []int32
+	for id := int32(2); id <= 40; id += 2 {
+		gravados = append(gravados, id)
+	}
+	arquivos := gravarBancoBusca(t, novoArmazenamentoMemoria(), gravados)
+
+	// Fora de ordem, com repetidos, com IDs entre os gravados e fora da faixa deles.
+	ids := []int32{40, 3, 2, 3, 99, 2, -1, 40, 20}
+	querAusentes := []int32{-1, 3, 99}
+
+	produtos, ausentes, err := consultarVariosProdutos(arquivos.Armazenamento, arquivos.IndiceProdutos, arquivos.Produtos, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ausentes, querAusentes) {
+		t.Errorf("produtos ausentes = %v, quero %v", ausentes, querAusentes)
+	}
+	if len(produtos) != len(ids) {
+		t.Fatalf("%d produtos para %d IDs", len(produtos), len(ids))
+	}
+	for i, id := range ids {
+		produto := produtos[i]
+		if slices.Contains(querAusentes, id) {
+			if produto != nil {
+				t.Errorf("posição %d (ID %d): produto %d, quero nil", i, id, bytesToInt32(produto.ID))
+			}
+			continue
+		}
+		if produto == nil || bytesToInt32(produto.ID) != id || bytesToInt32(produto.ProductID) != id*10 {
+			t.Errorf("posição %d (ID %d): %+v", i, id, produto)
+		}
+	}
+
+	acessos, ausentes, err := consultarVariosAcessos(arquivos.Armazenamento, arquivos.IndiceAcessos, arquivos.Acessos, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ausentes, querAusentes) {
+		t.Errorf("acessos ausentes = %v, quero %v", ausentes, querAusentes)
+	}
+	for i, id := range ids {
+		acesso := acessos[i]
+		if slices.Contains(querAusentes, id) != (acesso == nil) || acesso != nil && bytesToInt32(acesso.ID) != id {
+			t.Errorf("posição %d (ID %d): acesso %+v", i, id, acesso)
+		}
+	}
+
+	// Sem IDs, nada é lido.
+	produtos, ausentes, err = consultarVariosProdutos(arquivos.Armazenamento, arquivos.IndiceProdutos, arquivos.Produtos, nil)
+	if err != nil || len(produtos) != 0 || len(ausentes) != 0 {
+		t.Errorf("consultarVariosProdutos(nil) = %v, %v, %v", produtos, ausentes, err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Preco é um valor em centavos. Os preços são gravados como int64, e não como float32,
+// para que 99.99 continue 99.99 e somas sobre milhões de registros sejam exatas.
+type Preco int64
+
+const (
+	casasPreco  = 2
+	escalaPreco = 100
+)
+
+// lerPreco interpreta um decimal como "99.99", "-3.5" ou "12" sem passar por ponto
+// flutuante. Mais casas que casasPreco só são aceitas se forem zeros.
+func lerPreco(s string) (Preco, error) {
+	texto := strings.TrimSpace(s)
+	negativo := false
+	if texto != "" && (texto[0] == '-' || texto[0] == '+') {
+		negativo = texto[0] == '-'
+		texto = texto[1:]
+	}
+
+	inteiro, fracao, _ := strings.Cut(texto, ".")
+	if inteiro == "" && fracao == "" || !apenasDigitos(inteiro) || !apenasDigitos(fracao) {
+		return 0, fmt.Errorf("preço inválido: %q", s)
+	}
+	if len(fracao) > casasPreco {
+		if strings.Trim(fracao[casasPreco:], "0") != "" {
+			return 0, fmt.Errorf("preço %q tem mais de %d casas decimais", s, casasPreco)
+		}
+		fracao = fracao[:casasPreco]
+	}
+	fracao += strings.Repeat("0", casasPreco-len(fracao))
+
+	centavos, err := strconv.ParseInt(inteiro+fracao, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("preço fora do intervalo: %q", s)
+	}
+	if negativo {
+		centavos = -centavos
+	}
+	return Preco(centavos), nil
+}
+
+func apenasDigitos(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String formata o preço sempre com casasPreco casas, como "99.90".
+func (p Preco) String() string {
+	sinal := ""
+	centavos := int64(p)
+	if centavos < 0 {
+		sinal = "-"
+	}
+	inteiro, fracao := centavos/escalaPreco, centavos%escalaPreco
+	if inteiro < 0 {
+		inteiro = -inteiro
+	}
+	if fracao < 0 {
+		fracao = -fracao
+	}
+	return fmt.Sprintf("%s%d.%0*d", sinal, inteiro, casasPreco, fracao)
+}
+
+// float64 é só para cálculos aproximados, como médias.
+func (p Preco) float64() float64 {
+	return float64(p) / escalaPreco
+}
+
+// MarshalJSON escreve o preço como número JSON com as casas exatas, sem aspas.
+func (p Preco) MarshalJSON() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalJSON aceita o preço como número ou como texto, sem convertê-lo para float.
+func (p *Preco) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	preco, err := lerPreco(s)
+	if err != nil {
+		return err
+	}
+	*p = preco
+	return nil
+}
+
+func precoToBytes(p Preco) [8]byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(p))
+	return buf
+}
+
+func bytesToPreco(b [8]byte) Preco {
+	return Preco(binary.LittleEndian.Uint64(b[:]))
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// cenarioQueda é uma operação de escrita do banco cuja resistência a falhas e quedas é
+// conferida por simularQuedas.
+type cenarioQueda struct {
+	nome     string
+	operacao func(b *Banco) error
+}
+
+// cenariosQueda são as operações de escrita conferidas por TestQuedas e pelo comando
+// crashtest.
+func cenariosQueda() ([]cenarioQueda, error) {
+	modelo := ModeloProduto{ProductID: 777, Price: 1250, Brand: "marca queda", CategoryCode: "cat.queda"}
+	novoProduto, err := modelo.registro()
+	if err != nil {
+		return nil, err
+	}
+	modelo.ID = 4
+	alterado, err := modelo.registro()
+	if err != nil {
+		return nil, err
+	}
+	novoAcesso, err := ModeloAcesso{UserSession: "sessao queda", UserID: 777, EventType: "view"}.registro()
+	if err != nil {
+		return nil, err
+	}
+
+	return []cenarioQueda{
+		{"inserir-produto", func(b *Banco) error {
+			_, err := b.inserirProduto(novoProduto)
+			return err
+		}},
+		{"inserir-acesso", func(b *Banco) error {
+			_, err := b.inserirAcesso(novoAcesso)
+			return err
+		}},
+		{"inserir-lote", func(b *Banco) error {
+			_, err := b.inserirProdutos([]Produto{novoProduto, novoProduto, novoProduto})
+			return err
+		}},
+		{"atualizar-produto", func(b *Banco) error {
+			return b.atualizarProduto(alterado)
+		}},
+		{"remover-produto", func(b *Banco) error {
+			return b.removerProduto(3)
+		}},
+		{"remover-acesso", func(b *Banco) error {
+			return b.removerAcesso(3)
+		}},
+		{"remover-lote", func(b *Banco) error {
+			return b.removerProdutos([]int32{2, 5, 9})
+		}},
+		{"reindexar", func(b *Banco) error {
+			return b.reindexar()
+		}},
+	}, nil
+}
+
+// bancoInicialQueda cria em memória um banco com n produtos e n acessos, com todos os
+// índices e as sequências gravados.
+func bancoInicialQueda(arquivos Arquivos, n int) (*armazenamentoMemoria, error) {
+	m := novoArmazenamentoMemoria()
+	arquivos.Armazenamento = m
+
+	b, err := abrirBanco(arquivos)
+	if err != nil {
+		return nil, err
+	}
+	defer b.fechar()
+
+	produtos := make([]Produto, n)
+	acessos := make([]Acesso, n)
+	for i := range n {
+		produtos[i], err = ModeloProduto{
+			ProductID:    int32(i % 7),
+			Price:        Preco(i%13) * 325,
+			Brand:        fmt.Sprintf("marca%d", i%5),
+			CategoryCode: fmt.Sprintf("cat.%d", i%3),
+		}.registro()
+		if err != nil {
+			return nil, err
+		}
+		acessos[i], err = ModeloAcesso{
+			UserSession: fmt.Sprintf("sessao%d", i%11),
+			UserID:      int32(i % 17),
+			EventType:   "view",
+		}.registro()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if _, err := b.inserirProdutos(produtos); err != nil {
+		return nil, err
+	}
+	if _, err := b.inserirAcessos(acessos); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// estadoBanco resume o conteúdo dos arquivos de dados, para comparar o banco depois de
+// uma queda com o estado de antes e o de depois da operação.
+func estadoBanco(arquivos Arquivos) (string, error) {
+	var estado []byte
+	for produto, err := range produtosDoArquivo(arquivos.Armazenamento, arquivos.Produtos) {
+		if err != nil {
+			return "", err
+		}
+		estado = fmt.Appendf(estado, "p%v;", *produto)
+	}
+	for acesso, err := range acessosDoArquivo(arquivos.Armazenamento, arquivos.Acessos) {
+		if err != nil {
+			return "", err
+		}
+		estado = fmt.Appendf(estado, "a%v;", *acesso)
+	}
+	return string(estado), nil
+}
+
+// execucaoQueda roda a operação do cenário sobre uma cópia de inicial com as falhas
+// armadas e devolve o armazenamento com falhas, já com a operação feita (ou abortada).
+func execucaoQueda(cenario cenarioQueda, arquivos Arquivos, inicial *armazenamentoMemoria, limite int64, escritaParcial bool, renomeacoes int) (*armazenamentoFalhas, error) {
+	f := novoArmazenamentoFalhas(inicial.copiar())
+	arquivos.Armazenamento = f
+
+	b, err := abrirBanco(arquivos)
+	if err != nil {
+		return nil, err
+	}
+	defer b.fechar()
+
+	f.armar(limite, escritaParcial, renomeacoes)
+	err = cenario.operacao(b)
+	f.desarmar()
+	return f, err
+}
+
+// conferirReabertura reabre o banco guardado em m, como faria o processo ao subir de
+// novo, e confere que os arquivos estão íntegros e no estado antes ou depois.
+func conferirReabertura(m *armazenamentoMemoria, arquivos Arquivos, antes string, depois string) error {
+	arquivos.Armazenamento = m
+
+	b, err := abrirBanco(arquivos)
+	if err != nil {
+		return fmt.Errorf("banco não abre: %w", err)
+	}
+	b.fechar()
+
+	problemas, err := verificarArquivos(arquivos)
+	if err != nil {
+		return err
+	}
+	if len(problemas) > 0 {
+		return fmt.Errorf("%d problema(s), o primeiro: %s", len(problemas), problemas[0])
+	}
+
+	estado, err := estadoBanco(arquivos)
+	if err != nil {
+		return err
+	}
+	if estado != antes && estado != depois {
+		return errors.New("os dados não estão nem no estado de antes nem no de depois da operação")
+	}
+	return nil
+}
+
+// resultadoQueda resume a simulação de um cenário.
+type resultadoQueda struct {
+	cenario   string
+	bytes     int64
+	execucoes int
+	falhas    []string
+}
+
+// simularQuedas roda o cenário sem falhas, para saber quantos bytes ele escreve e
+// quantas renomeações faz, e depois uma vez para cada ponto de falha: escrita recusada
+// ou interrompida a cada passo bytes, e cada uma das renomeações falhando. Depois de
+// cada execução, o banco é reaberto duas vezes: com o que o processo deixou nos arquivos
+// e com o que sobraria no disco se o sistema caísse naquele momento.
+func simularQuedas(cenario cenarioQueda, arquivos Arquivos, inicial *armazenamentoMemoria, passo int64) (resultadoQueda, error) {
+	r := resultadoQueda{cenario: cenario.nome}
+
+	antes, err := estadoEm(inicial, arquivos)
+	if err != nil {
+		return r, err
+	}
+
+	f, err := execucaoQueda(cenario, arquivos, inicial, -1, false, -1)
+	if err != nil {
+		return r, fmt.Errorf("cenário %s falhou sem falhas injetadas: %w", cenario.nome, err)
+	}
+	depois, err := estadoEm(f.base, arquivos)
+	if err != nil {
+		return r, err
+	}
+	escritos, renomeacoes := f.contadores()
+	r.bytes = escritos
+
+	conferir := func(descricao string, f *armazenamentoFalhas) {
+		r.execucoes++
+		if err := conferirReabertura(f.base.copiar(), arquivos, antes, depois); err != nil {
+			r.falhas = append(r.falhas, fmt.Sprintf("%s, sem queda: %v", descricao, err))
+		}
+		if err := conferirReabertura(f.queda(), arquivos, antes, depois); err != nil {
+			r.falhas = append(r.falhas, fmt.Sprintf("%s, após queda: %v", descricao, err))
+		}
+	}
+	conferir("sem falhas", f)
+
+	for limite := int64(0); limite < escritos; limite += passo {
+		for _, parcial := range []bool{false, true} {
+			f, _ := execucaoQueda(cenario, arquivos, inicial, limite, parcial, -1)
+			descricao := fmt.Sprintf("escritas falhando após %d bytes", limite)
+			if parcial {
+				descricao += " (com escrita parcial)"
+			}
+			conferir(descricao, f)
+		}
+	}
+
+	for i := range renomeacoes {
+		f, _ := execucaoQueda(cenario, arquivos, inicial, -1, false, i)
+		conferir(fmt.Sprintf("renomeação %d falhando", i+1), f)
+	}
+	return r, nil
+}
+
+func estadoEm(m *armazenamentoMemoria, arquivos Arquivos) (string, error) {
+	arquivos.Armazenamento = m
+	return estadoBanco(arquivos)
+}
+
+// cenariosPorNome filtra os cenários pelos nomes pedidos; sem nomes, devolve todos.
+func cenariosPorNome(nomes []string) ([]cenarioQueda, error) {
+	todos, err := cenariosQueda()
+	if err != nil {
+		return nil, err
+	}
+	if len(nomes) == 0 {
+		return todos, nil
+	}
+
+	var escolhidos []cenarioQueda
+	for _, nome := range nomes {
+		i := slices.IndexFunc(todos, func(c cenarioQueda) bool { return c.nome == nome })
+		if i < 0 {
+			return nil, fmt.Errorf("cenário desconhecido: %s", nome)
+		}
+		escolhidos = append(escolhidos, todos[i])
+	}
+	return escolhidos, nil
+}
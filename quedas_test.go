@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+// silenciarLogs descarta os logs de reparo dos índices, que cada reabertura depois de
+// uma queda produz aos montes.
+func silenciarLogs(t *testing.T) {
+	saida := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(saida) })
+}
+
+// passoQuedas é o intervalo entre os pontos de falha: cada byte, ou um a cada 7 com -short.
+func passoQuedas() int64 {
+	if testing.Short() {
+		return 7
+	}
+	return 1
+}
+
+func TestQuedas(t *testing.T) {
+	silenciarLogs(t)
+	cenarios, err := cenariosQueda()
+	if err != nil {
+		t.Fatal(err)
+	}
+	arquivos := arquivosPadrao()
+	inicial, err := bancoInicialQueda(arquivos, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cenario := range cenarios {
+		t.Run(cenario.nome, func(t *testing.T) {
+			t.Parallel()
+			r, err := simularQuedas(cenario, arquivos, inicial, passoQuedas())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.bytes == 0 {
+				t.Fatal("o cenário não escreveu nada")
+			}
+			for _, f := range r.falhas {
+				t.Error(f)
+			}
+		})
+	}
+}
+
+// Uma operação que sobrescreve dois produtos em escritas separadas não é atômica: uma
+// queda entre as duas deixa o banco num estado que não é nem o de antes nem o de depois,
+// e a simulação tem de apontar isso.
+func TestQuedasDetectaEscritaNaoAtomica(t *testing.T) {
+	silenciarLogs(t)
+	arquivos := arquivosPadrao()
+	inicial, err := bancoInicialQueda(arquivos, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cenario := cenarioQueda{"escrita-nao-atomica", func(b *Banco) error {
+		file, err := b.arquivos.Armazenamento.Abrir(b.arquivos.Produtos, os.O_RDWR, 0)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		for _, offset := range []int64{0, tamanhoProduto} {
+			var registro [tamanhoProduto]byte
+			produto := produtoTeste(t, 999, 1, "outra")
+			produto.ID = int32ToBytes(int32(offset/tamanhoProduto) + 1)
+			codificarProduto(registro[:], &produto)
+			if _, err := file.WriteAt(registro[:], offset); err != nil {
+				return err
+			}
+		}
+		return file.Sync()
+	}}
+
+	r, err := simularQuedas(cenario, arquivos, inicial, tamanhoProduto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.falhas) == 0 {
+		t.Error("nenhuma falha apontada para uma operação que não é atômica")
+	}
+}
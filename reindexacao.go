@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// indiceDoBanco descreve um índice e o arquivo de dados de onde ele é gerado. chave é
+// a posição, dentro do registro, dos tamanhoChave bytes usados como chave do índice;
+// cada entrada tem a chave seguida do offset de 8 bytes. delta é o arquivo com as
+// escritas ainda não incorporadas ao índice, se ele tiver um.
+type indiceDoBanco struct {
+	nome         string
+	dados        string
+	tamanho      int
+	chave        int
+	tamanhoChave int
+	criar        func() error
+	delta        string
+}
+
+func (indice indiceDoBanco) tamanhoEntrada() int {
+	return indice.tamanhoChave + 8
+}
+
+func indicesDoBanco(arquivos Arquivos) []indiceDoBanco {
+	return []indiceDoBanco{
+		{arquivos.IndiceProdutos, arquivos.Produtos, tamanhoProduto, 0, 4, func() error {
+			return criarIndiceProdutos(arquivos.Armazenamento, arquivos.Produtos, arquivos.IndiceProdutos)
+		}, ""},
+		{arquivos.IndiceAcessos, arquivos.Acessos, tamanhoAcesso, 0, 4, func() error {
+			return criarIndiceAcessos(arquivos.Armazenamento, arquivos.Acessos, arquivos.IndiceAcessos)
+		}, ""},
+		indicePrecos(arquivos.Armazenamento, arquivos.Produtos, arquivos.IndicePrecos),
+	}
+}
+
+func indicePrecos(armazenamento Armazenamento, filenameProd string, indexPreco string) indiceDoBanco {
+	return indiceDoBanco{indexPreco, filenameProd, tamanhoProduto, 8, 8, func() error {
+		return criarIndicePrecos(armazenamento, filenameProd, indexPreco)
+	}, arquivoDelta(indexPreco)}
+}
+
+// repararIndices recria os índices ausentes, desatualizados ou inválidos. A checagem é
+// barata (datas, tamanhos e as entradas das pontas); verificarArquivos faz a completa.
+func repararIndices(arquivos Arquivos) error {
+	for _, indice := range indicesDoBanco(arquivos) {
+		if err := repararIndice(arquivos.Armazenamento, indice); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func repararIndice(armazenamento Armazenamento, indice indiceDoBanco) error {
+	motivo, err := problemaIndice(armazenamento, indice)
+	if err != nil {
+		return err
+	}
+	if motivo == "" {
+		return nil
+	}
+
+	log.Printf("índice %s %s; recriando", indice.nome, motivo)
+	return indice.criar()
+}
+
+// reindexar recria todos os índices, cada um gravado num arquivo temporário e renomeado
+// no lugar do antigo.
+func reindexar(arquivos Arquivos) error {
+	for _, indice := range indicesDoBanco(arquivos) {
+		if err := indice.criar(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// problemaIndice devolve o motivo para recriar o índice, ou "" se ele parece válido. Um
+// arquivo de dados com registro incompleto não se conserta com um índice novo e é
+// devolvido como erro.
+func problemaIndice(armazenamento Armazenamento, indice indiceDoBanco) (string, error) {
+	infoIndice, err := armazenamento.Info(indice.nome)
+	if errors.Is(err, os.ErrNotExist) {
+		return "não existe", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("erro ao verificar o índice %s: %w", indice.nome, err)
+	}
+
+	infoDados, err := armazenamento.Info(indice.dados)
+	if err != nil {
+		return "", fmt.Errorf("erro ao verificar o arquivo %s: %w", indice.dados, err)
+	}
+	if infoDados.Size()%int64(indice.tamanho) != 0 {
+		return "", fmt.Errorf("%w: %s tem %d bytes, que não é múltiplo do registro de %d bytes", ErrCorrupt, indice.dados, infoDados.Size(), indice.tamanho)
+	}
+
+	// Com delta, as entradas do índice são as da base, menos as que ele remove, mais as
+	// que ele adiciona, e o índice está em dia até a data do delta.
+	modificado := infoIndice.ModTime()
+	delta := &deltaPrecos{}
+	if indice.delta != "" {
+		delta, err = lerDeltaPrecos(armazenamento, indice.nome)
+		if errors.Is(err, ErrCorrupt) {
+			return "tem o delta corrompido", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		infoDelta, err := armazenamento.Info(indice.delta)
+		if err == nil && infoDelta.ModTime().After(modificado) {
+			modificado = infoDelta.ModTime()
+		}
+	}
+
+	if modificado.Before(infoDados.ModTime()) {
+		return "está desatualizado", nil
+	}
+
+	registros := infoDados.Size() / int64(indice.tamanho)
+	tamanhoEntrada := int64(indice.tamanhoEntrada())
+	entradasBase := infoIndice.Size() / tamanhoEntrada
+	if infoIndice.Size()%tamanhoEntrada != 0 {
+		return fmt.Sprintf("tem %d bytes para %d registros", infoIndice.Size(), registros), nil
+	}
+	if entradas := entradasBase + int64(len(delta.adicoes)-len(delta.remocoes)); entradas != registros {
+		return fmt.Sprintf("tem %d entradas para %d registros", entradas, registros), nil
+	}
+	if registros == 0 {
+		return "", nil
+	}
+
+	var entradas [][]byte
+	for _, pos := range []int64{0, entradasBase - 1} {
+		if pos < 0 || pos >= entradasBase {
+			continue
+		}
+		entrada, err := lerTrecho(armazenamento, indice.nome, pos*tamanhoEntrada, int(tamanhoEntrada))
+		if err != nil {
+			return "", err
+		}
+		if indice.delta != "" && delta.remocoes[entradaPrecoDe(entrada)] {
+			continue
+		}
+		entradas = append(entradas, entrada)
+	}
+	if n := len(delta.adicoes); n > 0 {
+		for _, e := range []entradaPreco{delta.adicoes[0], delta.adicoes[n-1]} {
+			entrada := make([]byte, tamanhoIndicePreco)
+			e.codificar(entrada)
+			entradas = append(entradas, entrada)
+		}
+	}
+
+	for _, entrada := range entradas {
+		ok, err := conferirEntradaIndice(armazenamento, indice, entrada)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return fmt.Sprintf("tem a entrada do offset %d apontando para o registro errado", binary.LittleEndian.Uint64(entrada[indice.tamanhoChave:])), nil
+		}
+	}
+	return "", nil
+}
+
+// conferirEntradaIndice confere se a entrada aponta para um registro com a mesma chave.
+func conferirEntradaIndice(armazenamento Armazenamento, indice indiceDoBanco, entrada []byte) (bool, error) {
+	offset := int64(binary.LittleEndian.Uint64(entrada[indice.tamanhoChave:indice.tamanhoEntrada()]))
+	if offset < 0 || offset%int64(indice.tamanho) != 0 {
+		return false, nil
+	}
+
+	chave, err := lerTrecho(armazenamento, indice.dados, offset+int64(indice.chave), indice.tamanhoChave)
+	if errors.Is(err, ErrCorrupt) {
+		// O offset passa do fim do arquivo de dados.
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(entrada[:indice.tamanhoChave], chave), nil
+}
+
+func lerTrecho(armazenamento Armazenamento, nome string, offset int64, n int) ([]byte, error) {
+	file, err := abrirArquivo(armazenamento, nome)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir o arquivo %s: %w", nome, err)
+	}
+	defer file.Close()
+
+	b := make([]byte, n)
+	_, err = file.ReadAt(b, offset)
+	if err != nil {
+		return nil, erroRegistro(nome, offset, err)
+	}
+	return b, nil
+}
+
+// tocarIndice avança a data de modificação do índice depois de uma escrita que muda o
+// arquivo de dados sem invalidar o índice, como a atualização de um registro no lugar.
+func tocarIndice(armazenamento Armazenamento, indexName string) error {
+	agora := time.Now()
+	err := armazenamento.AlterarData(indexName, agora)
+	if err != nil {
+		return fmt.Errorf("erro ao atualizar a data do índice %s: %w", indexName, err)
+	}
+	return nil
+}
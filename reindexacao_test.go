@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+func TestReindexarGeraIndicesIdenticos(t *testing.T) {
+	silenciarLogs(t)
+	m := novoArmazenamentoMemoria()
+	arquivos := arquivosPadrao()
+	arquivos.Armazenamento = m
+	b, err := abrirBanco(arquivos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Índices mantidos só pelas escritas incrementais, com operações no delta de preços.
+	var produtos []Produto
+	var acessos []Acesso
+	for i := range 12 {
+		produtos = append(produtos, produtoTeste(t, int32(i), Preco(i%4)*100, "m"))
+		acessos = append(acessos, acessoTeste(t, "s", int32(i), "view"))
+	}
+	produtos, err = b.inserirProdutos(produtos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.inserirAcessos(acessos); err != nil {
+		t.Fatal(err)
+	}
+	produtos[3].Price = precoToBytes(50)
+	if err := b.atualizarProduto(produtos[3]); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.removerProdutos([]int32{2, 7}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.removerAcesso(5); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.inserirProduto(produtoTeste(t, 99, 200, "m")); err != nil {
+		t.Fatal(err)
+	}
+
+	ler := func(nome string) []byte {
+		t.Helper()
+		dados, err := lerArquivoInteiro(m, nome)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dados
+	}
+	antes := map[string][]byte{
+		arquivos.IndiceProdutos: ler(arquivos.IndiceProdutos),
+		arquivos.IndiceAcessos:  ler(arquivos.IndiceAcessos),
+		arquivos.IndicePrecos:   entradasIndicePrecos(t, m, arquivos.IndicePrecos),
+	}
+
+	for rodada := range 2 {
+		if err := b.reindexar(); err != nil {
+			t.Fatal(err)
+		}
+		for nome, quero := range antes {
+			if dados := ler(nome); !bytes.Equal(dados, quero) {
+				t.Errorf("rodada %d: %s recriado difere do mantido pelas escritas: %d bytes, quero %d", rodada, nome, len(dados), len(quero))
+			}
+		}
+		if _, err := m.Info(arquivoDelta(arquivos.IndicePrecos)); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("rodada %d: delta continua depois de reindexar (Info = %v)", rodada, err)
+		}
+	}
+
+	// Um índice apagado é recriado igual ao abrir o banco.
+	if err := m.Remover(arquivos.IndiceProdutos); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := abrirBanco(arquivos); err != nil {
+		t.Fatal(err)
+	}
+	if dados := ler(arquivos.IndiceProdutos); !bytes.Equal(dados, antes[arquivos.IndiceProdutos]) {
+		t.Errorf("%s recriado por abrirBanco difere do original", arquivos.IndiceProdutos)
+	}
+}
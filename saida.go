@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+func escreverTabela(w io.Writer, cabecalho []string, linhas [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, strings.Join(cabecalho, "\t"))
+	separadores := make([]string, len(cabecalho))
+	for i, coluna := range cabecalho {
+		separadores[i] = strings.Repeat("-", len([]rune(coluna)))
+	}
+	fmt.Fprintln(tw, strings.Join(separadores, "\t"))
+
+	for _, linha := range linhas {
+		fmt.Fprintln(tw, strings.Join(linha, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+func escreverJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func escreverSaida(w io.Writer, formato string, cabecalho []string, linhas [][]string, v any) error {
+	switch formato {
+	case "tabela":
+		return escreverTabela(w, cabecalho, linhas)
+	case "json":
+		return escreverJSON(w, v)
+	default:
+		return fmt.Errorf("formato de saída desconhecido: %s", formato)
+	}
+}
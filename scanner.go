@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"iter"
+)
+
+// Tamanhos em disco dos registros e das entradas dos índices.
+const (
+	tamanhoProduto       = 56
+	tamanhoAcesso        = 38
+	tamanhoIndiceID      = 12
+	tamanhoIndicePreco   = 16
+	tamanhoBufferLeitura = 256 << 10
+)
+
+// scannerRegistros lê registros de tamanho fixo em sequência, com um buffer grande e
+// sem alocar por registro. O uso segue bufio.Scanner: chame ler até ele devolver false
+// e depois confira erro.
+type scannerRegistros struct {
+	arquivo  string
+	file     Arquivo
+	reader   *bufio.Reader
+	registro []byte
+	inicio   int64
+	fim      int64
+	err      error
+}
+
+func novoScannerRegistros(r io.Reader, arquivo string, tamanho int) scannerRegistros {
+	return scannerRegistros{
+		arquivo:  arquivo,
+		reader:   bufio.NewReaderSize(r, tamanhoBufferLeitura),
+		registro: make([]byte, tamanho),
+	}
+}
+
+func (s *scannerRegistros) ler() bool {
+	if s.err != nil {
+		return false
+	}
+
+	_, err := io.ReadFull(s.reader, s.registro)
+	if err != nil {
+		// io.EOF só aparece quando nenhum byte foi lido: fim normal do arquivo.
+		if err != io.EOF {
+			s.err = erroRegistro(s.arquivo, s.fim, err)
+		}
+		return false
+	}
+
+	s.inicio = s.fim
+	s.fim += int64(len(s.registro))
+	return true
+}
+
+// offset devolve a posição no arquivo do último registro lido.
+func (s *scannerRegistros) offset() int64 {
+	return s.inicio
+}
+
+func (s *scannerRegistros) erro() error {
+	return s.err
+}
+
+// fechar fecha o arquivo quando o scanner foi criado por abrirScanner*.
+func (s *scannerRegistros) fechar() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+type scannerProdutos struct {
+	scannerRegistros
+	atual Produto
+}
+
+func novoScannerProdutos(r io.Reader, arquivo string) *scannerProdutos {
+	return &scannerProdutos{scannerRegistros: novoScannerRegistros(r, arquivo, tamanhoProduto)}
+}
+
+func abrirScannerProdutos(armazenamento Armazenamento, filename string) (*scannerProdutos, error) {
+	file, err := abrirArquivo(armazenamento, filename)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir o arquivo binário de produtos: %w", err)
+	}
+
+	s := novoScannerProdutos(file, filename)
+	s.file = file
+	return s, nil
+}
+
+func (s *scannerProdutos) ler() bool {
+	if !s.scannerRegistros.ler() {
+		return false
+	}
+	decodificarProduto(s.registro, &s.atual)
+	return true
+}
+
+// produto devolve o último produto lido. O mesmo *Produto é reaproveitado a cada
+// leitura; copie o valor para guardá-lo.
+func (s *scannerProdutos) produto() *Produto {
+	return &s.atual
+}
+
+// todos itera sobre os produtos restantes, com o offset de cada um. Confira erro depois
+// do laço.
+func (s *scannerProdutos) todos() iter.Seq2[int64, *Produto] {
+	return func(yield func(int64, *Produto) bool) {
+		for s.ler() {
+			if !yield(s.offset(), &s.atual) {
+				return
+			}
+		}
+	}
+}
+
+type scannerAcessos struct {
+	scannerRegistros
+	atual Acesso
+}
+
+func novoScannerAcessos(r io.Reader, arquivo string) *scannerAcessos {
+	return &scannerAcessos{scannerRegistros: novoScannerRegistros(r, arquivo, tamanhoAcesso)}
+}
+
+func abrirScannerAcessos(armazenamento Armazenamento, filename string) (*scannerAcessos, error) {
+	file, err := abrirArquivo(armazenamento, filename)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir o arquivo binário de acessos: %w", err)
+	}
+
+	s := novoScannerAcessos(file, filename)
+	s.file = file
+	return s, nil
+}
+
+func (s *scannerAcessos) ler() bool {
+	if !s.scannerRegistros.ler() {
+		return false
+	}
+	decodificarAcesso(s.registro, &s.atual)
+	return true
+}
+
+func (s *scannerAcessos) acesso() *Acesso {
+	return &s.atual
+}
+
+func (s *scannerAcessos) todos() iter.Seq2[int64, *Acesso] {
+	return func(yield func(int64, *Acesso) bool) {
+		for s.ler() {
+			if !yield(s.offset(), &s.atual) {
+				return
+			}
+		}
+	}
+}
+
+// produtosDoArquivo itera sobre todos os produtos de filename. Um erro de abertura ou
+// de leitura é entregue uma única vez, com produto nil, e encerra a iteração.
+func produtosDoArquivo(armazenamento Armazenamento, filename string) iter.Seq2[*Produto, error] {
+	return func(yield func(*Produto, error) bool) {
+		s, err := abrirScannerProdutos(armazenamento, filename)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer s.fechar()
+
+		for s.ler() {
+			if !yield(&s.atual, nil) {
+				return
+			}
+		}
+		if s.erro() != nil {
+			yield(nil, fmt.Errorf("erro ao ler registro de produto: %w", s.erro()))
+		}
+	}
+}
+
+func acessosDoArquivo(armazenamento Armazenamento, filename string) iter.Seq2[*Acesso, error] {
+	return func(yield func(*Acesso, error) bool) {
+		s, err := abrirScannerAcessos(armazenamento, filename)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer s.fechar()
+
+		for s.ler() {
+			if !yield(&s.atual, nil) {
+				return
+			}
+		}
+		if s.erro() != nil {
+			yield(nil, fmt.Errorf("erro ao ler registro de acesso: %w", s.erro()))
+		}
+	}
+}
+
+func decodificarProduto(b []byte, produto *Produto) {
+	copy(produto.ID[:], b[0:4])
+	copy(produto.ProductID[:], b[4:8])
+	copy(produto.Price[:], b[8:16])
+	copy(produto.Brand[:], b[16:36])
+	copy(produto.CategoryCode[:], b[36:56])
+}
+
+func decodificarAcesso(b []byte, acesso *Acesso) {
+	copy(acesso.ID[:], b[0:4])
+	copy(acesso.UserSession[:], b[4:24])
+	copy(acesso.UserID[:], b[24:28])
+	copy(acesso.EventType[:], b[28:38])
+}
+
+func codificarProduto(b []byte, produto *Produto) {
+	copy(b[0:4], produto.ID[:])
+	copy(b[4:8], produto.ProductID[:])
+	copy(b[8:16], produto.Price[:])
+	copy(b[16:36], produto.Brand[:])
+	copy(b[36:56], produto.CategoryCode[:])
+}
+
+func codificarAcesso(b []byte, acesso *Acesso) {
+	copy(b[0:4], acesso.ID[:])
+	copy(b[4:24], acesso.UserSession[:])
+	copy(b[24:28], acesso.UserID[:])
+	copy(b[28:38], acesso.EventType[:])
+}
+
+// codificarEntradaIndice monta em b uma entrada de IndexProduto ou IndexAcesso, que têm
+// o mesmo formato.
+func codificarEntradaIndice(b []byte, id [4]byte, offset int64) {
+	copy(b[0:4], id[:])
+	binary.LittleEndian.PutUint64(b[4:12], uint64(offset))
+}
+
+func codificarEntradaIndicePreco(b []byte, preco [8]byte, offset int64) {
+	copy(b[0:8], preco[:])
+	binary.LittleEndian.PutUint64(b[8:16], uint64(offset))
+}
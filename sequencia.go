@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// O próximo ID livre de cada arquivo de dados fica em <arquivo>.seq, como um int32
+// little-endian. A sequência é gravada antes do registro: uma falha entre as duas
+// escritas deixa um buraco nos IDs, mas nunca um ID repetido.
+func arquivoSequencia(filename string) string {
+	return filename + ".seq"
+}
+
+// lerSequencia devolve o próximo ID livre de filename. Sem arquivo de sequência, o valor
+// parte do maior ID do arquivo de dados, calculado por maiorID.
+func lerSequencia(armazenamento Armazenamento, filename string, maiorID func(Armazenamento, string) (int32, error)) (int32, error) {
+	dados, err := lerArquivoInteiro(armazenamento, arquivoSequencia(filename))
+	if errors.Is(err, os.ErrNotExist) {
+		id, err := maiorID(armazenamento, filename)
+		if err != nil {
+			return 0, err
+		}
+		return id + 1, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("erro ao ler a sequência de IDs: %w", err)
+	}
+
+	if len(dados) != 4 {
+		return 0, fmt.Errorf("%w: sequência de IDs %s com %d bytes", ErrCorrupt, arquivoSequencia(filename), len(dados))
+	}
+	return int32(binary.LittleEndian.Uint32(dados)), nil
+}
+
+func gravarSequencia(armazenamento Armazenamento, filename string, proximo int32) error {
+	var dados [4]byte
+	binary.LittleEndian.PutUint32(dados[:], uint32(proximo))
+
+	err := gravarAtomicamente(armazenamento, arquivoSequencia(filename), func(w io.Writer) error {
+		_, err := w.Write(dados[:])
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao gravar a sequência de IDs: %w", err)
+	}
+	return nil
+}
+
+// reservarID devolve o próximo ID livre de filename e avança a sequência.
+func reservarID(armazenamento Armazenamento, filename string, maiorID func(Armazenamento, string) (int32, error)) (int32, error) {
+	return reservarIDs(armazenamento, filename, 1, maiorID)
+}
+
+// reservarIDs reserva n IDs consecutivos e devolve o primeiro deles.
+func reservarIDs(armazenamento Armazenamento, filename string, n int, maiorID func(Armazenamento, string) (int32, error)) (int32, error) {
+	id, err := lerSequencia(armazenamento, filename, maiorID)
+	if err != nil {
+		return 0, err
+	}
+	if int64(id)+int64(n) > math.MaxInt32 {
+		return 0, fmt.Errorf("sequência de IDs de %s esgotada", filename)
+	}
+	return id, gravarSequencia(armazenamento, filename, id+int32(n))
+}
+
+// reservarIDExplicito reserva um ID escolhido por quem chama. IDs abaixo da sequência
+// já foram entregues e são recusados com ErrDuplicateID.
+func reservarIDExplicito(armazenamento Armazenamento, filename string, id int32, maiorID func(Armazenamento, string) (int32, error)) error {
+	proximo, err := lerSequencia(armazenamento, filename, maiorID)
+	if err != nil {
+		return err
+	}
+	if id < proximo {
+		return fmt.Errorf("%w: o ID %d já foi entregue pela sequência; novos IDs devem ser >= %d", ErrDuplicateID, id, proximo)
+	}
+	return gravarSequencia(armazenamento, filename, id+1)
+}
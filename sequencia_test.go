@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestSequenciaSobreviveAReabertura(t *testing.T) {
+	arquivos := arquivosNoDiretorio(t.TempDir())
+	b, err := abrirBanco(arquivos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	produtos := make([]Produto, 5)
+	acessos := make([]Acesso, 5)
+	for i := range produtos {
+		produtos[i] = produtoTeste(t, int32(i), 100, "m")
+		acessos[i] = acessoTeste(t, "sess", int32(i), "view")
+	}
+	if _, err := b.inserirProdutos(produtos); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.inserirAcessos(acessos); err != nil {
+		t.Fatal(err)
+	}
+
+	// Os maiores IDs são removidos: o maior ID no arquivo volta a 3, mas 4 e 5 já foram
+	// entregues e não podem ser usados de novo.
+	if err := b.removerProdutos([]int32{4, 5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.removerAcesso(5); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = abrirBanco(arquivos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	produto, err := b.inserirProduto(produtoTeste(t, 9, 100, "m"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := bytesToInt32(produto.ID); id != 6 {
+		t.Errorf("produto inserido depois de reabrir tem ID %d, quero 6", id)
+	}
+	acesso, err := b.inserirAcesso(acessoTeste(t, "sess", 9, "view"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := bytesToInt32(acesso.ID); id != 6 {
+		t.Errorf("acesso inserido depois de reabrir tem ID %d, quero 6", id)
+	}
+	if err := b.inserirProdutoComID(produtoComID(t, 4)); !errors.Is(err, ErrDuplicateID) {
+		t.Errorf("inserir com o ID 4, já entregue, = %v, quero ErrDuplicateID", err)
+	}
+
+	proximo, err := lerSequencia(arquivos.Armazenamento, arquivos.Produtos, maiorIDProdutos)
+	if err != nil || proximo != 7 {
+		t.Errorf("sequência de produtos = %d, %v; quero 7", proximo, err)
+	}
+
+	// Sem o .seq, a sequência recomeça do maior ID do arquivo.
+	if err := os.Remove(arquivoSequencia(arquivos.Produtos)); err != nil {
+		t.Fatal(err)
+	}
+	proximo, err = lerSequencia(arquivos.Armazenamento, arquivos.Produtos, maiorIDProdutos)
+	if err != nil || proximo != 7 {
+		t.Errorf("sequência de produtos sem o .seq = %d, %v; quero 7", proximo, err)
+	}
+}
+
+func produtoComID(t *testing.T, id int32) Produto {
+	t.Helper()
+	produto := produtoTeste(t, 1, 100, "m")
+	produto.ID = int32ToBytes(id)
+	return produto
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	limitePadraoListagem = 100
+	limiteMaximoListagem = 1000
+)
+
+type servidorHTTP struct {
+	banco *Banco
+}
+
+func novoServidorHTTP(banco *Banco) http.Handler {
+	s := &servidorHTTP{banco: banco}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /produtos", s.listarProdutos)
+	mux.HandleFunc("POST /produtos", s.inserirProduto)
+	mux.HandleFunc("GET /produtos/{id}", s.consultarProduto)
+	mux.HandleFunc("PUT /produtos/{id}", s.atualizarProduto)
+	mux.HandleFunc("DELETE /produtos/{id}", s.removerProduto)
+
+	mux.HandleFunc("GET /acessos", s.listarAcessos)
+	mux.HandleFunc("POST /acessos", s.inserirAcesso)
+	mux.HandleFunc("GET /acessos/{id}", s.consultarAcesso)
+	mux.HandleFunc("PUT /acessos/{id}", s.atualizarAcesso)
+	mux.HandleFunc("DELETE /acessos/{id}", s.removerAcesso)
+	return mux
+}
+
+type erroHTTP struct {
+	status int
+	err    error
+}
+
+func (e *erroHTTP) Error() string { return e.err.Error() }
+func (e *erroHTTP) Unwrap() error { return e.err }
+
+func requisicaoInvalida(format string, args ...any) error {
+	return &erroHTTP{status: http.StatusBadRequest, err: fmt.Errorf(format, args...)}
+}
+
+func responderJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("erro ao escrever resposta: %v", err)
+	}
+}
+
+func responderErro(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var e *erroHTTP
+	if errors.As(err, &e) {
+		status = e.status
+	} else if errors.Is(err, ErrNotFound) {
+		status = http.StatusNotFound
+	} else if errors.Is(err, ErrDuplicateID) {
+		status = http.StatusConflict
+	}
+
+	if status == http.StatusInternalServerError {
+		log.Printf("erro interno: %v", err)
+	}
+	responderJSON(w, status, map[string]string{"erro": err.Error()})
+}
+
+func idDaRota(r *http.Request) (int32, error) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 32)
+	if err != nil {
+		return 0, requisicaoInvalida("id inválido: %q", r.PathValue("id"))
+	}
+	return int32(id), nil
+}
+
+func lerCorpoJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return requisicaoInvalida("corpo JSON inválido: %v", err)
+	}
+	return nil
+}
+
+// parametrosListagem lê de, ate e limite da query string, usados por todas as listagens.
+func parametrosListagem(r *http.Request) (int32, int32, int, error) {
+	query := r.URL.Query()
+	de, ate, limite := int32(math.MinInt32), int32(math.MaxInt32), limitePadraoListagem
+
+	if v := query.Get("de"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return 0, 0, 0, requisicaoInvalida("parâmetro de inválido: %q", v)
+		}
+		de = int32(n)
+	}
+	if v := query.Get("ate"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return 0, 0, 0, requisicaoInvalida("parâmetro ate inválido: %q", v)
+		}
+		ate = int32(n)
+	}
+	if v := query.Get("limite"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, 0, requisicaoInvalida("parâmetro limite inválido: %q", v)
+		}
+		limite = min(n, limiteMaximoListagem)
+	}
+
+	return de, ate, limite, nil
+}
+
+func parametroPreco(r *http.Request, nome string, padrao Preco) (Preco, error) {
+	v := r.URL.Query().Get(nome)
+	if v == "" {
+		return padrao, nil
+	}
+	preco, err := lerPreco(v)
+	if err != nil {
+		return 0, requisicaoInvalida("parâmetro %s inválido: %v", nome, err)
+	}
+	return preco, nil
+}
+
+func (s *servidorHTTP) consultarProduto(w http.ResponseWriter, r *http.Request) {
+	id, err := idDaRota(r)
+	if err != nil {
+		responderErro(w, err)
+		return
+	}
+
+	produto, err := s.banco.consultarProduto(id)
+	if err != nil {
+		responderErro(w, err)
+		return
+	}
+	responderJSON(w, http.StatusOK, modeloDoProduto(produto))
+}
+
+func (s *servidorHTTP) listarProdutos(w http.ResponseWriter, r *http.Request) {
+	de, ate, limite, err := parametrosListagem(r)
+	if err != nil {
+		responderErro(w, err)
+		return
+	}
+	precoMinimo, err := parametroPreco(r, "preco_min", math.MinInt64)
+	if err != nil {
+		responderErro(w, err)
+		return
+	}
+	precoMaximo, err := parametroPreco(r, "preco_max", math.MaxInt64)
+	if err != nil {
+		responderErro(w, err)
+		return
+	}
+	brand := r.URL.Query().Get("brand")
+	categoryCode := r.URL.Query().Get("category_code")
+
+	produtos, err := s.banco.listarProdutos(de, ate, limite, func(produto *Produto) bool {
+		preco := bytesToPreco(produto.Price)
+		if preco < precoMinimo || preco > precoMaximo {
+			return false
+		}
+		if brand != "" && textoFixo(produto.Brand[:]) != brand {
+			return false
+		}
+		if categoryCode != "" && textoFixo(produto.CategoryCode[:]) != categoryCode {
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		responderErro(w, err)
+		return
+	}
+
+	resposta := make([]ModeloProduto, len(produtos))
+	for i, produto := range produtos {
+		resposta[i] = modeloDoProduto(produto)
+	}
+	responderJSON(w, http.StatusOK, resposta)
+}
+
+func (s *servidorHTTP) inserirProduto(w http.ResponseWriter, r *http.Request) {
+	var corpo ModeloProduto
+	if err := lerCorpoJSON(w, r, &corpo); err != nil {
+		responderErro(w, err)
+		return
+	}
+
+	produto, err := corpo.registro()
+	if err != nil {
+		responderErro(w, requisicaoInvalida("%v", err))
+		return
+	}
+
+	// Com id no corpo, o produto é gravado com esse ID, e um ID já usado dá 409.
+	if corpo.ID != 0 {
+		err = s.banco.inserirProdutoComID(produto)
+	} else {
+		produto, err = s.banco.inserirProduto(produto)
+	}
+	if err != nil {
+		responderErro(w, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/produtos/%d", bytesToInt32(produto.ID)))
+	responderJSON(w, http.StatusCreated, modeloDoProduto(produto))
+}
+
+func (s *servidorHTTP) atualizarProduto(w http.ResponseWriter, r *http.Request) {
+	id, err := idDaRota(r)
+	if err != nil {
+		responderErro(w, err)
+		return
+	}
+
+	var corpo ModeloProduto
+	if err := lerCorpoJSON(w, r, &corpo); err != nil {
+		responderErro(w, err)
+		return
+	}
+	if corpo.ID != 0 && corpo.ID != id {
+		responderErro(w, requisicaoInvalida("id do corpo (%d) difere do id da rota (%d)", corpo.ID, id))
+		return
+	}
+	corpo.ID = id
+
+	produto, err := corpo.registro()
+	if err != nil {
+		responderErro(w, requisicaoInvalida("%v", err))
+		return
+	}
+
+	if err := s.banco.atualizarProduto(produto); err != nil {
+		responderErro(w, err)
+		return
+	}
+	responderJSON(w, http.StatusOK, modeloDoProduto(produto))
+}
+
+func (s *servidorHTTP) removerProduto(w http.ResponseWriter, r *http.Request) {
+	id, err := idDaRota(r)
+	if err != nil {
+		responderErro(w, err)
+		return
+	}
+
+	if err := s.banco.removerProduto(id); err != nil {
+		responderErro(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *servidorHTTP) consultarAcesso(w http.ResponseWriter, r *http.Request) {
+	id, err := idDaRota(r)
+	if err != nil {
+		responderErro(w, err)
+		return
+	}
+
+	acesso, err := s.banco.consultarAcesso(id)
+	if err != nil {
+		responderErro(w, err)
+		return
+	}
+	responderJSON(w, http.StatusOK, modeloDoAcesso(acesso))
+}
+
+func (s *servidorHTTP) listarAcessos(w http.ResponseWriter, r *http.Request) {
+	de, ate, limite, err := parametrosListagem(r)
+	if err != nil {
+		responderErro(w, err)
+		return
+	}
+	query := r.URL.Query()
+	userSession := query.Get("user_session")
+	eventType := query.Get("event_type")
+	userID := int64(-1)
+	if v := query.Get("user_id"); v != "" {
+		userID, err = strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			responderErro(w, requisicaoInvalida("parâmetro user_id inválido: %q", v))
+			return
+		}
+	}
+
+	acessos, err := s.banco.listarAcessos(de, ate, limite, func(acesso *Acesso) bool {
+		if userSession != "" && textoFixo(acesso.UserSession[:]) != userSession {
+			return false
+		}
+		if eventType != "" && textoFixo(acesso.EventType[:]) != eventType {
+			return false
+		}
+		if userID >= 0 && int64(bytesToInt32(acesso.UserID)) != userID {
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		responderErro(w, err)
+		return
+	}
+
+	resposta := make([]ModeloAcesso, len(acessos))
+	for i, acesso := range acessos {
+		resposta[i] = modeloDoAcesso(acesso)
+	}
+	responderJSON(w, http.StatusOK, resposta)
+}
+
+func (s *servidorHTTP) inserirAcesso(w http.ResponseWriter, r *http.Request) {
+	var corpo ModeloAcesso
+	if err := lerCorpoJSON(w, r, &corpo); err != nil {
+		responderErro(w, err)
+		return
+	}
+
+	acesso, err := corpo.registro()
+	if err != nil {
+		responderErro(w, requisicaoInvalida("%v", err))
+		return
+	}
+
+	if corpo.ID != 0 {
+		err = s.banco.inserirAcessoComID(acesso)
+	} else {
+		acesso, err = s.banco.inserirAcesso(acesso)
+	}
+	if err != nil {
+		responderErro(w, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/acessos/%d", bytesToInt32(acesso.ID)))
+	responderJSON(w, http.StatusCreated, modeloDoAcesso(acesso))
+}
+
+func (s *servidorHTTP) atualizarAcesso(w http.ResponseWriter, r *http.Request) {
+	id, err := idDaRota(r)
+	if err != nil {
+		responderErro(w, err)
+		return
+	}
+
+	var corpo ModeloAcesso
+	if err := lerCorpoJSON(w, r, &corpo); err != nil {
+		responderErro(w, err)
+		return
+	}
+	if corpo.ID != 0 && corpo.ID != id {
+		responderErro(w, requisicaoInvalida("id do corpo (%d) difere do id da rota (%d)", corpo.ID, id))
+		return
+	}
+	corpo.ID = id
+
+	acesso, err := corpo.registro()
+	if err != nil {
+		responderErro(w, requisicaoInvalida("%v", err))
+		return
+	}
+
+	if err := s.banco.atualizarAcesso(acesso); err != nil {
+		responderErro(w, err)
+		return
+	}
+	responderJSON(w, http.StatusOK, modeloDoAcesso(acesso))
+}
+
+func (s *servidorHTTP) removerAcesso(w http.ResponseWriter, r *http.Request) {
+	id, err := idDaRota(r)
+	if err != nil {
+		responderErro(w, err)
+		return
+	}
+
+	if err := s.banco.removerAcesso(id); err != nil {
+		responderErro(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// servirHTTP atende requisições até receber SIGINT ou SIGTERM e então espera as
+// requisições em andamento terminarem antes de retornar.
+func servirHTTP(endereco string, banco *Banco) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{
+		Addr:              endereco,
+		Handler:           novoServidorHTTP(banco),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	erros := make(chan error, 1)
+	go func() {
+		log.Printf("servidor HTTP ouvindo em %s", endereco)
+		erros <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-erros:
+		return fmt.Errorf("erro no servidor HTTP: %w", err)
+	case <-ctx.Done():
+	}
+
+	log.Printf("encerrando servidor HTTP")
+	ctxShutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctxShutdown); err != nil {
+		return fmt.Errorf("erro ao encerrar o servidor HTTP: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// requisicaoHTTP faz a requisição direto no handler e devolve a resposta gravada.
+func requisicaoHTTP(t *testing.T, handler http.Handler, metodo string, caminho string, corpo string) *httptest.ResponseRecorder {
+	t.Helper()
+	var r *http.Request
+	if corpo == "" {
+		r = httptest.NewRequest(metodo, caminho, nil)
+	} else {
+		r = httptest.NewRequest(metodo, caminho, strings.NewReader(corpo))
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+// conferirStatus confere o status da resposta e decodifica o corpo JSON em v, se v não
+// for nil.
+func conferirStatus(t *testing.T, w *httptest.ResponseRecorder, status int, v any) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("status %d, quero %d; corpo: %s", w.Code, status, w.Body.String())
+	}
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("corpo %q não é JSON: %v", w.Body.String(), err)
+		}
+	}
+}
+
+func TestServidorHTTPProdutos(t *testing.T) {
+	handler := novoServidorHTTP(abrirBancoTeste(t))
+
+	var criado ModeloProduto
+	w := requisicaoHTTP(t, handler, "POST", "/produtos", `{"product_id":70,"price":12.50,"brand":"marca","category_code":"cat"}`)
+	conferirStatus(t, w, http.StatusCreated, &criado)
+	quero := ModeloProduto{ID: 1, ProductID: 70, Price: 1250, Brand: "marca", CategoryCode: "cat"}
+	if criado != quero {
+		t.Fatalf("POST criou %+v, quero %+v", criado, quero)
+	}
+	if local := w.Header().Get("Location"); local != "/produtos/1" {
+		t.Errorf("Location = %q, quero /produtos/1", local)
+	}
+
+	var lido ModeloProduto
+	conferirStatus(t, requisicaoHTTP(t, handler, "GET", "/produtos/1", ""), http.StatusOK, &lido)
+	if lido != quero {
+		t.Errorf("GET = %+v, quero %+v", lido, quero)
+	}
+
+	quero.Price = 999
+	quero.Brand = "outra"
+	conferirStatus(t, requisicaoHTTP(t, handler, "PUT", "/produtos/1", `{"product_id":70,"price":9.99,"brand":"outra","category_code":"cat"}`), http.StatusOK, nil)
+	conferirStatus(t, requisicaoHTTP(t, handler, "GET", "/produtos/1", ""), http.StatusOK, &lido)
+	if lido != quero {
+		t.Errorf("GET depois do PUT = %+v, quero %+v", lido, quero)
+	}
+
+	conferirStatus(t, requisicaoHTTP(t, handler, "DELETE", "/produtos/1", ""), http.StatusNoContent, nil)
+	conferirStatus(t, requisicaoHTTP(t, handler, "GET", "/produtos/1", ""), http.StatusNotFound, nil)
+	conferirStatus(t, requisicaoHTTP(t, handler, "DELETE", "/produtos/1", ""), http.StatusNotFound, nil)
+	conferirStatus(t, requisicaoHTTP(t, handler, "PUT", "/produtos/1", `{"product_id":70,"price":1,"brand":"","category_code":""}`), http.StatusNotFound, nil)
+
+	// Um id novo no corpo é respeitado; o removido já foi entregue e não volta.
+	w = requisicaoHTTP(t, handler, "POST", "/produtos", `{"id":50,"product_id":71,"price":1}`)
+	conferirStatus(t, w, http.StatusCreated, &criado)
+	if criado.ID != 50 || w.Header().Get("Location") != "/produtos/50" {
+		t.Errorf("POST com id 50 criou %+v em %q", criado, w.Header().Get("Location"))
+	}
+	conferirStatus(t, requisicaoHTTP(t, handler, "GET", "/produtos/50", ""), http.StatusOK, nil)
+	conferirStatus(t, requisicaoHTTP(t, handler, "POST", "/produtos", `{"id":1,"product_id":72,"price":1}`), http.StatusConflict, nil)
+}
+
+func TestServidorHTTPAcessos(t *testing.T) {
+	handler := novoServidorHTTP(abrirBancoTeste(t))
+
+	var criado ModeloAcesso
+	w := requisicaoHTTP(t, handler, "POST", "/acessos", `{"user_session":"sess1","user_id":7,"event_type":"view"}`)
+	conferirStatus(t, w, http.StatusCreated, &criado)
+	quero := ModeloAcesso{ID: 1, UserSession: "sess1", UserID: 7, EventType: "view"}
+	if criado != quero {
+		t.Fatalf("POST criou %+v, quero %+v", criado, quero)
+	}
+	if local := w.Header().Get("Location"); local != "/acessos/1" {
+		t.Errorf("Location = %q, quero /acessos/1", local)
+	}
+
+	quero.EventType = "cart"
+	conferirStatus(t, requisicaoHTTP(t, handler, "PUT", "/acessos/1", `{"id":1,"user_session":"sess1","user_id":7,"event_type":"cart"}`), http.StatusOK, nil)
+	var lido ModeloAcesso
+	conferirStatus(t, requisicaoHTTP(t, handler, "GET", "/acessos/1", ""), http.StatusOK, &lido)
+	if lido != quero {
+		t.Errorf("GET depois do PUT = %+v, quero %+v", lido, quero)
+	}
+
+	conferirStatus(t, requisicaoHTTP(t, handler, "DELETE", "/acessos/1", ""), http.StatusNoContent, nil)
+	conferirStatus(t, requisicaoHTTP(t, handler, "GET", "/acessos/1", ""), http.StatusNotFound, nil)
+	conferirStatus(t, requisicaoHTTP(t, handler, "DELETE", "/acessos/1", ""), http.StatusNotFound, nil)
+}
+
+func TestServidorHTTPErros(t *testing.T) {
+	handler := novoServidorHTTP(abrirBancoTeste(t))
+	conferirStatus(t, requisicaoHTTP(t, handler, "POST", "/produtos", `{"product_id":1,"price":1}`), http.StatusCreated, nil)
+	conferirStatus(t, requisicaoHTTP(t, handler, "POST", "/acessos", `{"user_id":1}`), http.StatusCreated, nil)
+
+	casos := []struct {
+		nome    string
+		metodo  string
+		caminho string
+		corpo   string
+		status  int
+	}{
+		{"produto inexistente", "GET", "/produtos/99", "", http.StatusNotFound},
+		{"acesso inexistente", "GET", "/acessos/99", "", http.StatusNotFound},
+		{"produto com ID repetido", "POST", "/produtos", `{"id":1,"product_id":2,"price":1}`, http.StatusConflict},
+		{"acesso com ID repetido", "POST", "/acessos", `{"id":1,"user_id":2}`, http.StatusConflict},
+		{"id de produto não numérico", "GET", "/produtos/abc", "", http.StatusBadRequest},
+		{"id de acesso não numérico", "DELETE", "/acessos/abc", "", http.StatusBadRequest},
+		{"id fora de int32", "PUT", "/produtos/9999999999", `{"price":1}`, http.StatusBadRequest},
+		{"JSON malformado", "POST", "/produtos", `{"price":`, http.StatusBadRequest},
+		{"campo desconhecido", "POST", "/acessos", `{"user_id":1,"nome":"x"}`, http.StatusBadRequest},
+		{"preço com três casas", "POST", "/produtos", `{"price":1.005}`, http.StatusBadRequest},
+		{"brand grande demais", "POST", "/produtos", `{"brand":"` + strings.Repeat("x", 21) + `"}`, http.StatusBadRequest},
+		{"id do corpo diferente da rota", "PUT", "/acessos/1", `{"id":2,"user_id":1}`, http.StatusBadRequest},
+		{"limite negativo", "GET", "/produtos?limite=-1", "", http.StatusBadRequest},
+		{"de não numérico", "GET", "/acessos?de=x", "", http.StatusBadRequest},
+		{"preco_min inválido", "GET", "/produtos?preco_min=abc", "", http.StatusBadRequest},
+		{"user_id inválido", "GET", "/acessos?user_id=abc", "", http.StatusBadRequest},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			var corpo map[string]string
+			conferirStatus(t, requisicaoHTTP(t, handler, c.metodo, c.caminho, c.corpo), c.status, &corpo)
+			if corpo["erro"] == "" {
+				t.Errorf("resposta sem mensagem de erro: %v", corpo)
+			}
+		})
+	}
+}
+
+func TestServidorHTTPListagens(t *testing.T) {
+	b := abrirBancoTeste(t)
+	for i := range 10 {
+		brand := "par"
+		if i%2 == 1 {
+			brand = "impar"
+		}
+		if _, err := b.inserirProduto(produtoTeste(t, int32(i), Preco(i)*100, brand)); err != nil {
+			t.Fatal(err)
+		}
+		evento := "view"
+		if i%3 == 0 {
+			evento = "cart"
+		}
+		if _, err := b.inserirAcesso(acessoTeste(t, "sess", int32(i%4), evento)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handler := novoServidorHTTP(b)
+
+	idsProdutos := func(caminho string) []int32 {
+		t.Helper()
+		var produtos []ModeloProduto
+		conferirStatus(t, requisicaoHTTP(t, handler, "GET", caminho, ""), http.StatusOK, &produtos)
+		ids := make([]int32, 0, len(produtos))
+		for _, p := range produtos {
+			ids = append(ids, p.ID)
+		}
+		return ids
+	}
+	idsAcessos := func(caminho string) []int32 {
+		t.Helper()
+		var acessos []ModeloAcesso
+		conferirStatus(t, requisicaoHTTP(t, handler, "GET", caminho, ""), http.StatusOK, &acessos)
+		ids := make([]int32, 0, len(acessos))
+		for _, a := range acessos {
+			ids = append(ids, a.ID)
+		}
+		return ids
+	}
+
+	casos := []struct {
+		caminho string
+		ids     func(string) []int32
+		quero   []int32
+	}{
+		{"/produtos", idsProdutos, []int32{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+		{"/produtos?limite=3", idsProdutos, []int32{1, 2, 3}},
+		{"/produtos?limite=0", idsProdutos, []int32{}},
+		{"/produtos?de=4&ate=6", idsProdutos, []int32{4, 5, 6}},
+		{"/produtos?brand=impar", idsProdutos, []int32{2, 4, 6, 8, 10}},
+		{"/produtos?brand=impar&limite=2", idsProdutos, []int32{2, 4}},
+		{"/produtos?preco_min=3&preco_max=5.00", idsProdutos, []int32{4, 5, 6}},
+		{"/produtos?category_code=outra", idsProdutos, []int32{}},
+		{"/acessos?event_type=cart", idsAcessos, []int32{1, 4, 7, 10}},
+		{"/acessos?user_id=1", idsAcessos, []int32{2, 6, 10}},
+		{"/acessos?user_session=sess&de=9", idsAcessos, []int32{9, 10}},
+		{"/acessos?user_id=1&limite=1", idsAcessos, []int32{2}},
+	}
+	for _, c := range casos {
+		if ids := c.ids(c.caminho); !slices.Equal(ids, c.quero) {
+			t.Errorf("GET %s = %v, quero %v", c.caminho, ids, c.quero)
+		}
+	}
+}
@@ -0,0 +1,566 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"os"
+	"os/signal"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Tamanho máximo aceito para um argumento ou para a quantidade de argumentos de um
+// comando, e o maior COUNT atendido pelo SCAN; valores acima dele são reduzidos a ele.
+const (
+	tamanhoMaximoArgumentoRESP = 1 << 20
+	argumentosMaximosRESP      = 1 << 16
+	countMaximoScanRESP        = 1000
+)
+
+type erroRESP string
+
+func (e erroRESP) Error() string { return string(e) }
+
+type servidorRESP struct {
+	banco *Banco
+
+	mu       sync.Mutex
+	conexoes map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+func novoServidorRESP(banco *Banco) *servidorRESP {
+	return &servidorRESP{banco: banco, conexoes: make(map[net.Conn]struct{})}
+}
+
+// servir aceita conexões até o listener ser fechado.
+func (s *servidorRESP) servir(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("erro ao aceitar conexão: %w", err)
+		}
+
+		s.mu.Lock()
+		s.conexoes[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.atender(conn)
+
+			s.mu.Lock()
+			delete(s.conexoes, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// encerrar fecha as conexões abertas e espera os comandos em andamento terminarem.
+func (s *servidorRESP) encerrar() {
+	s.mu.Lock()
+	for conn := range s.conexoes {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *servidorRESP) atender(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+
+	for {
+		args, err := lerComandoRESP(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				var e erroRESP
+				if errors.As(err, &e) {
+					escreverErroRESP(writer, e.Error())
+					writer.Flush()
+				}
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		fechar := s.executar(writer, args)
+		if err := writer.Flush(); err != nil || fechar {
+			return
+		}
+	}
+}
+
+// lerComandoRESP lê um comando no formato de array de bulk strings ou, como o
+// redis-cli aceita, um comando inline separado por espaços.
+func lerComandoRESP(reader *bufio.Reader) ([]string, error) {
+	linha, err := lerLinhaRESP(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(linha, "*") {
+		return strings.Fields(linha), nil
+	}
+
+	n, err := strconv.Atoi(linha[1:])
+	if err != nil || n > argumentosMaximosRESP {
+		return nil, erroRESP("ERR Protocol error: invalid multibulk length")
+	}
+
+	args := make([]string, 0, max(n, 0))
+	for i := 0; i < n; i++ {
+		linha, err := lerLinhaRESP(reader)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(linha, "$") {
+			return nil, erroRESP(fmt.Sprintf("ERR Protocol error: expected '$', got '%.1s'", linha))
+		}
+
+		tamanho, err := strconv.Atoi(linha[1:])
+		if err != nil || tamanho < 0 || tamanho > tamanhoMaximoArgumentoRESP {
+			return nil, erroRESP("ERR Protocol error: invalid bulk length")
+		}
+
+		buf := make([]byte, tamanho+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:tamanho]))
+	}
+
+	return args, nil
+}
+
+func lerLinhaRESP(reader *bufio.Reader) (string, error) {
+	linha, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(linha, "\r\n"), nil
+}
+
+func escreverErroRESP(w *bufio.Writer, msg string) {
+	w.WriteString("-" + strings.ReplaceAll(msg, "\r\n", " ") + "\r\n")
+}
+
+func escreverSimplesRESP(w *bufio.Writer, s string) {
+	w.WriteString("+" + s + "\r\n")
+}
+
+func escreverInteiroRESP(w *bufio.Writer, n int64) {
+	w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func escreverBulkRESP(w *bufio.Writer, b []byte) {
+	if b == nil {
+		w.WriteString("$-1\r\n")
+		return
+	}
+	w.WriteString("$" + strconv.Itoa(len(b)) + "\r\n")
+	w.Write(b)
+	w.WriteString("\r\n")
+}
+
+func escreverArrayRESP(w *bufio.Writer, n int) {
+	w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
+
+// chaveRESP separa chaves como "produto:42" em tabela e ID.
+func chaveRESP(chave string) (string, int32, error) {
+	tabela, id, ok := strings.Cut(chave, ":")
+	if !ok || (tabela != "produto" && tabela != "acesso") {
+		return "", 0, fmt.Errorf("chave inválida %q: use produto:<id> ou acesso:<id>", chave)
+	}
+
+	n, err := strconv.ParseInt(id, 10, 32)
+	if err != nil {
+		return "", 0, fmt.Errorf("chave inválida %q: id não numérico", chave)
+	}
+	return tabela, int32(n), nil
+}
+
+// valorRESP devolve o registro da chave em JSON, ou nil se ele não existir.
+func (s *servidorRESP) valorRESP(chave string) ([]byte, error) {
+	tabela, id, err := chaveRESP(chave)
+	if err != nil {
+		return nil, err
+	}
+
+	var registro any
+	if tabela == "produto" {
+		produto, err := s.banco.consultarProduto(id)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		registro = modeloDoProduto(produto)
+	} else {
+		acesso, err := s.banco.consultarAcesso(id)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		registro = modeloDoAcesso(acesso)
+	}
+
+	return json.Marshal(registro)
+}
+
+// valoresRESP resolve as chaves do MGET com uma consulta em lote por tabela. Como no
+// Redis, chaves inválidas aparecem como nil.
+func (s *servidorRESP) valoresRESP(chaves []string) ([][]byte, error) {
+	var idsProdutos, idsAcessos []int32
+	for _, chave := range chaves {
+		tabela, id, err := chaveRESP(chave)
+		if err != nil {
+			continue
+		}
+		if tabela == "produto" {
+			idsProdutos = append(idsProdutos, id)
+		} else {
+			idsAcessos = append(idsAcessos, id)
+		}
+	}
+
+	produtos, _, err := s.banco.consultarProdutos(idsProdutos)
+	if err != nil {
+		return nil, err
+	}
+	acessos, _, err := s.banco.consultarAcessos(idsAcessos)
+	if err != nil {
+		return nil, err
+	}
+
+	valores := make([][]byte, len(chaves))
+	for i, chave := range chaves {
+		tabela, _, err := chaveRESP(chave)
+		if err != nil {
+			continue
+		}
+
+		var registro any
+		if tabela == "produto" {
+			produto := produtos[0]
+			produtos = produtos[1:]
+			if produto == nil {
+				continue
+			}
+			registro = modeloDoProduto(*produto)
+		} else {
+			acesso := acessos[0]
+			acessos = acessos[1:]
+			if acesso == nil {
+				continue
+			}
+			registro = modeloDoAcesso(*acesso)
+		}
+
+		valores[i], err = json.Marshal(registro)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return valores, nil
+}
+
+func (s *servidorRESP) definirValorRESP(chave string, valor string) error {
+	tabela, id, err := chaveRESP(chave)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(valor))
+	decoder.DisallowUnknownFields()
+
+	if tabela == "produto" {
+		var corpo ModeloProduto
+		if err := decoder.Decode(&corpo); err != nil {
+			return fmt.Errorf("valor JSON inválido: %v", err)
+		}
+		corpo.ID = id
+		produto, err := corpo.registro()
+		if err != nil {
+			return err
+		}
+		return s.banco.salvarProduto(produto)
+	}
+
+	var corpo ModeloAcesso
+	if err := decoder.Decode(&corpo); err != nil {
+		return fmt.Errorf("valor JSON inválido: %v", err)
+	}
+	corpo.ID = id
+	acesso, err := corpo.registro()
+	if err != nil {
+		return err
+	}
+	return s.banco.salvarAcesso(acesso)
+}
+
+// removerChavesRESP remove as chaves com uma reescrita por tabela e devolve quantas
+// existiam. Como no Redis, chaves ausentes são ignoradas.
+func (s *servidorRESP) removerChavesRESP(chaves []string) (int64, error) {
+	var idsProdutos, idsAcessos []int32
+	for _, chave := range chaves {
+		tabela, id, err := chaveRESP(chave)
+		if err != nil {
+			return 0, err
+		}
+		if tabela == "produto" {
+			idsProdutos = append(idsProdutos, id)
+		} else {
+			idsAcessos = append(idsAcessos, id)
+		}
+	}
+
+	produtos, err := s.banco.removerProdutosExistentes(idsProdutos)
+	if err != nil {
+		return 0, err
+	}
+	acessos, err := s.banco.removerAcessosExistentes(idsAcessos)
+	if err != nil {
+		return 0, err
+	}
+	return int64(produtos + acessos), nil
+}
+
+// O cursor do SCAN guarda a tabela nos 32 bits altos (0 produtos, 1 acessos) e o
+// próximo ID a visitar nos 32 bits baixos. O cursor 0 começa pelos produtos; os IDs
+// são sempre positivos.
+func (s *servidorRESP) scan(cursor uint64, padrao string, count int) (uint64, []string, error) {
+	tabela := cursor >> 32
+	id := int32(uint32(cursor))
+
+	var chaves []string
+	var ultimo int32
+	var lidos int
+	var err error
+	switch tabela {
+	case 0:
+		var produtos []Produto
+		produtos, err = s.banco.listarProdutos(id, math.MaxInt32, count, nil)
+		for _, produto := range produtos {
+			ultimo = bytesToInt32(produto.ID)
+			chaves = append(chaves, fmt.Sprintf("produto:%d", ultimo))
+		}
+		lidos = len(produtos)
+	case 1:
+		var acessos []Acesso
+		acessos, err = s.banco.listarAcessos(id, math.MaxInt32, count, nil)
+		for _, acesso := range acessos {
+			ultimo = bytesToInt32(acesso.ID)
+			chaves = append(chaves, fmt.Sprintf("acesso:%d", ultimo))
+		}
+		lidos = len(acessos)
+	default:
+		return 0, nil, fmt.Errorf("cursor inválido")
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	proximo := uint64(0)
+	if lidos == count && ultimo < math.MaxInt32 {
+		proximo = tabela<<32 | uint64(uint32(ultimo+1))
+	} else if tabela == 0 {
+		proximo = 1 << 32
+	}
+
+	if padrao != "*" {
+		filtradas := chaves[:0]
+		for _, chave := range chaves {
+			if ok, _ := path.Match(padrao, chave); ok {
+				filtradas = append(filtradas, chave)
+			}
+		}
+		chaves = filtradas
+	}
+
+	return proximo, chaves, nil
+}
+
+// executar roda um comando e escreve a resposta; retorna true se a conexão deve ser fechada.
+func (s *servidorRESP) executar(w *bufio.Writer, args []string) bool {
+	comando := strings.ToUpper(args[0])
+	args = args[1:]
+
+	aridade := func(minimo int, variavel bool) bool {
+		if len(args) < minimo || (!variavel && len(args) != minimo) {
+			escreverErroRESP(w, fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(comando)))
+			return false
+		}
+		return true
+	}
+	falhar := func(err error) {
+		escreverErroRESP(w, "ERR "+err.Error())
+	}
+
+	switch comando {
+	case "PING":
+		if len(args) > 0 {
+			escreverBulkRESP(w, []byte(args[0]))
+		} else {
+			escreverSimplesRESP(w, "PONG")
+		}
+	case "ECHO":
+		if aridade(1, false) {
+			escreverBulkRESP(w, []byte(args[0]))
+		}
+	case "QUIT":
+		escreverSimplesRESP(w, "OK")
+		return true
+	case "COMMAND":
+		escreverArrayRESP(w, 0)
+	case "GET":
+		if !aridade(1, false) {
+			break
+		}
+		valor, err := s.valorRESP(args[0])
+		if err != nil {
+			falhar(err)
+			break
+		}
+		escreverBulkRESP(w, valor)
+	case "MGET":
+		if !aridade(1, true) {
+			break
+		}
+		valores, err := s.valoresRESP(args)
+		if err != nil {
+			falhar(err)
+			break
+		}
+		escreverArrayRESP(w, len(valores))
+		for _, valor := range valores {
+			escreverBulkRESP(w, valor)
+		}
+	case "EXISTS":
+		if !aridade(1, true) {
+			break
+		}
+		var n int64
+		for _, chave := range args {
+			valor, err := s.valorRESP(chave)
+			if err == nil && valor != nil {
+				n++
+			}
+		}
+		escreverInteiroRESP(w, n)
+	case "SET":
+		if !aridade(2, false) {
+			break
+		}
+		if err := s.definirValorRESP(args[0], args[1]); err != nil {
+			falhar(err)
+			break
+		}
+		escreverSimplesRESP(w, "OK")
+	case "DEL":
+		if !aridade(1, true) {
+			break
+		}
+		n, err := s.removerChavesRESP(args)
+		if err != nil {
+			falhar(err)
+			break
+		}
+		escreverInteiroRESP(w, n)
+	case "SCAN":
+		if !aridade(1, true) {
+			break
+		}
+		cursor, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			escreverErroRESP(w, "ERR invalid cursor")
+			break
+		}
+		padrao, count := "*", 10
+		for i := 1; i < len(args); i += 2 {
+			if i+1 >= len(args) {
+				escreverErroRESP(w, "ERR syntax error")
+				return false
+			}
+			switch strings.ToUpper(args[i]) {
+			case "MATCH":
+				padrao = args[i+1]
+			case "COUNT":
+				count, err = strconv.Atoi(args[i+1])
+				if err != nil || count < 1 {
+					escreverErroRESP(w, "ERR value is not an integer or out of range")
+					return false
+				}
+				count = min(count, countMaximoScanRESP)
+			default:
+				escreverErroRESP(w, "ERR syntax error")
+				return false
+			}
+		}
+
+		proximo, chaves, err := s.scan(cursor, padrao, count)
+		if err != nil {
+			falhar(err)
+			break
+		}
+		escreverArrayRESP(w, 2)
+		escreverBulkRESP(w, []byte(strconv.FormatUint(proximo, 10)))
+		escreverArrayRESP(w, len(chaves))
+		for _, chave := range chaves {
+			escreverBulkRESP(w, []byte(chave))
+		}
+	default:
+		escreverErroRESP(w, fmt.Sprintf("ERR unknown command '%s'", strings.ToLower(comando)))
+	}
+	return false
+}
+
+func servirRESP(endereco string, banco *Banco) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listener, err := net.Listen("tcp", endereco)
+	if err != nil {
+		return fmt.Errorf("erro ao abrir %s: %w", endereco, err)
+	}
+
+	s := novoServidorRESP(banco)
+	erros := make(chan error, 1)
+	go func() {
+		log.Printf("servidor RESP ouvindo em %s", listener.Addr())
+		erros <- s.servir(listener)
+	}()
+
+	select {
+	case err := <-erros:
+		listener.Close()
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("encerrando servidor RESP")
+	listener.Close()
+	s.encerrar()
+	return <-erros
+}
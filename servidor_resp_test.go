@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// abrirBancoTeste abre um banco vazio num diretório temporário.
+func abrirBancoTeste(t testing.TB) *Banco {
+	t.Helper()
+	b, err := abrirBanco(arquivosNoDiretorio(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.fechar() })
+	return b
+}
+
+func produtoTeste(t testing.TB, productID int32, preco Preco, brand string) Produto {
+	t.Helper()
+	produto, err := ModeloProduto{ProductID: productID, Price: preco, Brand: brand, CategoryCode: "cat"}.registro()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return produto
+}
+
+func acessoTeste(t testing.TB, sessao string, userID int32, evento string) Acesso {
+	t.Helper()
+	acesso, err := ModeloAcesso{UserSession: sessao, UserID: userID, EventType: evento}.registro()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return acesso
+}
+
+// clienteRESP fala com o servidor por uma conexão TCP de verdade.
+type clienteRESP struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// iniciarServidorRESP sobe o servidor em 127.0.0.1:0 sobre b e conecta um cliente.
+func iniciarServidorRESP(t *testing.T, b *Banco) *clienteRESP {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := novoServidorRESP(b)
+	erros := make(chan error, 1)
+	go func() { erros <- s.servir(listener) }()
+	t.Cleanup(func() {
+		listener.Close()
+		s.encerrar()
+		if err := <-erros; err != nil {
+			t.Error(err)
+		}
+	})
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &clienteRESP{t: t, conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// comando envia args como array de bulk strings e devolve a resposta: string para
+// respostas simples, erroRESP, int64, []byte (nil para o bulk nulo) ou []any.
+func (c *clienteRESP) comando(args ...string) any {
+	c.t.Helper()
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	c.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		c.t.Fatal(err)
+	}
+
+	resposta, err := lerRespostaRESP(c.reader)
+	if err != nil {
+		c.t.Fatalf("%v: erro ao ler a resposta: %v", args, err)
+	}
+	return resposta
+}
+
+func lerRespostaRESP(reader *bufio.Reader) (any, error) {
+	linha, err := lerLinhaRESP(reader)
+	if err != nil {
+		return nil, err
+	}
+	if linha == "" {
+		return nil, fmt.Errorf("resposta vazia")
+	}
+
+	switch linha[0] {
+	case '+':
+		return linha[1:], nil
+	case '-':
+		return erroRESP(linha[1:]), nil
+	case ':':
+		return strconv.ParseInt(linha[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(linha[1:])
+		if err != nil || n < 0 {
+			return []byte(nil), err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(linha[1:])
+		if err != nil {
+			return nil, err
+		}
+		itens := make([]any, n)
+		for i := range itens {
+			itens[i], err = lerRespostaRESP(reader)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return itens, nil
+	}
+	return nil, fmt.Errorf("resposta desconhecida: %q", linha)
+}
+
+func TestRESPPing(t *testing.T) {
+	c := iniciarServidorRESP(t, abrirBancoTeste(t))
+
+	if r := c.comando("PING"); r != "PONG" {
+		t.Errorf("PING = %v, quero PONG", r)
+	}
+	if r := c.comando("PING", "olá"); string(r.([]byte)) != "olá" {
+		t.Errorf("PING olá = %q", r)
+	}
+}
+
+func TestRESPSetGet(t *testing.T) {
+	c := iniciarServidorRESP(t, abrirBancoTeste(t))
+
+	if r := c.comando("SET", "produto:7", `{"product_id":70,"price":"12.50","brand":"marca","category_code":"cat"}`); r != "OK" {
+		t.Fatalf("SET produto:7 = %v", r)
+	}
+	if r := c.comando("SET", "acesso:3", `{"user_session":"s1","user_id":9,"event_type":"view"}`); r != "OK" {
+		t.Fatalf("SET acesso:3 = %v", r)
+	}
+
+	var produto ModeloProduto
+	if err := json.Unmarshal(c.comando("GET", "produto:7").([]byte), &produto); err != nil {
+		t.Fatal(err)
+	}
+	quero := ModeloProduto{ID: 7, ProductID: 70, Price: 1250, Brand: "marca", CategoryCode: "cat"}
+	if produto != quero {
+		t.Errorf("GET produto:7 = %+v, quero %+v", produto, quero)
+	}
+
+	// SET numa chave existente atualiza o registro no lugar.
+	c.comando("SET", "produto:7", `{"product_id":71,"price":1,"brand":"outra","category_code":"cat"}`)
+	json.Unmarshal(c.comando("GET", "produto:7").([]byte), &produto)
+	if produto.ProductID != 71 || produto.Price != 100 || produto.Brand != "outra" {
+		t.Errorf("GET depois do segundo SET = %+v", produto)
+	}
+
+	if r := c.comando("GET", "produto:8"); r.([]byte) != nil {
+		t.Errorf("GET produto:8 = %q, quero nil", r)
+	}
+}
+
+func TestRESPMGet(t *testing.T) {
+	b := abrirBancoTeste(t)
+	if _, err := b.inserirProdutos([]Produto{produtoTeste(t, 10, 100, "a"), produtoTeste(t, 20, 200, "b")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.inserirAcessos([]Acesso{acessoTeste(t, "s", 1, "view")}); err != nil {
+		t.Fatal(err)
+	}
+	c := iniciarServidorRESP(t, b)
+
+	r := c.comando("MGET", "produto:2", "produto:3", "acesso:1", "invalida", "produto:1")
+	valores := r.([]any)
+	if len(valores) != 5 {
+		t.Fatalf("MGET devolveu %d valores, quero 5", len(valores))
+	}
+	for i, querID := range []int32{2, 0, 1, 0, 1} {
+		valor := valores[i].([]byte)
+		if querID == 0 {
+			if valor != nil {
+				t.Errorf("valor %d = %s, quero nil", i, valor)
+			}
+			continue
+		}
+		var registro struct{ ID int32 }
+		if err := json.Unmarshal(valor, &registro); err != nil || registro.ID != querID {
+			t.Errorf("valor %d = %s, quero o ID %d", i, valor, querID)
+		}
+	}
+
+	// Uma falha de E/S vira um erro padrão, com o prefixo ERR.
+	if err := os.Remove(b.arquivos.IndiceProdutos); err != nil {
+		t.Fatal(err)
+	}
+	if r, ok := c.comando("MGET", "produto:1").(erroRESP); !ok || !strings.HasPrefix(string(r), "ERR ") {
+		t.Errorf("MGET sem índice = %q, quero um erro com o prefixo ERR", r)
+	}
+}
+
+func TestRESPDel(t *testing.T) {
+	b := abrirBancoTeste(t)
+	produtos := make([]Produto, 5)
+	for i := range produtos {
+		produtos[i] = produtoTeste(t, int32(i), Preco(i*100), "m")
+	}
+	if _, err := b.inserirProdutos(produtos); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.inserirAcessos([]Acesso{acessoTeste(t, "s", 1, "view")}); err != nil {
+		t.Fatal(err)
+	}
+	c := iniciarServidorRESP(t, b)
+
+	// Chaves repetidas e ausentes não contam, como no Redis.
+	if r := c.comando("DEL", "produto:2", "produto:4", "produto:2", "produto:99", "acesso:1"); r != int64(3) {
+		t.Errorf("DEL = %v, quero 3", r)
+	}
+	for _, caso := range []struct {
+		chave  string
+		existe bool
+	}{{"produto:1", true}, {"produto:2", false}, {"produto:3", true}, {"produto:4", false}, {"produto:5", true}, {"acesso:1", false}} {
+		if existe := c.comando("GET", caso.chave).([]byte) != nil; existe != caso.existe {
+			t.Errorf("depois do DEL, %s existe = %v, quero %v", caso.chave, existe, caso.existe)
+		}
+	}
+	if r := c.comando("DEL", "produto:2"); r != int64(0) {
+		t.Errorf("DEL de chave ausente = %v, quero 0", r)
+	}
+	if r, ok := c.comando("DEL", "outra:1").(erroRESP); !ok || !strings.HasPrefix(string(r), "ERR ") {
+		t.Errorf("DEL de chave inválida = %v, quero erro", r)
+	}
+
+	problemas, err := verificarArquivos(b.arquivos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problemas) > 0 {
+		t.Errorf("arquivos inconsistentes depois do DEL: %v", problemas)
+	}
+}
+
+func TestRESPScan(t *testing.T) {
+	b := abrirBancoTeste(t)
+	produtos := make([]Produto, 7)
+	for i := range produtos {
+		produtos[i] = produtoTeste(t, int32(i), 100, "m")
+	}
+	acessos := make([]Acesso, 4)
+	for i := range acessos {
+		acessos[i] = acessoTeste(t, "s", int32(i), "view")
+	}
+	if _, err := b.inserirProdutos(produtos); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.inserirAcessos(acessos); err != nil {
+		t.Fatal(err)
+	}
+	c := iniciarServidorRESP(t, b)
+
+	var quero []string
+	for i := 1; i <= 7; i++ {
+		quero = append(quero, fmt.Sprintf("produto:%d", i))
+	}
+	for i := 1; i <= 4; i++ {
+		quero = append(quero, fmt.Sprintf("acesso:%d", i))
+	}
+
+	for _, count := range []string{"1", "3", "100", "1000000000"} {
+		var chaves []string
+		cursor := "0"
+		for voltas := 0; ; voltas++ {
+			if voltas > 20 {
+				t.Fatalf("COUNT %s: SCAN não terminou", count)
+			}
+			r := c.comando("SCAN", cursor, "COUNT", count).([]any)
+			cursor = string(r[0].([]byte))
+			for _, chave := range r[1].([]any) {
+				chaves = append(chaves, string(chave.([]byte)))
+			}
+			if cursor == "0" {
+				break
+			}
+		}
+		if !slices.Equal(chaves, quero) {
+			t.Errorf("COUNT %s: SCAN = %v, quero %v", count, chaves, quero)
+		}
+	}
+
+	r := c.comando("SCAN", "0", "MATCH", "produto:[12]", "COUNT", "10").([]any)
+	if chaves := r[1].([]any); len(chaves) != 2 {
+		t.Errorf("SCAN MATCH produto:[12] = %v", chaves)
+	}
+	if _, ok := c.comando("SCAN", "0", "COUNT", "0").(erroRESP); !ok {
+		t.Error("SCAN COUNT 0 deveria falhar")
+	}
+}
+
+func TestRESPScanLimitaCount(t *testing.T) {
+	b := abrirBancoTeste(t)
+	produtos := make([]Produto, countMaximoScanRESP+5)
+	for i := range produtos {
+		produtos[i] = produtoTeste(t, int32(i), 100, "m")
+	}
+	if _, err := b.inserirProdutos(produtos); err != nil {
+		t.Fatal(err)
+	}
+	c := iniciarServidorRESP(t, b)
+
+	r := c.comando("SCAN", "0", "COUNT", "2000000000").([]any)
+	if n := len(r[1].([]any)); n != countMaximoScanRESP {
+		t.Errorf("SCAN devolveu %d chaves, quero %d", n, countMaximoScanRESP)
+	}
+	if cursor := string(r[0].([]byte)); cursor != strconv.Itoa(countMaximoScanRESP+1) {
+		t.Errorf("cursor = %s, quero %d", cursor, countMaximoScanRESP+1)
+	}
+}
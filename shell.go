@@ -0,0 +1,653 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Colunas mostradas pelo shell, na ordem dos campos nos registros.
+var (
+	colunasProduto = []string{"id", "product_id", "price", "brand", "category_code"}
+	colunasAcesso  = []string{"id", "user_session", "user_id", "event_type"}
+)
+
+// atalhosTop são os nomes aceitos por "top" no lugar de tabela e campo.
+var atalhosTop = map[string][2]string{
+	"sessions":   {"acessos", "user_session"},
+	"users":      {"acessos", "user_id"},
+	"events":     {"acessos", "event_type"},
+	"products":   {"produtos", "product_id"},
+	"brands":     {"produtos", "brand"},
+	"categories": {"produtos", "category_code"},
+}
+
+type instrucaoShell struct {
+	nome      string
+	uso       string
+	descricao string
+	executar  func(s *shell, args []string) error
+}
+
+// instrucoesShell fica numa função para que os comandos possam citar a própria lista
+// (help e a completação) sem um ciclo de inicialização.
+func instrucoesShell() []instrucaoShell {
+	return []instrucaoShell{
+		{"open", "open [diretório]", "abre o banco no diretório (padrão: o diretório atual)", (*shell).comandoOpen},
+		{"get", "get <tabela> <id>...", "mostra os registros com os IDs", (*shell).comandoGet},
+		{"scan", "scan <tabela> [from <id>] [limit <n>] [where <campo>=<valor>]", "lista os registros em ordem de ID", (*shell).comandoScan},
+		{"top", "top <atalho> [k] | top <tabela> <campo> [k]", "valores mais frequentes; atalhos: sessions, users, events, products, brands, categories", (*shell).comandoTop},
+		{"insert", "insert <tabela> <campo>=<valor>...", "insere um registro com o próximo ID", (*shell).comandoInsert},
+		{"delete", "delete <tabela> <id>...", "remove os registros com os IDs (todos ou nenhum)", (*shell).comandoDelete},
+		{"stats", "stats", "tamanhos dos arquivos de dados e dos índices", (*shell).comandoStats},
+		{"help", "help", "mostra esta ajuda", (*shell).comandoHelp},
+		{"exit", "exit", "sai do shell (também quit ou Ctrl-D)", nil},
+	}
+}
+
+// shell é o estado da sessão interativa: o banco aberto e onde escrever os resultados.
+type shell struct {
+	banco    *Banco
+	arquivos Arquivos
+	mmap     bool
+	saida    io.Writer
+}
+
+// executarShell lê e executa comandos até exit ou o fim da entrada. Erros de um comando
+// são mostrados e não encerram a sessão.
+func executarShell(s *shell, editor *editorLinha) error {
+	if editor.terminal {
+		fmt.Fprintln(s.saida, "digite help para ver os comandos")
+	}
+
+	for {
+		linha, err := editor.lerLinha("banco> ")
+		if errors.Is(err, errLinhaCancelada) {
+			continue
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("erro ao ler o comando: %w", err)
+		}
+
+		args, err := dividirArgumentos(linha)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "erro:", err)
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if args[0] == "exit" || args[0] == "quit" {
+			break
+		}
+
+		if err := s.executar(args); err != nil {
+			fmt.Fprintln(os.Stderr, "erro:", err)
+		}
+	}
+
+	if err := editor.salvarHistorico(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	return s.banco.fechar()
+}
+
+func (s *shell) executar(args []string) error {
+	for _, c := range instrucoesShell() {
+		if c.nome == args[0] && c.executar != nil {
+			return c.executar(s, args[1:])
+		}
+	}
+	return fmt.Errorf("comando desconhecido: %s (digite help)", args[0])
+}
+
+// dividirArgumentos separa a linha em palavras, mantendo juntas as partes entre aspas
+// simples ou duplas, como em brand="nova marca".
+func dividirArgumentos(linha string) ([]string, error) {
+	var args []string
+	var atual strings.Builder
+	var aspas rune
+	temPalavra := false
+
+	for _, r := range linha {
+		switch {
+		case aspas != 0 && r == aspas:
+			aspas = 0
+		case aspas != 0:
+			atual.WriteRune(r)
+		case r == '"' || r == '\'':
+			aspas = r
+			temPalavra = true
+		case r == ' ' || r == '\t':
+			if temPalavra {
+				args = append(args, atual.String())
+				atual.Reset()
+				temPalavra = false
+			}
+		default:
+			atual.WriteRune(r)
+			temPalavra = true
+		}
+	}
+	if aspas != 0 {
+		return nil, fmt.Errorf("aspas não fechadas")
+	}
+	if temPalavra {
+		args = append(args, atual.String())
+	}
+	return args, nil
+}
+
+// tabelaDoShell aceita o nome da tabela no singular ou no plural e devolve o plural,
+// que é o nome usado pelo resto do programa.
+func tabelaDoShell(nome string) (string, error) {
+	switch nome {
+	case "produto", "produtos":
+		return "produtos", nil
+	case "acesso", "acessos":
+		return "acessos", nil
+	}
+	return "", fmt.Errorf("tabela desconhecida: %s (use produto ou acesso)", nome)
+}
+
+func linhaProduto(produto *Produto) []string {
+	linha := make([]string, len(colunasProduto))
+	for i, coluna := range colunasProduto {
+		linha[i] = camposProduto[coluna].extrair(produto).String()
+	}
+	return linha
+}
+
+func linhaAcesso(acesso *Acesso) []string {
+	linha := make([]string, len(colunasAcesso))
+	for i, coluna := range colunasAcesso {
+		linha[i] = camposAcesso[coluna].extrair(acesso).String()
+	}
+	return linha
+}
+
+func lerIDs(args []string) ([]int32, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("informe pelo menos um ID")
+	}
+	ids := make([]int32, len(args))
+	for i, arg := range args {
+		id, err := strconv.ParseInt(arg, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("ID inválido: %q", arg)
+		}
+		ids[i] = int32(id)
+	}
+	return ids, nil
+}
+
+func (s *shell) abrir(arquivos Arquivos) error {
+	banco, err := abrirBancoComando(arquivos, s.mmap)
+	if err != nil {
+		return err
+	}
+	if s.banco != nil {
+		s.banco.fechar()
+	}
+	s.banco = banco
+	s.arquivos = arquivos
+	return nil
+}
+
+func (s *shell) comandoOpen(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("uso: open [diretório]")
+	}
+
+	arquivos := arquivosPadrao()
+	if len(args) == 1 {
+		info, err := os.Stat(args[0])
+		if err != nil {
+			return fmt.Errorf("erro ao abrir o diretório %s: %w", args[0], err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s não é um diretório", args[0])
+		}
+		arquivos = arquivosNoDiretorio(args[0])
+	}
+
+	if err := s.abrir(arquivos); err != nil {
+		return err
+	}
+	fmt.Fprintf(s.saida, "banco aberto: %s, %s\n", arquivos.Produtos, arquivos.Acessos)
+	return nil
+}
+
+func (s *shell) comandoGet(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("uso: get <tabela> <id>...")
+	}
+	tabela, err := tabelaDoShell(args[0])
+	if err != nil {
+		return err
+	}
+	ids, err := lerIDs(args[1:])
+	if err != nil {
+		return err
+	}
+
+	var linhas [][]string
+	var ausentes []int32
+	var colunas []string
+	switch tabela {
+	case "produtos":
+		colunas = colunasProduto
+		var produtos []*Produto
+		produtos, ausentes, err = s.banco.consultarProdutos(ids)
+		for _, produto := range produtos {
+			if produto != nil {
+				linhas = append(linhas, linhaProduto(produto))
+			}
+		}
+	case "acessos":
+		colunas = colunasAcesso
+		var acessos []*Acesso
+		acessos, ausentes, err = s.banco.consultarAcessos(ids)
+		for _, acesso := range acessos {
+			if acesso != nil {
+				linhas = append(linhas, linhaAcesso(acesso))
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(linhas) > 0 {
+		if err := escreverTabela(s.saida, colunas, linhas); err != nil {
+			return err
+		}
+	}
+	for _, id := range ausentes {
+		fmt.Fprintf(s.saida, "ID %d não encontrado\n", id)
+	}
+	return nil
+}
+
+// opcoesScan interpreta os modificadores de scan, em qualquer ordem.
+func opcoesScan(tabela string, args []string) (idInicial int32, limite int, campo string, valor string, err error) {
+	limite = 20
+	for i := 0; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return 0, 0, "", "", fmt.Errorf("falta o valor de %s", args[i])
+		}
+		switch args[i] {
+		case "from":
+			id, err := strconv.ParseInt(args[i+1], 10, 32)
+			if err != nil {
+				return 0, 0, "", "", fmt.Errorf("ID inválido: %q", args[i+1])
+			}
+			idInicial = int32(id)
+		case "limit":
+			limite, err = strconv.Atoi(args[i+1])
+			if err != nil || limite < 0 {
+				return 0, 0, "", "", fmt.Errorf("limite inválido: %q", args[i+1])
+			}
+		case "where":
+			var ok bool
+			campo, valor, ok = strings.Cut(args[i+1], "=")
+			if !ok {
+				return 0, 0, "", "", fmt.Errorf("filtro inválido: %q (use campo=valor)", args[i+1])
+			}
+			if _, err := campoNumerico(tabela, campo); err != nil {
+				return 0, 0, "", "", err
+			}
+		default:
+			return 0, 0, "", "", fmt.Errorf("opção desconhecida: %s (use from, limit ou where)", args[i])
+		}
+	}
+	return idInicial, limite, campo, valor, nil
+}
+
+func (s *shell) comandoScan(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("uso: scan <tabela> [from <id>] [limit <n>] [where <campo>=<valor>]")
+	}
+	tabela, err := tabelaDoShell(args[0])
+	if err != nil {
+		return err
+	}
+	idInicial, limite, campo, valor, err := opcoesScan(tabela, args[1:])
+	if err != nil {
+		return err
+	}
+
+	var linhas [][]string
+	var colunas []string
+	switch tabela {
+	case "produtos":
+		colunas = colunasProduto
+		var filtro func(*Produto) bool
+		if campo != "" {
+			filtro = func(p *Produto) bool { return camposProduto[campo].extrair(p).String() == valor }
+		}
+		var produtos []Produto
+		produtos, err = s.banco.listarProdutos(idInicial, math.MaxInt32, limite, filtro)
+		for i := range produtos {
+			linhas = append(linhas, linhaProduto(&produtos[i]))
+		}
+	case "acessos":
+		colunas = colunasAcesso
+		var filtro func(*Acesso) bool
+		if campo != "" {
+			filtro = func(a *Acesso) bool { return camposAcesso[campo].extrair(a).String() == valor }
+		}
+		var acessos []Acesso
+		acessos, err = s.banco.listarAcessos(idInicial, math.MaxInt32, limite, filtro)
+		for i := range acessos {
+			linhas = append(linhas, linhaAcesso(&acessos[i]))
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := escreverTabela(s.saida, colunas, linhas); err != nil {
+		return err
+	}
+	fmt.Fprintf(s.saida, "(%d registro(s))\n", len(linhas))
+	return nil
+}
+
+func (s *shell) comandoTop(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("uso: top <atalho> [k] ou top <tabela> <campo> [k]")
+	}
+
+	var tabela, campo string
+	if atalho, ok := atalhosTop[args[0]]; ok {
+		tabela, campo = atalho[0], atalho[1]
+		args = args[1:]
+	} else {
+		if len(args) < 2 {
+			return fmt.Errorf("uso: top <atalho> [k] ou top <tabela> <campo> [k]")
+		}
+		var err error
+		tabela, err = tabelaDoShell(args[0])
+		if err != nil {
+			return err
+		}
+		campo = args[1]
+		args = args[2:]
+	}
+
+	k := 10
+	if len(args) > 1 {
+		return fmt.Errorf("argumentos demais para top")
+	}
+	if len(args) == 1 {
+		var err error
+		k, err = strconv.Atoi(args[0])
+		if err != nil || k < 0 {
+			return fmt.Errorf("k inválido: %q", args[0])
+		}
+	}
+
+	filename, err := arquivoDaTabela(tabela, s.arquivos.Produtos, s.arquivos.Acessos)
+	if err != nil {
+		return err
+	}
+	top, err := topKValores(s.arquivos.Armazenamento, tabela, filename, campo, k)
+	if err != nil {
+		return err
+	}
+	return escreverTopK(s.saida, "tabela", campo, top)
+}
+
+// lerInt32Campo interpreta o valor de um campo int32 informado em insert.
+func lerInt32Campo(campo string, valor string) (int32, error) {
+	n, err := strconv.ParseInt(valor, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("valor inválido para %s: %q", campo, valor)
+	}
+	return int32(n), nil
+}
+
+// produtoDosCampos monta um produto a partir de pares campo=valor. O ID não pode ser
+// informado: ele vem da sequência.
+func produtoDosCampos(pares []string) (ModeloProduto, error) {
+	var productID int32
+	var price Preco
+	var brand, categoryCode string
+	for _, par := range pares {
+		campo, valor, ok := strings.Cut(par, "=")
+		if !ok {
+			return ModeloProduto{}, fmt.Errorf("use campo=valor: %q", par)
+		}
+
+		var err error
+		switch campo {
+		case "product_id":
+			productID, err = lerInt32Campo(campo, valor)
+		case "price":
+			price, err = lerPreco(valor)
+		case "brand":
+			brand = valor
+		case "category_code":
+			categoryCode = valor
+		default:
+			err = fmt.Errorf("campo %q não pode ser informado em produtos", campo)
+		}
+		if err != nil {
+			return ModeloProduto{}, err
+		}
+	}
+	return novoModeloProduto(productID, price, brand, categoryCode)
+}
+
+func acessoDosCampos(pares []string) (ModeloAcesso, error) {
+	var userSession, eventType string
+	var userID int32
+	for _, par := range pares {
+		campo, valor, ok := strings.Cut(par, "=")
+		if !ok {
+			return ModeloAcesso{}, fmt.Errorf("use campo=valor: %q", par)
+		}
+
+		var err error
+		switch campo {
+		case "user_session":
+			userSession = valor
+		case "user_id":
+			userID, err = lerInt32Campo(campo, valor)
+		case "event_type":
+			eventType = valor
+		default:
+			err = fmt.Errorf("campo %q não pode ser informado em acessos", campo)
+		}
+		if err != nil {
+			return ModeloAcesso{}, err
+		}
+	}
+	return novoModeloAcesso(userSession, userID, eventType)
+}
+
+func (s *shell) comandoInsert(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("uso: insert <tabela> <campo>=<valor>...")
+	}
+	tabela, err := tabelaDoShell(args[0])
+	if err != nil {
+		return err
+	}
+
+	switch tabela {
+	case "produtos":
+		modelo, err := produtoDosCampos(args[1:])
+		if err != nil {
+			return err
+		}
+		produto, err := modelo.registro()
+		if err != nil {
+			return err
+		}
+		produto, err = s.banco.inserirProduto(produto)
+		if err != nil {
+			return err
+		}
+		return escreverTabela(s.saida, colunasProduto, [][]string{linhaProduto(&produto)})
+	default:
+		modelo, err := acessoDosCampos(args[1:])
+		if err != nil {
+			return err
+		}
+		acesso, err := modelo.registro()
+		if err != nil {
+			return err
+		}
+		acesso, err = s.banco.inserirAcesso(acesso)
+		if err != nil {
+			return err
+		}
+		return escreverTabela(s.saida, colunasAcesso, [][]string{linhaAcesso(&acesso)})
+	}
+}
+
+func (s *shell) comandoDelete(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("uso: delete <tabela> <id>...")
+	}
+	tabela, err := tabelaDoShell(args[0])
+	if err != nil {
+		return err
+	}
+	ids, err := lerIDs(args[1:])
+	if err != nil {
+		return err
+	}
+	// Um ID repetido remove um registro só.
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	if tabela == "produtos" {
+		err = s.banco.removerProdutos(ids)
+	} else {
+		err = s.banco.removerAcessos(ids)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(s.saida, "%d registro(s) removido(s) de %s\n", len(ids), tabela)
+	return nil
+}
+
+func (s *shell) comandoStats(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("uso: stats")
+	}
+
+	type arquivoStats struct {
+		nome    string
+		tipo    string
+		tamanho int
+		proximo func(Armazenamento, string) (int32, error)
+	}
+	arquivos := []arquivoStats{
+		{s.arquivos.Produtos, "dados", tamanhoProduto, maiorIDProdutos},
+		{s.arquivos.IndiceProdutos, "índice", binary.Size(IndexProduto{}), nil},
+		{s.arquivos.IndicePrecos, "índice de preços", binary.Size(IndexPreco{}), nil},
+		{arquivoDelta(s.arquivos.IndicePrecos), "delta de preços", tamanhoOperacaoDelta, nil},
+		{s.arquivos.Acessos, "dados", tamanhoAcesso, maiorIDAcessos},
+		{s.arquivos.IndiceAcessos, "índice", binary.Size(IndexAcesso{}), nil},
+	}
+
+	linhas := make([][]string, len(arquivos))
+	for i, a := range arquivos {
+		bytes, err := tamanhoArquivo(s.arquivos.Armazenamento, a.nome)
+		if err != nil {
+			return err
+		}
+		proximo := ""
+		if a.proximo != nil {
+			id, err := lerSequencia(s.arquivos.Armazenamento, a.nome, a.proximo)
+			if err != nil {
+				return err
+			}
+			proximo = strconv.Itoa(int(id))
+		}
+		linhas[i] = []string{a.nome, a.tipo, strconv.FormatInt(bytes/int64(a.tamanho), 10), strconv.FormatInt(bytes, 10), proximo}
+	}
+	return escreverTabela(s.saida, []string{"arquivo", "tipo", "registros", "bytes", "próximo ID"}, linhas)
+}
+
+func (s *shell) comandoHelp(args []string) error {
+	var linhas [][]string
+	for _, c := range instrucoesShell() {
+		linhas = append(linhas, []string{c.uso, c.descricao})
+	}
+	return escreverTabela(s.saida, []string{"comando", "descrição"}, linhas)
+}
+
+// completar devolve as opções para a última palavra de antes: o nome do comando, a
+// tabela, os atalhos de top, as opções de scan ou os campos da tabela.
+func (s *shell) completar(antes string) []string {
+	palavras := strings.Fields(antes)
+	atual := ""
+	if len(palavras) > 0 && !strings.HasSuffix(antes, " ") {
+		atual = palavras[len(palavras)-1]
+		palavras = palavras[:len(palavras)-1]
+	}
+
+	var opcoes []string
+	switch {
+	case len(palavras) == 0:
+		for _, c := range instrucoesShell() {
+			opcoes = append(opcoes, c.nome)
+		}
+	case len(palavras) == 1:
+		switch palavras[0] {
+		case "get", "insert", "delete":
+			opcoes = []string{"produto", "acesso"}
+		case "scan":
+			opcoes = []string{"produtos", "acessos"}
+		case "top":
+			opcoes = []string{"produtos", "acessos"}
+			for atalho := range atalhosTop {
+				opcoes = append(opcoes, atalho)
+			}
+		}
+	default:
+		tabela, err := tabelaDoShell(palavras[1])
+		if err != nil {
+			return nil
+		}
+		switch palavras[0] {
+		case "top":
+			if len(palavras) == 2 {
+				opcoes = nomesCampos(tabela)
+			}
+		case "insert":
+			for _, campo := range nomesCampos(tabela) {
+				if campo != "id" {
+					opcoes = append(opcoes, campo+"=")
+				}
+			}
+		case "scan":
+			if palavras[len(palavras)-1] == "where" {
+				for _, campo := range nomesCampos(tabela) {
+					opcoes = append(opcoes, campo+"=")
+				}
+			} else if len(palavras)%2 == 0 {
+				opcoes = []string{"from", "limit", "where"}
+			}
+		}
+	}
+
+	opcoes = slices.DeleteFunc(opcoes, func(o string) bool { return !strings.HasPrefix(o, atual) })
+	slices.Sort(opcoes)
+	return opcoes
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestShellDeleteContaRegistrosRemovidos(t *testing.T) {
+	b := abrirBancoTeste(t)
+	produtos, err := b.inserirProdutos([]Produto{produtoTeste(t, 1, 100, "m"), produtoTeste(t, 2, 200, "m"), produtoTeste(t, 3, 300, "m")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(produtos))
+	for i, produto := range produtos {
+		ids[i] = strconv.Itoa(int(bytesToInt32(produto.ID)))
+	}
+
+	var saida bytes.Buffer
+	s := &shell{banco: b, arquivos: b.arquivos, saida: &saida}
+
+	// Um ID repetido conta uma vez só.
+	if err := s.executar([]string{"delete", "produto", ids[0], ids[0], ids[1]}); err != nil {
+		t.Fatal(err)
+	}
+	if quero := "2 registro(s) removido(s) de produtos\n"; saida.String() != quero {
+		t.Errorf("saída = %q, quero %q", saida.String(), quero)
+	}
+
+	// Com um ID ausente, nada é removido nem contado.
+	saida.Reset()
+	if err := s.executar([]string{"delete", "produto", ids[2], "999"}); err == nil {
+		t.Error("delete com ID ausente não falhou")
+	}
+	if saida.Len() != 0 {
+		t.Errorf("saída = %q, quero vazia", saida.String())
+	}
+	if err := s.executar([]string{"get", "produto", ids[2]}); err != nil {
+		t.Errorf("produto %s sumiu depois do delete que falhou: %v", ids[2], err)
+	}
+}
+
+func TestDividirArgumentos(t *testing.T) {
+	casos := []struct {
+		linha string
+		quero []string
+	}{
+		{`get produto 1`, []string{"get", "produto", "1"}},
+		{`  insert produto  brand="nova marca" `, []string{"insert", "produto", "brand=nova marca"}},
+		{`insert acesso event_type='' user_session="a'b"`, []string{"insert", "acesso", "event_type=", "user_session=a'b"}},
+		{``, nil},
+	}
+	for _, caso := range casos {
+		args, err := dividirArgumentos(caso.linha)
+		if err != nil {
+			t.Errorf("dividirArgumentos(%q): %v", caso.linha, err)
+			continue
+		}
+		if !slices.Equal(args, caso.quero) {
+			t.Errorf("dividirArgumentos(%q) = %q, quero %q", caso.linha, args, caso.quero)
+		}
+	}
+
+	if _, err := dividirArgumentos(`insert produto brand="sem fim`); err == nil {
+		t.Error("aspas sem fechar não deram erro")
+	}
+}
+
+func TestShellCompletar(t *testing.T) {
+	s := &shell{}
+	casos := []struct {
+		antes string
+		quero []string
+	}{
+		{"de", []string{"delete"}},
+		{"get ", []string{"acesso", "produto"}},
+		{"scan produtos ", []string{"from", "limit", "where"}},
+		{"insert acesso ev", []string{"event_type="}},
+		{"get mesa ", nil},
+	}
+	for _, caso := range casos {
+		if opcoes := s.completar(caso.antes); !slices.Equal(opcoes, caso.quero) {
+			t.Errorf("completar(%q) = %q, quero %q", caso.antes, opcoes, caso.quero)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+//go:build linux
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+func lerTermios(fd int) (syscall.Termios, error) {
+	var t syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCGETS, uintptr(unsafe.Pointer(&t)))
+	if errno != 0 {
+		return t, errno
+	}
+	return t, nil
+}
+
+func gravarTermios(fd int, t *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCSETS, uintptr(unsafe.Pointer(t)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func ehTerminal(fd int) bool {
+	_, err := lerTermios(fd)
+	return err == nil
+}
+
+// modoBruto desliga o eco e o modo canônico do terminal, para que o editor de linha
+// receba cada tecla, e devolve a função que restaura o modo anterior. A saída continua
+// processada pelo terminal, então "\n" ainda volta ao começo da linha.
+func modoBruto(fd int) (func() error, error) {
+	anterior, err := lerTermios(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	bruto := anterior
+	bruto.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	bruto.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	bruto.Cflag &^= syscall.CSIZE | syscall.PARENB
+	bruto.Cflag |= syscall.CS8
+	bruto.Cc[syscall.VMIN] = 1
+	bruto.Cc[syscall.VTIME] = 0
+	if err := gravarTermios(fd, &bruto); err != nil {
+		return nil, err
+	}
+
+	return func() error { return gravarTermios(fd, &anterior) }, nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// Fora do Linux o shell não edita a linha: lê a entrada linha a linha, como de um pipe.
+func ehTerminal(fd int) bool {
+	return false
+}
+
+func modoBruto(fd int) (func() error, error) {
+	return nil, errors.New("modo bruto do terminal não suportado neste sistema")
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"container/heap"
+	"io"
+	"sort"
+	"strconv"
+)
+
+type ContagemValor struct {
+	Valor string `json:"valor"`
+	Count int    `json:"count"`
+}
+
+// maisFrequente define a ordem do top-K: maior contagem primeiro e, no empate,
+// o menor valor em ordem lexicográfica.
+func maisFrequente(a, b ContagemValor) bool {
+	if a.Count != b.Count {
+		return a.Count > b.Count
+	}
+	return a.Valor < b.Valor
+}
+
+// heapTopK é um min-heap na ordem de maisFrequente: a raiz é o candidato que sai
+// primeiro quando aparece um valor mais frequente.
+type heapTopK []ContagemValor
+
+func (h heapTopK) Len() int           { return len(h) }
+func (h heapTopK) Less(i, j int) bool { return maisFrequente(h[j], h[i]) }
+func (h heapTopK) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *heapTopK) Push(x any)        { *h = append(*h, x.(ContagemValor)) }
+func (h *heapTopK) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+func topKValores(armazenamento Armazenamento, tabela string, filename string, campo string, k int) ([]ContagemValor, error) {
+	contagem := make(map[string]int)
+	err := percorrerCampos(armazenamento, tabela, filename, []string{campo}, func(valores []valorCampo) error {
+		contagem[valores[0].String()]++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if k <= 0 {
+		return []ContagemValor{}, nil
+	}
+
+	h := make(heapTopK, 0, k)
+	for valor, count := range contagem {
+		candidato := ContagemValor{Valor: valor, Count: count}
+		if h.Len() < k {
+			heap.Push(&h, candidato)
+		} else if maisFrequente(candidato, h[0]) {
+			h[0] = candidato
+			heap.Fix(&h, 0)
+		}
+	}
+
+	top := []ContagemValor(h)
+	sort.Slice(top, func(i, j int) bool { return maisFrequente(top[i], top[j]) })
+	return top, nil
+}
+
+func escreverTopK(w io.Writer, formato string, campo string, top []ContagemValor) error {
+	linhas := make([][]string, len(top))
+	for i, c := range top {
+		linhas[i] = []string{strconv.Itoa(i + 1), c.Valor, strconv.Itoa(c.Count)}
+	}
+	return escreverSaida(w, formato, []string{"#", campo, "count"}, linhas, top)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestTopKValores(t *testing.T) {
+	b := abrirBancoTeste(t)
+
+	// Sessões com contagens 5, 3, 3, 3, 2 e 1: o empate de 3 atravessa os cortes de k.
+	contagens := map[string]int{"e": 5, "c": 3, "a": 3, "d": 3, "b": 2, "f": 1}
+	var acessos []Acesso
+	for sessao, n := range contagens {
+		for i := range n {
+			acessos = append(acessos, acessoTeste(t, sessao, int32(i), "view"))
+		}
+	}
+	if _, err := b.inserirAcessos(acessos); err != nil {
+		t.Fatal(err)
+	}
+
+	// A referência conta tudo e ordena a lista inteira.
+	var todos []ContagemValor
+	for sessao, n := range contagens {
+		todos = append(todos, ContagemValor{Valor: sessao, Count: n})
+	}
+	sort.Slice(todos, func(i, j int) bool { return maisFrequente(todos[i], todos[j]) })
+
+	for _, k := range []int{0, 1, 2, 3, 4, 6, 10} {
+		top, err := topKValores(b.arquivos.Armazenamento, "acessos", b.arquivos.Acessos, "user_session", k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		quero := todos[:min(k, len(todos))]
+		if !slices.Equal(top, quero) {
+			t.Errorf("k=%d: top = %v, quero %v", k, top, quero)
+		}
+	}
+
+	// Um campo numérico é contado pelo texto do valor.
+	top, err := topKValores(b.arquivos.Armazenamento, "acessos", b.arquivos.Acessos, "user_id", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quero := []ContagemValor{{"0", 6}, {"1", 5}}; !slices.Equal(top, quero) {
+		t.Errorf("top de user_id = %v, quero %v", top, quero)
+	}
+
+	if _, err := topKValores(b.arquivos.Armazenamento, "acessos", b.arquivos.Acessos, "preco", 1); err == nil {
+		t.Error("campo inexistente aceito")
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// Problema é uma inconsistência encontrada por verificarArquivos, localizada pelo
+// arquivo e pelo offset em bytes onde ela aparece.
+type Problema struct {
+	Arquivo  string `json:"arquivo"`
+	Offset   int64  `json:"offset"`
+	Mensagem string `json:"problema"`
+}
+
+func (p Problema) String() string {
+	return fmt.Sprintf("%s@%d: %s", p.Arquivo, p.Offset, p.Mensagem)
+}
+
+// registrosVerificados guarda o ID de cada registro do arquivo de dados, por posição.
+type registrosVerificados struct {
+	arquivo    string
+	tamanho    int
+	ids        []int32
+	tamanhoArq int64
+}
+
+func (r *registrosVerificados) offset(pos int) int64 {
+	return int64(pos) * int64(r.tamanho)
+}
+
+// verificarArquivos confere os arquivos de dados, os índices e as sequências de IDs
+// do banco. O erro devolvido é só para falhas de E/S; inconsistências viram Problemas.
+func verificarArquivos(arquivos Arquivos) ([]Problema, error) {
+	var problemas []Problema
+
+	// Lido com 56 bytes, um arquivo do formato antigo daria um problema por registro.
+	antigo, err := formatoFloat32(arquivos.Armazenamento, arquivos.Produtos)
+	if err != nil {
+		return nil, err
+	}
+	if antigo {
+		return []Problema{{arquivos.Produtos, 0, "preços em float32, do formato antigo; converta com o comando migrar-precos"}}, nil
+	}
+
+	produtos, p, err := verificarDados(arquivos.Armazenamento, arquivos.Produtos, tamanhoProduto)
+	if err != nil {
+		return nil, err
+	}
+	problemas = append(problemas, p...)
+
+	p, err = verificarIndiceID(arquivos.Armazenamento, arquivos.IndiceProdutos, produtos)
+	if err != nil {
+		return nil, err
+	}
+	problemas = append(problemas, p...)
+
+	p, err = verificarIndicePrecos(arquivos.Armazenamento, arquivos.IndicePrecos, produtos)
+	if err != nil {
+		return nil, err
+	}
+	problemas = append(problemas, p...)
+
+	acessos, p, err := verificarDados(arquivos.Armazenamento, arquivos.Acessos, tamanhoAcesso)
+	if err != nil {
+		return nil, err
+	}
+	problemas = append(problemas, p...)
+
+	p, err = verificarIndiceID(arquivos.Armazenamento, arquivos.IndiceAcessos, acessos)
+	if err != nil {
+		return nil, err
+	}
+	problemas = append(problemas, p...)
+
+	for _, r := range []*registrosVerificados{produtos, acessos} {
+		p, err = verificarSequencia(arquivos.Armazenamento, r)
+		if err != nil {
+			return nil, err
+		}
+		problemas = append(problemas, p...)
+
+		p, err = verificarDiario(arquivos.Armazenamento, r)
+		if err != nil {
+			return nil, err
+		}
+		problemas = append(problemas, p...)
+	}
+
+	return problemas, nil
+}
+
+// verificarDados confere que o arquivo tem um número inteiro de registros e que os IDs
+// são únicos e crescentes.
+func verificarDados(armazenamento Armazenamento, filename string, tamanho int) (*registrosVerificados, []Problema, error) {
+	r := &registrosVerificados{arquivo: filename, tamanho: tamanho}
+	var problemas []Problema
+
+	file, err := abrirArquivo(armazenamento, filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, []Problema{{filename, 0, "arquivo de dados não existe"}}, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("erro ao abrir o arquivo %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, fmt.Errorf("erro ao obter informações do arquivo %s: %w", filename, err)
+	}
+	r.tamanhoArq = info.Size()
+
+	primeiro := make(map[int32]int64)
+	scanner := novoScannerRegistros(file, filename, tamanho)
+	for scanner.ler() {
+		id := int32(binary.LittleEndian.Uint32(scanner.registro[0:4]))
+		offset := scanner.offset()
+
+		if anterior, repetido := primeiro[id]; repetido {
+			problemas = append(problemas, Problema{filename, offset, fmt.Sprintf("ID %d repetido (já usado no offset %d)", id, anterior)})
+		} else {
+			primeiro[id] = offset
+			if len(r.ids) > 0 && id < r.ids[len(r.ids)-1] {
+				problemas = append(problemas, Problema{filename, offset, fmt.Sprintf("ID %d fora de ordem (o registro anterior tem ID %d)", id, r.ids[len(r.ids)-1])})
+			}
+		}
+		r.ids = append(r.ids, id)
+	}
+
+	var errRegistro *RecordError
+	if errors.As(scanner.erro(), &errRegistro) && errors.Is(errRegistro, ErrCorrupt) {
+		problemas = append(problemas, Problema{filename, errRegistro.Offset, fmt.Sprintf("registro incompleto: o arquivo tem %d bytes, que não é múltiplo de %d", r.tamanhoArq, tamanho)})
+	} else if scanner.erro() != nil {
+		return nil, nil, scanner.erro()
+	}
+
+	return r, problemas, nil
+}
+
+// verificarIndiceID confere que as entradas estão em ordem de ID, que cada uma aponta
+// para um registro com o mesmo ID e que todo registro tem exatamente uma entrada.
+func verificarIndiceID(armazenamento Armazenamento, indexName string, dados *registrosVerificados) ([]Problema, error) {
+	var problemas []Problema
+
+	file, err := abrirArquivo(armazenamento, indexName)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Problema{{indexName, 0, "índice não existe"}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir o arquivo de índice %s: %w", indexName, err)
+	}
+	defer file.Close()
+
+	var anterior int32
+	indexados := make([]bool, len(dados.ids))
+	scanner := novoScannerRegistros(file, indexName, tamanhoIndiceID)
+	for scanner.ler() {
+		id := int32(binary.LittleEndian.Uint32(scanner.registro[0:4]))
+		offsetDados := int64(binary.LittleEndian.Uint64(scanner.registro[4:12]))
+		offset := scanner.offset()
+
+		if offset > 0 && id <= anterior {
+			problemas = append(problemas, Problema{indexName, offset, fmt.Sprintf("entrada com ID %d fora de ordem ou repetida (a anterior tem ID %d)", id, anterior)})
+		}
+		anterior = id
+
+		if mensagem := conferirEntrada(dados, indexados, offsetDados, func(pos int) string {
+			if dados.ids[pos] != id {
+				return fmt.Sprintf("entrada com ID %d aponta para o registro de ID %d no offset %d", id, dados.ids[pos], offsetDados)
+			}
+			return ""
+		}); mensagem != "" {
+			problemas = append(problemas, Problema{indexName, offset, mensagem})
+		}
+	}
+	if p, err := problemaDeTamanho(&scanner, indexName, tamanhoIndiceID); err != nil {
+		return nil, err
+	} else if p != nil {
+		problemas = append(problemas, *p)
+	}
+
+	return append(problemas, registrosSemEntrada(dados, indexados, indexName)...), nil
+}
+
+// verificarIndicePrecos confere a ordem por preço, que cada entrada tem o preço do
+// registro apontado e que todo produto aparece uma vez.
+func verificarIndicePrecos(armazenamento Armazenamento, indexPreco string, produtos *registrosVerificados) ([]Problema, error) {
+	var problemas []Problema
+
+	file, err := abrirArquivo(armazenamento, indexPreco)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Problema{{indexPreco, 0, "índice não existe"}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir o arquivo de índice %s: %w", indexPreco, err)
+	}
+	defer file.Close()
+
+	// As entradas conferidas são as da base, menos as que o delta remove, mais as que ele
+	// adiciona. Um delta corrompido é relatado e a base é conferida sozinha.
+	delta, err := lerDeltaPrecos(armazenamento, indexPreco)
+	var errRegistro *RecordError
+	if errors.As(err, &errRegistro) && errors.Is(err, ErrCorrupt) {
+		problemas = append(problemas, Problema{errRegistro.Arquivo, errRegistro.Offset, errRegistro.Err.Error()})
+		delta = &deltaPrecos{}
+	} else if err != nil {
+		return nil, err
+	}
+
+	dados, err := abrirArquivo(armazenamento, produtos.arquivo)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("erro ao abrir o arquivo %s: %w", produtos.arquivo, err)
+	}
+	if dados != nil {
+		defer dados.Close()
+	}
+
+	indexados := make([]bool, len(produtos.ids))
+	conferirEntradaPreco := func(arquivo string, offset int64, entrada entradaPreco) error {
+		var erroLeitura error
+		if mensagem := conferirEntrada(produtos, indexados, entrada.offset, func(pos int) string {
+			var precoRegistro [8]byte
+			_, erroLeitura = dados.ReadAt(precoRegistro[:], entrada.offset+8)
+			if erroLeitura == nil && bytesToPreco(precoRegistro) != entrada.preco {
+				return fmt.Sprintf("entrada com preço %s aponta para o produto de ID %d com preço %s", entrada.preco, produtos.ids[pos], bytesToPreco(precoRegistro))
+			}
+			return ""
+		}); mensagem != "" {
+			problemas = append(problemas, Problema{arquivo, offset, mensagem})
+		}
+		if erroLeitura != nil {
+			return fmt.Errorf("erro ao ler registro de produto: %w", erroRegistro(produtos.arquivo, entrada.offset, erroLeitura))
+		}
+		return nil
+	}
+
+	var anterior Preco
+	removidas := make(map[entradaPreco]bool)
+	scanner := novoScannerRegistros(file, indexPreco, tamanhoIndicePreco)
+	for scanner.ler() {
+		entrada := entradaPrecoDe(scanner.registro)
+		offset := scanner.offset()
+
+		if offset > 0 && entrada.preco < anterior {
+			problemas = append(problemas, Problema{indexPreco, offset, fmt.Sprintf("entrada com preço %s fora de ordem (a anterior tem preço %s)", entrada.preco, anterior)})
+		}
+		anterior = entrada.preco
+
+		if delta.remocoes[entrada] {
+			removidas[entrada] = true
+			continue
+		}
+		if err := conferirEntradaPreco(indexPreco, offset, entrada); err != nil {
+			return nil, err
+		}
+	}
+	if p, err := problemaDeTamanho(&scanner, indexPreco, tamanhoIndicePreco); err != nil {
+		return nil, err
+	} else if p != nil {
+		problemas = append(problemas, *p)
+	}
+
+	for entrada := range delta.remocoes {
+		if !removidas[entrada] {
+			problemas = append(problemas, Problema{delta.nome, delta.posicoes[entrada], fmt.Sprintf("remove a entrada com preço %s e offset %d, que não está em %s", entrada.preco, entrada.offset, indexPreco)})
+		}
+	}
+	for _, entrada := range delta.adicoes {
+		if err := conferirEntradaPreco(delta.nome, delta.posicoes[entrada], entrada); err != nil {
+			return nil, err
+		}
+	}
+
+	return append(problemas, registrosSemEntrada(produtos, indexados, indexPreco)...), nil
+}
+
+// conferirEntrada valida o offset de uma entrada de índice, marca o registro como
+// indexado e chama conferir para as checagens específicas do índice.
+func conferirEntrada(dados *registrosVerificados, indexados []bool, offsetDados int64, conferir func(pos int) string) string {
+	if offsetDados%int64(dados.tamanho) != 0 {
+		return fmt.Sprintf("offset %d não está alinhado a um registro de %d bytes", offsetDados, dados.tamanho)
+	}
+	pos := offsetDados / int64(dados.tamanho)
+	if offsetDados < 0 || pos >= int64(len(dados.ids)) {
+		return fmt.Sprintf("offset %d fora do arquivo de dados (%d bytes)", offsetDados, dados.tamanhoArq)
+	}
+
+	if indexados[pos] {
+		return fmt.Sprintf("registro no offset %d indexado mais de uma vez", offsetDados)
+	}
+	indexados[pos] = true
+	return conferir(int(pos))
+}
+
+func registrosSemEntrada(dados *registrosVerificados, indexados []bool, indexName string) []Problema {
+	var problemas []Problema
+	for pos, indexado := range indexados {
+		if !indexado {
+			problemas = append(problemas, Problema{dados.arquivo, dados.offset(pos), fmt.Sprintf("registro com ID %d não está em %s", dados.ids[pos], indexName)})
+		}
+	}
+	return problemas
+}
+
+func problemaDeTamanho(scanner *scannerRegistros, indexName string, tamanhoEntrada int) (*Problema, error) {
+	var errRegistro *RecordError
+	if errors.As(scanner.erro(), &errRegistro) && errors.Is(errRegistro, ErrCorrupt) {
+		return &Problema{indexName, errRegistro.Offset, fmt.Sprintf("entrada incompleta: o índice não é múltiplo de %d bytes", tamanhoEntrada)}, nil
+	}
+	return nil, scanner.erro()
+}
+
+// verificarSequencia confere que a sequência persistida está acima de todos os IDs.
+func verificarSequencia(armazenamento Armazenamento, dados *registrosVerificados) ([]Problema, error) {
+	nome := arquivoSequencia(dados.arquivo)
+	_, err := armazenamento.Info(nome)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	// O arquivo existe, então lerSequencia não precisa calcular o maior ID.
+	proximo, err := lerSequencia(armazenamento, dados.arquivo, func(Armazenamento, string) (int32, error) { return 0, nil })
+	if errors.Is(err, ErrCorrupt) {
+		return []Problema{{nome, 0, err.Error()}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var maior int32
+	for _, id := range dados.ids {
+		maior = max(maior, id)
+	}
+	if len(dados.ids) > 0 && proximo <= maior {
+		return []Problema{{nome, 0, fmt.Sprintf("próximo ID %d não é maior que o maior ID em uso (%d)", proximo, maior)}}, nil
+	}
+	return nil, nil
+}
+
+// verificarDiario aponta uma atualização interrompida, que abrirBanco ainda vai concluir.
+func verificarDiario(armazenamento Armazenamento, dados *registrosVerificados) ([]Problema, error) {
+	nome := arquivoDiario(dados.arquivo)
+	_, err := armazenamento.Info(nome)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao verificar o diário %s: %w", nome, err)
+	}
+	return []Problema{{nome, 0, "atualização interrompida; será concluída na próxima abertura do banco"}}, nil
+}
+
+func escreverProblemas(w io.Writer, formato string, problemas []Problema) error {
+	if problemas == nil {
+		problemas = []Problema{}
+	}
+
+	linhas := make([][]string, len(problemas))
+	for i, p := range problemas {
+		linhas[i] = []string{p.Arquivo, strconv.FormatInt(p.Offset, 10), p.Mensagem}
+	}
+	return escreverSaida(w, formato, []string{"arquivo", "offset", "problema"}, linhas, problemas)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// sobrescrever grava b em nome a partir de offset, sem passar pelo banco.
+func sobrescrever(t *testing.T, armazenamento Armazenamento, nome string, offset int64, b []byte) {
+	t.Helper()
+	file, err := armazenamento.Abrir(nome, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteAt(b, offset); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerificarIndiceCorrompido(t *testing.T) {
+	silenciarLogs(t)
+	m := novoArmazenamentoMemoria()
+	arquivos := arquivosPadrao()
+	arquivos.Armazenamento = m
+	b, err := abrirBanco(arquivos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var produtos []Produto
+	for i := range 5 {
+		produtos = append(produtos, produtoTeste(t, int32(i), Preco(i+1)*100, "m"))
+	}
+	if _, err := b.inserirProdutos(produtos); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.inserirAcessos([]Acesso{acessoTeste(t, "s", 1, "view"), acessoTeste(t, "s", 2, "view")}); err != nil {
+		t.Fatal(err)
+	}
+	// Os cinco produtos ficam na base do índice de preços e o sexto, no delta.
+	if err := b.reindexar(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.inserirProduto(produtoTeste(t, 9, 50, "m")); err != nil {
+		t.Fatal(err)
+	}
+	if problemas, err := verificarArquivos(arquivos); err != nil || len(problemas) > 0 {
+		t.Fatalf("banco recém-criado com problemas: %v, %v", problemas, err)
+	}
+
+	entradaID := func(id int32, offset int64) []byte {
+		var b [tamanhoIndiceID]byte
+		codificarEntradaIndice(b[:], int32ToBytes(id), offset)
+		return b[:]
+	}
+	operacaoRemocao := func(preco Preco, offset int64) []byte {
+		b := make([]byte, tamanhoOperacaoDelta)
+		entradaPreco{preco, offset}.codificar(b)
+		b[tamanhoIndicePreco] = remocaoDelta
+		return b
+	}
+	bytesPreco := func(p Preco) []byte {
+		b := precoToBytes(p)
+		return b[:]
+	}
+	delta := arquivoDelta(arquivos.IndicePrecos)
+
+	casos := []struct {
+		nome      string
+		corromper func(Armazenamento)
+		arquivo   string
+		offset    int64
+		mensagem  string
+	}{
+		{"entradas de ID trocadas", func(a Armazenamento) {
+			sobrescrever(t, a, arquivos.IndiceProdutos, tamanhoIndiceID, append(entradaID(3, 2*tamanhoProduto), entradaID(2, tamanhoProduto)...))
+		}, arquivos.IndiceProdutos, 2 * tamanhoIndiceID, "fora de ordem"},
+		{"entrada de ID para o registro errado", func(a Armazenamento) {
+			sobrescrever(t, a, arquivos.IndiceProdutos, 0, entradaID(1, tamanhoProduto))
+		}, arquivos.IndiceProdutos, 0, "aponta para o registro de ID 2"},
+		{"entrada de ID incompleta", func(a Armazenamento) {
+			if err := a.Truncar(arquivos.IndiceAcessos, tamanhoIndiceID+5); err != nil {
+				t.Fatal(err)
+			}
+		}, arquivos.IndiceAcessos, tamanhoIndiceID, "entrada incompleta"},
+		{"preço diferente do registro", func(a Armazenamento) {
+			sobrescrever(t, a, arquivos.IndicePrecos, 0, bytesPreco(1))
+		}, arquivos.IndicePrecos, 0, "aponta para o produto de ID 1 com preço 1.00"},
+		{"entrada de preço fora de ordem", func(a Armazenamento) {
+			sobrescrever(t, a, arquivos.IndicePrecos, tamanhoIndicePreco, bytesPreco(99999))
+		}, arquivos.IndicePrecos, 2 * tamanhoIndicePreco, "fora de ordem"},
+		{"operação incompleta no delta", func(a Armazenamento) {
+			sobrescrever(t, a, delta, tamanhoOperacaoDelta, []byte{1})
+		}, delta, tamanhoOperacaoDelta, "operação incompleta"},
+		{"remoção de entrada que não existe", func(a Armazenamento) {
+			sobrescrever(t, a, delta, tamanhoOperacaoDelta, operacaoRemocao(777, 0))
+		}, delta, tamanhoOperacaoDelta, "que não está em"},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nome, func(t *testing.T) {
+			corrompido := arquivos
+			corrompido.Armazenamento = m.copiar()
+			caso.corromper(corrompido.Armazenamento)
+
+			problemas, err := verificarArquivos(corrompido)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, p := range problemas {
+				if p.Arquivo == caso.arquivo && p.Offset == caso.offset && strings.Contains(p.Mensagem, caso.mensagem) {
+					return
+				}
+			}
+			t.Errorf("nenhum problema %q em %s@%d; problemas: %v", caso.mensagem, caso.arquivo, caso.offset, problemas)
+		})
+	}
+}